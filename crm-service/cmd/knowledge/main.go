@@ -0,0 +1,135 @@
+// Command knowledge imports and exports knowledge base entries and FAQs.
+//
+//	knowledge import [-format jsonl|csv] FILE
+//	knowledge export [-format jsonl|csv] [-vectors] [-out FILE]
+//
+// Imports are embedded in throttled batches (KNOWLEDGE_IMPORT_BATCH_SIZE and
+// KNOWLEDGE_IMPORT_BATCH_DELAY); entries already present are skipped.
+package main
+
+import (
+	"divine-crm/internal/config"
+	"divine-crm/internal/database"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/services"
+	"divine-crm/internal/utils"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	appLogger := utils.NewLogger(cfg.Logging.Level)
+
+	embedder, err := services.NewEmbedder(cfg)
+	if err != nil {
+		appLogger.Error("Invalid embedding configuration", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		appLogger.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+
+	vectorService := services.NewVectorService(repository.NewVectorRepository(db, cfg.VectorIndex.EFSearch), embedder, cfg, appLogger)
+
+	switch os.Args[1] {
+	case "import":
+		runImport(vectorService, appLogger, os.Args[2:])
+	case "export":
+		runExport(vectorService, appLogger, os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: knowledge import [-format jsonl|csv] FILE")
+	fmt.Fprintln(os.Stderr, "       knowledge export [-format jsonl|csv] [-vectors] [-out FILE]")
+	os.Exit(2)
+}
+
+func runImport(vectorService *services.VectorService, appLogger *utils.Logger, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "jsonl or csv; taken from the file extension when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	filename := flags.Arg(0)
+
+	detected, err := services.DetectTransferFormat(*format, filename)
+	if err != nil {
+		appLogger.Error("Unknown import format", "error", err)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		appLogger.Error("Failed to read import file", "error", err)
+		os.Exit(1)
+	}
+
+	records, progress, err := services.ParseKnowledgeRecords(data, detected)
+	if err != nil {
+		appLogger.Error("Failed to parse import file", "error", err)
+		os.Exit(1)
+	}
+	appLogger.Info("Importing knowledge", "file", filename, "records", progress.Total)
+
+	vectorService.ImportKnowledgeRecords(records, progress, func(p *services.ImportProgress) {
+		appLogger.Info("Importing", "processed", p.Processed, "total", p.Total)
+	})
+
+	for _, line := range progress.Errors {
+		appLogger.Warn("Record not imported", "error", line)
+	}
+	appLogger.Info("✅ Import complete",
+		"imported", progress.Imported, "skipped", progress.Skipped, "failed", progress.Failed)
+	if progress.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runExport(vectorService *services.VectorService, appLogger *utils.Logger, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", services.TransferJSONL, "jsonl or csv")
+	vectors := flags.Bool("vectors", false, "include embeddings")
+	out := flags.String("out", "", "output file; knowledge-YYYYMMDD.<format> when empty")
+	flags.Parse(args)
+
+	detected, err := services.DetectTransferFormat(*format, "")
+	if err != nil {
+		appLogger.Error("Unknown export format", "error", err)
+		os.Exit(1)
+	}
+
+	// Logs go to stdout, so the dump always goes to a file
+	if *out == "" {
+		*out = fmt.Sprintf("knowledge-%s.%s", time.Now().Format("20060102"), detected)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		appLogger.Error("Failed to create export file", "error", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if err := vectorService.ExportKnowledge(f, detected, *vectors); err != nil {
+		appLogger.Error("Export failed", "error", err)
+		os.Exit(1)
+	}
+	appLogger.Info("✅ Export complete", "file", *out)
+}
@@ -0,0 +1,62 @@
+// Command reembed recomputes all stored embeddings with the model configured by
+// EMBEDDING_PROVIDER / EMBEDDING_MODEL / EMBEDDING_DIMENSIONS. Run it after changing
+// the embedding model; it can be stopped and restarted safely.
+package main
+
+import (
+	"divine-crm/internal/config"
+	"divine-crm/internal/database"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/services"
+	"divine-crm/internal/utils"
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 100, "rows read per query")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	appLogger := utils.NewLogger(cfg.Logging.Level)
+
+	embedder, err := services.NewEmbedder(cfg)
+	if err != nil {
+		appLogger.Error("Invalid embedding configuration", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		appLogger.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+
+	vectorService := services.NewVectorService(repository.NewVectorRepository(db, cfg.VectorIndex.EFSearch), embedder, cfg, appLogger)
+	quickReplyService := services.NewQuickReplyService(repository.NewQuickReplyRepository(db), vectorService, appLogger)
+
+	appLogger.Info("Re-embedding stored vectors",
+		"provider", embedder.Provider(), "model", embedder.Model(), "dimensions", embedder.Dimensions())
+
+	if err := vectorService.Reembed(*batchSize); err != nil {
+		appLogger.Error("Re-embedding failed", "error", err)
+		os.Exit(1)
+	}
+
+	replies, err := quickReplyService.ReembedSemantic()
+	if err != nil {
+		appLogger.Error("Re-embedding quick replies failed", "error", err)
+		os.Exit(1)
+	}
+	appLogger.Info("Re-embedded semantic quick replies", "count", replies)
+
+	if err := vectorService.RecordEmbeddingModel(); err != nil {
+		appLogger.Error("Failed to record embedding model", "error", err)
+		os.Exit(1)
+	}
+	appLogger.Info("✅ Re-embedding complete")
+}
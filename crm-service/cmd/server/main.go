@@ -40,9 +40,16 @@ func main() {
 	}
 	appLogger.Info("Database connected successfully")
 
+	// Embedding model for RAG; sizes the vector columns
+	embedder, err := services.NewEmbedder(cfg)
+	if err != nil {
+		appLogger.Error("Invalid embedding configuration", "error", err)
+		os.Exit(1)
+	}
+
 	// Run migrations
 	appLogger.Info("Running database migrations...")
-	if err := database.RunMigrations(db); err != nil {
+	if err := database.RunMigrations(db, embedder.Dimensions(), cfg.VectorIndex); err != nil {
 		appLogger.Error("Failed to run migrations", "error", err)
 		os.Exit(1)
 	}
@@ -60,50 +67,109 @@ func main() {
 	humanAgentRepo := repository.NewHumanAgentRepository(db)
 	broadcastRepo := repository.NewBroadcastRepository(db)
 	quickReplyRepo := repository.NewQuickReplyRepository(db)
-	vectorRepo := repository.NewVectorRepository(db)
+	vectorRepo := repository.NewVectorRepository(db, cfg.VectorIndex.EFSearch)
+	businessHoursRepo := repository.NewBusinessHoursRepository(db)
+	noteRepo := repository.NewNoteRepository(db)
+	automationRepo := repository.NewAutomationRepository(db)
+	cannedResponseRepo := repository.NewCannedResponseRepository(db)
+	segmentRepo := repository.NewSegmentRepository(db)
+	whatsappTemplateRepo := repository.NewWhatsAppTemplateRepository(db)
+	consentRepo := repository.NewConsentRepository(db)
+	outboundRepo := repository.NewOutboundRepository(db)
 
 	// ==================== INITIALIZE SERVICES ====================
 	appLogger.Info("Initializing services...")
 
-	// Core services
-	contactService := services.NewContactService(contactRepo, appLogger)
-	productService := services.NewProductService(productRepo, appLogger)
-	chatLabelService := services.NewChatLabelService(chatLabelRepo, appLogger)
-	quickReplyService := services.NewQuickReplyService(quickReplyRepo, appLogger)
-	vectorService := services.NewVectorService(vectorRepo, cfg, appLogger)
-
-	// ✅ AI Service now uses vectorService
-	aiService := services.NewAIService(aiRepo, vectorService, cfg)
-
-	humanAgentService := services.NewHumanAgentService(humanAgentRepo, appLogger, cfg)
+	// Platform services share one outbound rate limiter
+	outboundLimiter := services.NewOutboundLimiter(&cfg.Outbound, outboundRepo, appLogger)
+	whatsappService := services.NewWhatsAppService(platformRepo, outboundLimiter, appLogger)
+	instagramService := services.NewInstagramService(platformRepo, outboundLimiter, appLogger)
+	telegramService := services.NewTelegramService(platformRepo, outboundLimiter, appLogger)
+	whatsappTemplateService := services.NewWhatsAppTemplateService(
+		whatsappTemplateRepo,
+		contactRepo,
+		whatsappService,
+		appLogger,
+	)
 
-	// Platform services
-	whatsappService := services.NewWhatsAppService(platformRepo, appLogger)
-	instagramService := services.NewInstagramService(platformRepo, appLogger)
-	telegramService := services.NewTelegramService(platformRepo, appLogger)
+	// Core services
+	businessHoursService := services.NewBusinessHoursService(businessHoursRepo, appLogger)
+	automationService := services.NewAutomationService(
+		automationRepo,
+		chatRepo,
+		contactRepo,
+		chatLabelRepo,
+		businessHoursService,
+		whatsappService,
+		instagramService,
+		telegramService,
+		appLogger,
+	)
+	consentService := services.NewConsentService(consentRepo, contactRepo, appLogger)
 
 	// Broadcast service
 	broadcastService := services.NewBroadcastService(
 		broadcastRepo,
 		contactRepo,
+		segmentRepo,
 		whatsappService,
 		instagramService,
 		telegramService,
+		whatsappTemplateService,
+		consentService,
+		&cfg.Broadcast,
+		appLogger,
+	)
+
+	contactService := services.NewContactService(
+		contactRepo,
+		automationService,
+		consentService,
+		broadcastService,
 		appLogger,
 	)
+	vectorService := services.NewVectorService(vectorRepo, embedder, cfg, appLogger)
+	vectorService.CheckEmbeddingModel()
+	vectorService.FailUnfinishedImports()
+	productService := services.NewProductService(productRepo, vectorService, &cfg.RAG, appLogger)
+	chatLabelService := services.NewChatLabelService(chatLabelRepo, appLogger)
+	quickReplyService := services.NewQuickReplyService(quickReplyRepo, vectorService, appLogger)
+
+	// ✅ AI Service now uses vectorService
+	aiService := services.NewAIService(aiRepo, vectorService, cfg)
+
+	humanAgentService := services.NewHumanAgentService(humanAgentRepo, appLogger, cfg)
+	noteService := services.NewNoteService(noteRepo, chatRepo, humanAgentRepo, appLogger)
+	cannedResponseService := services.NewCannedResponseService(
+		cannedResponseRepo,
+		contactRepo,
+		productRepo,
+		chatRepo,
+		humanAgentRepo,
+		appLogger,
+	)
+
+	segmentService := services.NewSegmentService(segmentRepo, appLogger)
 
 	// Chat service (with product context)
 	chatService := services.NewChatService(
 		chatRepo,
+		chatLabelRepo,
 		contactService,
 		aiService,
 		productService,
+		businessHoursService,
+		automationService,
+		quickReplyService,
+		consentService,
+		broadcastService,
 		appLogger,
 	)
 
 	// Webhook service
 	webhookService := services.NewWebhookService(
 		chatService,
+		broadcastService,
 		whatsappService,
 		instagramService,
 		telegramService,
@@ -134,6 +200,13 @@ func main() {
 	quickReplyHandler := handlers.NewQuickReplyHandler(quickReplyService)
 	webhookHandler := handlers.NewWebhookHandler(webhookService, cfg)
 	vectorHandler := handlers.NewVectorHandler(vectorService)
+	businessHoursHandler := handlers.NewBusinessHoursHandler(businessHoursService)
+	noteHandler := handlers.NewNoteHandler(noteService)
+	automationHandler := handlers.NewAutomationHandler(automationService)
+	cannedResponseHandler := handlers.NewCannedResponseHandler(cannedResponseService)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	whatsappTemplateHandler := handlers.NewWhatsAppTemplateHandler(whatsappTemplateService)
+	consentHandler := handlers.NewConsentHandler(consentService)
 
 	// ==================== INITIALIZE FIBER APP ====================
 	app := fiber.New(fiber.Config{
@@ -230,8 +303,21 @@ func main() {
 		quickReplyHandler,
 		webhookHandler,
 		vectorHandler,
+		businessHoursHandler,
+		noteHandler,
+		automationHandler,
+		cannedResponseHandler,
+		segmentHandler,
+		whatsappTemplateHandler,
+		consentHandler,
 	)
 
+	// ==================== BACKGROUND JOBS ====================
+	go automationService.StartIdleWatcher(time.Minute)
+	go broadcastService.StartScheduler(time.Minute)
+	go broadcastService.StartDeliveryWorker(5 * time.Second)
+	go vectorService.StartEmbeddingCachePruner(time.Hour)
+
 	// ==================== START SERVER ====================
 	port := cfg.Server.Port
 	appLogger.Info("Server starting with Vector RAG capabilities", "port", port)
@@ -22,6 +22,13 @@ func setupRoutes(
 	quickReplyHandler *handlers.QuickReplyHandler,
 	webhookHandler *handlers.WebhookHandler,
 	vectorHandler *handlers.VectorHandler,
+	businessHoursHandler *handlers.BusinessHoursHandler,
+	noteHandler *handlers.NoteHandler,
+	automationHandler *handlers.AutomationHandler,
+	cannedResponseHandler *handlers.CannedResponseHandler,
+	segmentHandler *handlers.SegmentHandler,
+	whatsappTemplateHandler *handlers.WhatsAppTemplateHandler,
+	consentHandler *handlers.ConsentHandler,
 ) {
 	// ==================== ROOT ====================
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -86,6 +93,14 @@ func setupRoutes(
 	contacts.Delete("/:id", contactHandler.Delete)
 	contacts.Patch("/:id/temperature", contactHandler.UpdateTemperature)
 	contacts.Patch("/:id/status", contactHandler.UpdateStatus)
+	contacts.Get("/:id/consent", consentHandler.GetContactConsent)
+	contacts.Put("/:id/consent", consentHandler.UpdateContactConsent)
+
+	// Suppression list
+	suppressions := protected.Group("/suppressions")
+	suppressions.Get("/", consentHandler.GetSuppressions)
+	suppressions.Post("/", consentHandler.AddSuppression)
+	suppressions.Delete("/:id", consentHandler.DeleteSuppression)
 
 	// Products
 	products := protected.Group("/products")
@@ -94,12 +109,15 @@ func setupRoutes(
 	products.Get("/search", productHandler.Search)
 	products.Get("/:id", productHandler.GetByID)
 	products.Post("/", productHandler.Create)
+	products.Get("/embeddings/failed", productHandler.GetFailedEmbeddingSyncs)
+	products.Post("/embeddings/sync", productHandler.SyncEmbeddings)
 	products.Put("/:id", productHandler.Update)
 	products.Delete("/:id", productHandler.Delete)
 
 	// Chat Labels
 	labels := protected.Group("/chat-labels")
 	labels.Get("/", chatLabelHandler.GetAll)
+	labels.Get("/counts", chatLabelHandler.GetCounts)
 	labels.Get("/:id", chatLabelHandler.GetByID)
 	labels.Post("/", chatLabelHandler.Create)
 	labels.Put("/:id", chatLabelHandler.Update)
@@ -112,6 +130,8 @@ func setupRoutes(
 	chats.Get("/unassigned", chatHandler.GetUnassigned)
 	chats.Get("/assigned", chatHandler.GetAssigned)
 	chats.Get("/resolved", chatHandler.GetResolved)
+	chats.Get("/sla-breached", chatHandler.GetSLABreached)
+	chats.Post("/labels/bulk", chatHandler.BulkApplyLabels)
 	chats.Get("/:id", chatHandler.GetByID)
 	chats.Post("/", chatHandler.Create)
 	chats.Put("/:id", chatHandler.Update)
@@ -119,6 +139,19 @@ func setupRoutes(
 	chats.Patch("/:id/resolve", chatHandler.Resolve)
 	chats.Patch("/:id/takeover", chatHandler.TakeOver)
 	chats.Patch("/:id/to-ai", chatHandler.BackToAI)
+	chats.Post("/:id/labels", chatHandler.AddLabels)
+	chats.Delete("/:id/labels/:labelId", chatHandler.RemoveLabel)
+
+	// Internal notes (agent-only, never sent to the customer)
+	chats.Get("/:id/notes", noteHandler.GetByChat)
+	chats.Post("/:id/notes", noteHandler.Create)
+	chats.Delete("/:id/notes/:noteId", noteHandler.Delete)
+
+	// Mention notifications
+	mentions := protected.Group("/notifications/mentions")
+	mentions.Get("/", noteHandler.GetMentions)
+	mentions.Patch("/read-all", noteHandler.MarkAllMentionsRead)
+	mentions.Patch("/:id/read", noteHandler.MarkMentionRead)
 
 	// AI Configurations
 	aiConfigs := protected.Group("/ai/configurations")
@@ -164,10 +197,42 @@ func setupRoutes(
 	broadcast.Get("/templates", broadcastHandler.GetAllTemplates)
 	broadcast.Get("/templates/:id", broadcastHandler.GetTemplateByID)
 	broadcast.Post("/templates", broadcastHandler.CreateTemplate)
+	broadcast.Post("/templates/preview", broadcastHandler.PreviewTemplate)
 	broadcast.Put("/templates/:id", broadcastHandler.UpdateTemplate)
 	broadcast.Delete("/templates/:id", broadcastHandler.DeleteTemplate)
+	broadcast.Get("/audience", broadcastHandler.CountAudience)
 	broadcast.Post("/send", broadcastHandler.SendBroadcast)
 	broadcast.Get("/history", broadcastHandler.GetHistory)
+	broadcast.Get("/history/:id", broadcastHandler.GetHistoryByID)
+	broadcast.Get("/history/:id/recipients", broadcastHandler.GetRecipients)
+	broadcast.Get("/history/:id/recipients/export", broadcastHandler.ExportRecipients)
+	broadcast.Post("/history/:id/retry", broadcastHandler.RetryFailed)
+	broadcast.Post("/history/:id/winner", broadcastHandler.ChooseWinner)
+	broadcast.Get("/history/:id/report", broadcastHandler.GetReport)
+	broadcast.Post("/conversions", broadcastHandler.RecordConversion)
+	broadcast.Get("/schedules", broadcastHandler.GetSchedules)
+	broadcast.Post("/schedules", broadcastHandler.ScheduleBroadcast)
+	broadcast.Get("/schedules/:id", broadcastHandler.GetScheduleByID)
+	broadcast.Put("/schedules/:id", broadcastHandler.Reschedule)
+	broadcast.Post("/schedules/:id/cancel", broadcastHandler.CancelSchedule)
+
+	// Segments
+	segments := protected.Group("/segments")
+	segments.Get("/", segmentHandler.GetAll)
+	segments.Post("/", segmentHandler.Create)
+	segments.Post("/preview", segmentHandler.Preview)
+	segments.Get("/:id", segmentHandler.GetByID)
+	segments.Put("/:id", segmentHandler.Update)
+	segments.Delete("/:id", segmentHandler.Delete)
+	segments.Get("/:id/contacts", segmentHandler.GetContacts)
+
+	// WhatsApp Templates
+	waTemplates := protected.Group("/whatsapp/templates")
+	waTemplates.Get("/", whatsappTemplateHandler.GetAll)
+	waTemplates.Post("/sync", whatsappTemplateHandler.Sync)
+	waTemplates.Get("/:id", whatsappTemplateHandler.GetByID)
+	waTemplates.Put("/:id/parameters", whatsappTemplateHandler.UpdateParameters)
+	waTemplates.Post("/:id/preview", whatsappTemplateHandler.Preview)
 
 	// Quick Replies
 	quickReplies := protected.Group("/quick-replies")
@@ -176,6 +241,37 @@ func setupRoutes(
 	quickReplies.Put("/:id", quickReplyHandler.Update)
 	quickReplies.Delete("/:id", quickReplyHandler.Delete)
 
+	// Canned Responses
+	cannedResponses := protected.Group("/canned-responses")
+	cannedResponses.Get("/", cannedResponseHandler.GetAll)
+	cannedResponses.Get("/search", cannedResponseHandler.Search)
+	cannedResponses.Get("/categories", cannedResponseHandler.GetCategories)
+	cannedResponses.Post("/", cannedResponseHandler.Create)
+	cannedResponses.Put("/:id", cannedResponseHandler.Update)
+	cannedResponses.Delete("/:id", cannedResponseHandler.Delete)
+	cannedResponses.Post("/:id/render", cannedResponseHandler.Render)
+
+	// Automation Rules
+	automation := protected.Group("/automation")
+	automation.Get("/rules", automationHandler.GetAll)
+	automation.Get("/rules/:id", automationHandler.GetByID)
+	automation.Post("/rules", automationHandler.Create)
+	automation.Put("/rules/:id", automationHandler.Update)
+	automation.Delete("/rules/:id", automationHandler.Delete)
+	automation.Post("/dry-run", automationHandler.DryRun)
+	automation.Get("/logs", automationHandler.GetLogs)
+
+	// Business Hours
+	businessHours := protected.Group("/business-hours")
+	businessHours.Get("/", businessHoursHandler.GetSettings)
+	businessHours.Put("/", businessHoursHandler.UpdateSettings)
+	businessHours.Get("/status", businessHoursHandler.GetStatus)
+	businessHours.Get("/schedule", businessHoursHandler.GetSchedule)
+	businessHours.Put("/schedule", businessHoursHandler.UpdateSchedule)
+	businessHours.Get("/holidays", businessHoursHandler.GetHolidays)
+	businessHours.Post("/holidays", businessHoursHandler.CreateHoliday)
+	businessHours.Delete("/holidays/:id", businessHoursHandler.DeleteHoliday)
+
 	// ==================== VECTOR EMBEDDINGS & RAG ====================
 	vectors := protected.Group("/vectors")
 
@@ -183,16 +279,47 @@ func setupRoutes(
 	vectors.Post("/knowledge", vectorHandler.AddKnowledge)
 	vectors.Get("/knowledge", vectorHandler.GetAllKnowledge)
 	vectors.Get("/knowledge/search", vectorHandler.SearchKnowledge)
+	vectors.Put("/knowledge/category-status", vectorHandler.SetKnowledgeCategoryActive)
+	vectors.Put("/knowledge/:id", vectorHandler.UpdateKnowledge)
+	vectors.Delete("/knowledge/:id", vectorHandler.DeleteKnowledge)
+
+	// Knowledge Documents (chunked uploads)
+	vectors.Post("/knowledge/documents", vectorHandler.IngestDocument)
+	vectors.Get("/knowledge/documents", vectorHandler.GetAllDocuments)
+	vectors.Get("/knowledge/documents/:id", vectorHandler.GetDocument)
+	vectors.Delete("/knowledge/documents/:id", vectorHandler.DeleteDocument)
 
 	// FAQ
 	vectors.Post("/faq", vectorHandler.AddFAQ)
 	vectors.Get("/faq", vectorHandler.GetAllFAQ)
 	vectors.Get("/faq/search", vectorHandler.SearchFAQ)
+	vectors.Put("/faq/category-status", vectorHandler.SetFAQCategoryActive)
+	vectors.Put("/faq/:id", vectorHandler.UpdateFAQ)
+	vectors.Delete("/faq/:id", vectorHandler.DeleteFAQ)
 
 	// Product Semantic Search
 	vectors.Post("/products/embedding", vectorHandler.AddProductEmbedding)
 	vectors.Get("/products/search", vectorHandler.SearchProducts)
+	vectors.Put("/products/embedding/:id", vectorHandler.UpdateProductEmbedding)
+	vectors.Delete("/products/embedding/:id", vectorHandler.DeleteProductEmbedding)
+
+	// Bulk import & export of knowledge and FAQs
+	vectors.Post("/import", vectorHandler.ImportKnowledge)
+	vectors.Get("/import", vectorHandler.GetImportJobs)
+	vectors.Get("/import/:id", vectorHandler.GetImportJob)
+	vectors.Get("/export", vectorHandler.ExportKnowledge)
+
+	// Versions of knowledge, faq and product entries
+	vectors.Get("/versions/:type/:id", vectorHandler.GetVersions)
+	vectors.Post("/versions/:type/:id/rollback", vectorHandler.Rollback)
 
 	// Chat History
 	vectors.Get("/chat-history/:contactId", vectorHandler.GetSimilarConversations)
+
+	// RAG context preview
+	vectors.Get("/rag/context", vectorHandler.RetrieveContext)
+
+	// HNSW index management
+	vectors.Get("/indexes", vectorHandler.GetVectorIndexes)
+	vectors.Post("/indexes/rebuild", vectorHandler.RebuildVectorIndexes)
 }
@@ -4,24 +4,31 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	OpenAI    OpenAIConfig
-	DeepSeek  AIProviderConfig
-	Grok      AIProviderConfig
-	Gemini    GeminiConfig
-	WhatsApp  WhatsAppConfig
-	Instagram InstagramConfig
-	Telegram  TelegramConfig
-	JWT       JWTConfig
-	CORS      CORSConfig
-	RateLimit RateLimitConfig
-	Logging   LoggingConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	OpenAI      OpenAIConfig
+	Embedding   EmbeddingConfig
+	DeepSeek    AIProviderConfig
+	Grok        AIProviderConfig
+	Gemini      GeminiConfig
+	WhatsApp    WhatsAppConfig
+	Instagram   InstagramConfig
+	Telegram    TelegramConfig
+	JWT         JWTConfig
+	CORS        CORSConfig
+	RateLimit   RateLimitConfig
+	Outbound    OutboundConfig
+	Broadcast   BroadcastConfig
+	Knowledge   KnowledgeConfig
+	RAG         RAGConfig
+	VectorIndex VectorIndexConfig
+	Logging     LoggingConfig
 }
 
 type ServerConfig struct {
@@ -49,6 +56,18 @@ type OpenAIConfig struct {
 	Endpoint string
 }
 
+// EmbeddingConfig selects the model that turns text into vectors for RAG
+type EmbeddingConfig struct {
+	Provider   string // openai, gemini or local (any OpenAI-compatible /embeddings endpoint)
+	Model      string
+	Endpoint   string // For Gemini the model URL, without :batchEmbedContents
+	APIKey     string // Defaults to the OpenAI or Gemini key of the provider
+	Dimensions int    // 0 uses the model's default; required for local models
+	BatchSize  int    // Texts sent per embedding request
+	CacheSize  int    // Embeddings kept in memory; 0 disables the in-memory cache
+	CacheTTL   string // How long an unused embedding stays in the database cache
+}
+
 type AIProviderConfig struct {
 	APIKey   string
 	Model    string
@@ -103,6 +122,63 @@ type RateLimitConfig struct {
 	Window            string
 }
 
+// OutboundConfig limits messages sent to each platform account
+type OutboundConfig struct {
+	WhatsAppPerSecond          int
+	WhatsAppDailyConversations int // Messaging tier: unique business-initiated recipients per 24h
+	TelegramPerSecond          int
+	TelegramPerChatPerSecond   int
+	InstagramPerSecond         int
+	MaxWait                    string // Longest a sender blocks before giving up with a rate limit error
+}
+
+// BroadcastConfig sets how long after a broadcast replies and conversions are credited to it
+type BroadcastConfig struct {
+	ReplyWindow      string
+	ConversionWindow string
+}
+
+// KnowledgeConfig sets how uploaded documents are split before embedding and how
+// bulk imports are throttled
+type KnowledgeConfig struct {
+	ChunkTokens      int    // Approximate tokens per chunk
+	ChunkOverlap     int    // Tokens repeated from the end of the previous chunk
+	MaxExtractedSize int    // Largest decompressed size in bytes a document may expand to during extraction
+	ImportBatchSize  int    // Entries embedded and stored per import batch
+	ImportBatchDelay string // Pause between import batches, keeps imports under provider rate limits
+}
+
+// RAGConfig tunes how knowledge and FAQs are retrieved for AI replies
+type RAGConfig struct {
+	KnowledgeLimit int            // Knowledge chunks put in the prompt
+	FAQLimit       int            // FAQs put in the prompt
+	CategoryLimits map[string]int // Most results per category, e.g. "harga:2,promo:1"
+
+	// Minimum cosine similarity to the message per source; defaults suit text-embedding-ada-002
+	MinKnowledgeSimilarity float64
+	MinFAQSimilarity       float64
+	MinProductSimilarity   float64
+
+	HandoffWithoutContext bool // Leave chats Pending for an agent when no result passes the cutoffs
+
+	ProductLimit int    // Catalog products put in the prompt for messages with purchase intent
+	OutOfStock   string // flag (listed as sold out) or exclude
+
+	Candidates     int    // Rows taken from each of vector and full-text search before fusion
+	Rerank         string // none, llm or api (a Cohere/Jina-compatible /rerank endpoint)
+	RerankEndpoint string
+	RerankModel    string
+	RerankAPIKey   string
+}
+
+// VectorIndexConfig tunes the HNSW indexes on embedding columns. Changing M or
+// EFConstruction only affects indexes built afterwards, see POST /vectors/indexes/rebuild.
+type VectorIndexConfig struct {
+	M              int // Links per node; higher improves recall and costs memory
+	EFConstruction int // Candidate list size while building
+	EFSearch       int // Candidate list size while searching (hnsw.ef_search); must be at least the result limit
+}
+
 type LoggingConfig struct {
 	Level  string
 	Format string
@@ -135,6 +211,16 @@ func LoadConfig() (*Config, error) {
 			Model:    getEnv("OPENAI_MODEL", "gpt-3.5-turbo"),
 			Endpoint: getEnv("OPENAI_ENDPOINT", "https://api.openai.com/v1/chat/completions"),
 		},
+		Embedding: EmbeddingConfig{
+			Provider:   getEnv("EMBEDDING_PROVIDER", "openai"),
+			Model:      getEnv("EMBEDDING_MODEL", ""),
+			Endpoint:   getEnv("EMBEDDING_ENDPOINT", ""),
+			APIKey:     getEnv("EMBEDDING_API_KEY", ""),
+			Dimensions: getEnvInt("EMBEDDING_DIMENSIONS", 0),
+			BatchSize:  getEnvInt("EMBEDDING_BATCH_SIZE", 96),
+			CacheSize:  getEnvInt("EMBEDDING_CACHE_SIZE", 10000),
+			CacheTTL:   getEnv("EMBEDDING_CACHE_TTL", "720h"),
+		},
 		DeepSeek: AIProviderConfig{
 			APIKey:   getEnv("DEEPSEEK_API_KEY", ""),
 			Model:    getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
@@ -186,6 +272,46 @@ func LoadConfig() (*Config, error) {
 			Max:               getEnvInt("RATE_LIMIT_MAX", 100),
 			Window:            getEnv("RATE_LIMIT_WINDOW", "1m"),
 		},
+		Outbound: OutboundConfig{
+			WhatsAppPerSecond:          getEnvInt("WHATSAPP_MESSAGES_PER_SECOND", 80),
+			WhatsAppDailyConversations: getEnvInt("WHATSAPP_TIER_LIMIT", 1000),
+			TelegramPerSecond:          getEnvInt("TELEGRAM_MESSAGES_PER_SECOND", 30),
+			TelegramPerChatPerSecond:   getEnvInt("TELEGRAM_MESSAGES_PER_CHAT_PER_SECOND", 1),
+			InstagramPerSecond:         getEnvInt("INSTAGRAM_MESSAGES_PER_SECOND", 10),
+			MaxWait:                    getEnv("OUTBOUND_MAX_WAIT", "30s"),
+		},
+		Broadcast: BroadcastConfig{
+			ReplyWindow:      getEnv("BROADCAST_REPLY_WINDOW", "72h"),
+			ConversionWindow: getEnv("BROADCAST_CONVERSION_WINDOW", "168h"),
+		},
+		Knowledge: KnowledgeConfig{
+			ChunkTokens:      getEnvInt("KNOWLEDGE_CHUNK_TOKENS", 500),
+			ChunkOverlap:     getEnvInt("KNOWLEDGE_CHUNK_OVERLAP", 50),
+			MaxExtractedSize: getEnvInt("KNOWLEDGE_MAX_EXTRACTED_SIZE", 50<<20),
+			ImportBatchSize:  getEnvInt("KNOWLEDGE_IMPORT_BATCH_SIZE", 50),
+			ImportBatchDelay: getEnv("KNOWLEDGE_IMPORT_BATCH_DELAY", "1s"),
+		},
+		RAG: RAGConfig{
+			KnowledgeLimit:         getEnvInt("RAG_KNOWLEDGE_LIMIT", 3),
+			FAQLimit:               getEnvInt("RAG_FAQ_LIMIT", 2),
+			CategoryLimits:         getEnvIntMap("RAG_CATEGORY_LIMITS"),
+			MinKnowledgeSimilarity: getEnvFloat("RAG_MIN_SIMILARITY_KNOWLEDGE", 0.75),
+			MinFAQSimilarity:       getEnvFloat("RAG_MIN_SIMILARITY_FAQ", 0.8),
+			MinProductSimilarity:   getEnvFloat("RAG_MIN_SIMILARITY_PRODUCT", 0.75),
+			HandoffWithoutContext:  getEnvBool("RAG_HANDOFF_WITHOUT_CONTEXT", false),
+			ProductLimit:           getEnvInt("RAG_PRODUCT_LIMIT", 5),
+			OutOfStock:             getEnv("RAG_OUT_OF_STOCK", "flag"),
+			Candidates:             getEnvInt("RAG_CANDIDATES", 20),
+			Rerank:                 getEnv("RAG_RERANK", "none"),
+			RerankEndpoint:         getEnv("RAG_RERANK_ENDPOINT", ""),
+			RerankModel:            getEnv("RAG_RERANK_MODEL", ""),
+			RerankAPIKey:           getEnv("RAG_RERANK_API_KEY", ""),
+		},
+		VectorIndex: VectorIndexConfig{
+			M:              getEnvInt("VECTOR_INDEX_M", 16),
+			EFConstruction: getEnvInt("VECTOR_INDEX_EF_CONSTRUCTION", 64),
+			EFSearch:       getEnvInt("VECTOR_INDEX_EF_SEARCH", 40),
+		},
 		Logging: LoggingConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
 			Format: getEnv("LOG_FORMAT", "json"),
@@ -227,6 +353,35 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return boolValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return floatValue
+}
+
+// getEnvIntMap parses "key:1,other:2" into a map with lowercase keys, skipping invalid pairs
+func getEnvIntMap(key string) map[string]int {
+	result := map[string]int{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		result[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	return result
+}
+
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
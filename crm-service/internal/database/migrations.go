@@ -1,14 +1,16 @@
 package database
 
 import (
+	"divine-crm/internal/config"
 	"divine-crm/internal/models"
 	"fmt"
 	"gorm.io/gorm"
 	"log"
 )
 
-// RunMigrations runs all database migrations
-func RunMigrations(db *gorm.DB) error {
+// RunMigrations runs all database migrations. Vector columns get the given number of dimensions
+// and searched ones an HNSW index built with the index settings.
+func RunMigrations(db *gorm.DB, dimensions int, index config.VectorIndexConfig) error {
 	log.Println("🚀 Running database migrations...")
 
 	// ============================================
@@ -60,12 +62,17 @@ func RunMigrations(db *gorm.DB) error {
 	// ============================================
 	log.Println("📋 Migrating database tables...")
 
+	backfillResponses := !db.Migrator().HasColumn(&models.ChatMessage{}, "first_responded_at")
+	backfillManaged := !db.Migrator().HasColumn(&models.ProductEmbedding{}, "managed")
 	err = db.AutoMigrate(
 		// Core entities
 		&models.Contact{},
 		&models.Product{},
 		&models.ChatLabel{},
 		&models.ChatMessage{},
+		&models.ChatMessageLabel{},
+		&models.ChatNote{},
+		&models.NoteMention{},
 
 		// AI & Platforms
 		&models.AIConfiguration{},
@@ -76,18 +83,38 @@ func RunMigrations(db *gorm.DB) error {
 		// Communication
 		&models.BroadcastTemplate{},
 		&models.BroadcastHistory{},
+		&models.BroadcastVariant{},
+		&models.BroadcastRecipient{},
+		&models.ScheduledBroadcast{},
+		&models.WhatsAppTemplate{},
+		&models.WhatsAppConversation{},
+		&models.ContactConsent{},
+		&models.ConsentLog{},
+		&models.SuppressionEntry{},
 		&models.QuickReply{},
+		&models.CannedResponse{},
+		&models.Segment{},
+		&models.AutomationRule{},
+		&models.AutomationLog{},
 
 		// Settings & Analytics
 		&models.APISettings{},
+		&models.BusinessHours{},
+		&models.BusinessSchedule{},
+		&models.Holiday{},
 		&models.TokenBalance{},
 		&models.Analytics{},
 
 		// Vector Embeddings (with pgvector)
 		&models.KnowledgeBase{},
+		&models.KnowledgeDocument{},
 		&models.ChatHistory{},
 		&models.ProductEmbedding{},
 		&models.FAQEmbedding{},
+		&models.KnowledgeVersion{},
+		&models.KnowledgeImportJob{},
+		&models.EmbeddingModel{},
+		&models.EmbeddingCacheEntry{},
 	)
 
 	if err != nil {
@@ -95,6 +122,24 @@ func RunMigrations(db *gorm.DB) error {
 		return fmt.Errorf("migration failed: %w", err)
 	}
 
+	sizeEmbeddingColumns(db, dimensions)
+	createVectorIndexes(db, dimensions, index)
+	addSearchVectors(db)
+
+	if err := migrateLegacyChatLabels(db); err != nil {
+		return err
+	}
+	if backfillResponses {
+		if err := backfillFirstResponses(db); err != nil {
+			return err
+		}
+	}
+	if backfillManaged {
+		if err := backfillManagedProductEmbeddings(db); err != nil {
+			return err
+		}
+	}
+
 	log.Println("✅ Database migrations completed successfully")
 	return nil
 }
@@ -103,12 +148,16 @@ func RunMigrations(db *gorm.DB) error {
 func migrateWithoutVector(db *gorm.DB) error {
 	log.Println("⚠️  Migrating without vector features...")
 
+	backfillResponses := !db.Migrator().HasColumn(&models.ChatMessage{}, "first_responded_at")
 	err := db.AutoMigrate(
 		// Core entities
 		&models.Contact{},
 		&models.Product{},
 		&models.ChatLabel{},
 		&models.ChatMessage{},
+		&models.ChatMessageLabel{},
+		&models.ChatNote{},
+		&models.NoteMention{},
 
 		// AI & Platforms
 		&models.AIConfiguration{},
@@ -119,10 +168,25 @@ func migrateWithoutVector(db *gorm.DB) error {
 		// Communication
 		&models.BroadcastTemplate{},
 		&models.BroadcastHistory{},
+		&models.BroadcastVariant{},
+		&models.BroadcastRecipient{},
+		&models.ScheduledBroadcast{},
+		&models.WhatsAppTemplate{},
+		&models.WhatsAppConversation{},
+		&models.ContactConsent{},
+		&models.ConsentLog{},
+		&models.SuppressionEntry{},
 		&models.QuickReply{},
+		&models.CannedResponse{},
+		&models.Segment{},
+		&models.AutomationRule{},
+		&models.AutomationLog{},
 
 		// Settings & Analytics
 		&models.APISettings{},
+		&models.BusinessHours{},
+		&models.BusinessSchedule{},
+		&models.Holiday{},
 		&models.TokenBalance{},
 		&models.Analytics{},
 	)
@@ -131,6 +195,203 @@ func migrateWithoutVector(db *gorm.DB) error {
 		return fmt.Errorf("migration without vector failed: %w", err)
 	}
 
+	if err := migrateLegacyChatLabels(db); err != nil {
+		return err
+	}
+	if backfillResponses {
+		if err := backfillFirstResponses(db); err != nil {
+			return err
+		}
+	}
+
 	log.Println("✅ Database migrations completed (without vector features)")
 	return nil
 }
+
+// migrateLegacyChatLabels moves comma-separated chat_messages.labels into the
+// chat_message_labels join table. Unknown label IDs are dropped.
+func migrateLegacyChatLabels(db *gorm.DB) error {
+	var pending int64
+	if err := db.Model(&models.ChatMessage{}).Where("labels IS NOT NULL AND labels <> ''").Count(&pending).Error; err != nil {
+		return fmt.Errorf("failed to count legacy chat labels: %w", err)
+	}
+	if pending == 0 {
+		return nil
+	}
+
+	log.Printf("🏷️  Migrating legacy labels on %d chat messages...", pending)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO chat_message_labels (chat_message_id, chat_label_id, created_at)
+			SELECT m.id, l.id, NOW()
+			FROM chat_messages m
+			CROSS JOIN LATERAL unnest(string_to_array(m.labels, ',')) AS raw(label_id)
+			JOIN chat_labels l ON l.id::text = btrim(raw.label_id)
+			WHERE m.labels IS NOT NULL AND m.labels <> ''
+			ON CONFLICT DO NOTHING
+		`).Error
+		if err != nil {
+			return fmt.Errorf("failed to migrate legacy chat labels: %w", err)
+		}
+
+		if err := tx.Exec("UPDATE chat_messages SET labels = '' WHERE labels <> ''").Error; err != nil {
+			return fmt.Errorf("failed to clear legacy chat labels: %w", err)
+		}
+
+		log.Println("✅ Legacy chat labels migrated")
+		return nil
+	})
+}
+
+// backfillFirstResponses fills chat_messages.first_responded_at from the answers already
+// stored as separate rows, so messages answered before the column existed don't show as breached
+func backfillFirstResponses(db *gorm.DB) error {
+	log.Println("⏱️  Backfilling first response times...")
+
+	err := db.Exec(`
+		UPDATE chat_messages m
+		SET first_responded_at = (
+			SELECT MIN(r.created_at)
+			FROM chat_messages r
+			WHERE r.contact_id = m.contact_id
+			  AND r.status = 'Answered'
+			  AND r.response <> ''
+			  AND r.created_at >= m.created_at
+		)
+		WHERE m.sla_due_at IS NOT NULL AND m.first_responded_at IS NULL
+	`).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill first response times: %w", err)
+	}
+	return nil
+}
+
+// backfillManagedProductEmbeddings marks embeddings still holding the text the catalog sync
+// generated as managed. Anything edited by hand keeps managed = false and is left alone.
+func backfillManagedProductEmbeddings(db *gorm.DB) error {
+	err := db.Exec(`
+		UPDATE product_embeddings pe
+		SET managed = true
+		FROM products p
+		WHERE p.id = pe.product_id
+		  AND COALESCE(pe.features, '') = '' AND COALESCE(pe.use_cases, '') = ''
+		  AND pe.description IN (
+			p.name || ' (Kode: ' || p.code || ')',
+			p.name || ' (Kode: ' || p.code || '). ' || p.description
+		  )
+	`).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark catalog product embeddings as managed: %w", err)
+	}
+	return nil
+}
+
+// sizeEmbeddingColumns gives every vector column the configured dimensions. Columns
+// holding vectors of another size are left alone: they need `make reembed`.
+func sizeEmbeddingColumns(db *gorm.DB, dimensions int) {
+	want := fmt.Sprintf("vector(%d)", dimensions)
+
+	for _, col := range models.EmbeddingColumns {
+		var current string
+		err := db.Raw(`
+			SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+			WHERE attrelid = ?::regclass AND attname = ? AND NOT attisdropped
+		`, col.Table, col.Column).Scan(&current).Error
+		if err != nil {
+			log.Printf("⚠️  Could not read type of %s.%s: %v", col.Table, col.Column, err)
+			continue
+		}
+		if current == want {
+			continue
+		}
+
+		var mismatched int64
+		db.Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NOT NULL AND vector_dims(%s) <> ?", col.Table, col.Column, col.Column), dimensions).
+			Scan(&mismatched)
+		if mismatched > 0 {
+			log.Printf("⚠️  %s.%s is %s but EMBEDDING_DIMENSIONS is %d: run `make reembed` to re-embed %d rows",
+				col.Table, col.Column, current, dimensions, mismatched)
+			continue
+		}
+
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", col.Table, col.Column, want)).Error; err != nil {
+			log.Printf("⚠️  Could not resize %s.%s to %s: %v", col.Table, col.Column, want, err)
+			continue
+		}
+		log.Printf("📐 %s.%s resized to %s", col.Table, col.Column, want)
+	}
+}
+
+// createVectorIndexes adds missing HNSW indexes on searched vector columns, so similarity
+// searches do not scan whole tables, and drops those left on columns no longer searched.
+// Columns not yet sized to the configured dimensions are skipped; they are indexed after `make reembed`.
+func createVectorIndexes(db *gorm.DB, dimensions int, index config.VectorIndexConfig) {
+	for _, col := range models.EmbeddingColumns {
+		if col.Searched {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", col.IndexName())).Error; err != nil {
+			log.Printf("⚠️  Could not drop unused HNSW index on %s.%s: %v", col.Table, col.Column, err)
+		}
+	}
+
+	if dimensions > models.HNSWMaxDimensions {
+		log.Printf("⚠️  HNSW indexes support up to %d dimensions, vector searches will scan (EMBEDDING_DIMENSIONS is %d)",
+			models.HNSWMaxDimensions, dimensions)
+		return
+	}
+	want := fmt.Sprintf("vector(%d)", dimensions)
+
+	for _, col := range models.EmbeddingColumns {
+		if !col.Searched {
+			continue
+		}
+
+		var current string
+		db.Raw(`
+			SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+			WHERE attrelid = ?::regclass AND attname = ? AND NOT attisdropped
+		`, col.Table, col.Column).Scan(&current)
+		if current != want {
+			continue
+		}
+
+		var exists bool
+		db.Raw("SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE indexname = ?)", col.IndexName()).Scan(&exists)
+		if exists {
+			continue
+		}
+
+		log.Printf("🧭 Building HNSW index on %s.%s...", col.Table, col.Column)
+		if err := db.Exec(col.CreateIndexSQL(col.IndexName(), index.M, index.EFConstruction, false)).Error; err != nil {
+			log.Printf("⚠️  Could not create HNSW index on %s.%s (pgvector 0.5.0+ is required): %v", col.Table, col.Column, err)
+			continue
+		}
+	}
+}
+
+// addSearchVectors adds generated tsvector columns with GIN indexes for full-text search.
+// The simple configuration keeps product codes such as F003 intact and suits mixed languages.
+func addSearchVectors(db *gorm.DB) {
+	statements := []string{
+		`ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(tags, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(content, '')), 'C')
+		) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_knowledge_bases_search_vector ON knowledge_bases USING GIN (search_vector)`,
+		`ALTER TABLE faq_embeddings ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(question, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(answer, '')), 'C')
+		) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_faq_embeddings_search_vector ON faq_embeddings USING GIN (search_vector)`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("⚠️  Full-text search setup failed: %v", err)
+			return
+		}
+	}
+}
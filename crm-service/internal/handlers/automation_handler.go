@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type AutomationHandler struct {
+	service *services.AutomationService
+}
+
+func NewAutomationHandler(service *services.AutomationService) *AutomationHandler {
+	return &AutomationHandler{service: service}
+}
+
+// GetAll returns all automation rules in execution order
+func (h *AutomationHandler) GetAll(c *fiber.Ctx) error {
+	rules, err := h.service.GetAll()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rules})
+}
+
+// GetByID returns an automation rule by ID
+func (h *AutomationHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	rule, err := h.service.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Rule not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": rule})
+}
+
+// Create creates a new automation rule
+func (h *AutomationHandler) Create(c *fiber.Ctx) error {
+	var rule models.AutomationRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.service.Create(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": rule})
+}
+
+// Update updates an automation rule
+func (h *AutomationHandler) Update(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var rule models.AutomationRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	rule.ID = uint(id)
+	if err := h.service.Update(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": rule})
+}
+
+// Delete deletes an automation rule
+func (h *AutomationHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Rule deleted successfully"})
+}
+
+// DryRun evaluates rules against a hypothetical event without running any action
+func (h *AutomationHandler) DryRun(c *fiber.Ctx) error {
+	var req services.DryRunRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	evaluations, err := h.service.DryRun(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	matched := 0
+	for _, evaluation := range evaluations {
+		if evaluation.Matched {
+			matched++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    evaluations,
+		"matched": matched,
+	})
+}
+
+// GetLogs returns recent rule executions, optionally filtered by ?rule_id=
+func (h *AutomationHandler) GetLogs(c *fiber.Ctx) error {
+	ruleID, _ := strconv.Atoi(c.Query("rule_id", "0"))
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+
+	logs, err := h.service.GetLogs(uint(ruleID), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  logs,
+		"count": len(logs),
+	})
+}
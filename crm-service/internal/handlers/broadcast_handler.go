@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"bytes"
 	"divine-crm/internal/models"
 	"divine-crm/internal/services"
+	"encoding/csv"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"strconv"
+	"time"
 )
 
 type BroadcastHandler struct {
@@ -45,7 +49,7 @@ func (h *BroadcastHandler) CreateTemplate(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.CreateTemplate(&template); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(201).JSON(fiber.Map{"data": template})
@@ -64,7 +68,7 @@ func (h *BroadcastHandler) UpdateTemplate(c *fiber.Ctx) error {
 
 	template.ID = uint(id)
 	if err := h.service.UpdateTemplate(&template); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(fiber.Map{"data": template})
@@ -83,21 +87,184 @@ func (h *BroadcastHandler) DeleteTemplate(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Template deleted successfully"})
 }
 
+// PreviewTemplate renders a saved template or unsaved content against a contact
+func (h *BroadcastHandler) PreviewTemplate(c *fiber.Ctx) error {
+	var req services.PreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	message, err := h.service.PreviewTemplate(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": fiber.Map{"message": message}})
+}
+
 // Broadcasting
 func (h *BroadcastHandler) SendBroadcast(c *fiber.Ctx) error {
+	var req services.BroadcastRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	history, err := h.service.SendBroadcast(&req)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Broadcast started successfully",
+		"data":    history,
+	})
+}
+
+// GetReport returns campaign analytics with an ?interval=hour|day timeline
+func (h *BroadcastHandler) GetReport(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	report, err := h.service.GetReport(uint(id), c.Query("interval"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": report})
+}
+
+// ChooseWinner ends an A/B test with the variant in the body
+func (h *BroadcastHandler) ChooseWinner(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
 	var body struct {
-		TemplateID uint   `json:"template_id"`
-		SentBy     string `json:"sent_by"`
+		VariantID uint `json:"variant_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if body.VariantID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "variant_id is required"})
+	}
 
-	if err := h.service.SendBroadcast(body.TemplateID, body.SentBy); err != nil {
+	history, err := h.service.ChooseWinner(uint(id), body.VariantID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": history})
+}
+
+// RecordConversion credits the last broadcast a contact received with a conversion
+func (h *BroadcastHandler) RecordConversion(c *fiber.Ctx) error {
+	var body struct {
+		ContactID uint `json:"contact_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ContactID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "contact_id is required"})
+	}
+
+	attributed, err := h.service.RecordConversion(body.ContactID, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": fiber.Map{"attributed": attributed}})
+}
+
+// CountAudience previews how many contacts ?template_id=&segment_id= would reach
+func (h *BroadcastHandler) CountAudience(c *fiber.Ctx) error {
+	templateID, err := strconv.Atoi(c.Query("template_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template ID"})
+	}
+	segmentID, _ := strconv.Atoi(c.Query("segment_id", "0"))
+
+	audience, err := h.service.CountAudience(uint(templateID), uint(segmentID))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"count": audience.Count, "suppressed": audience.Suppressed})
+}
+
+// Schedules
+func (h *BroadcastHandler) GetSchedules(c *fiber.Ctx) error {
+	schedules, err := h.service.GetSchedules()
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	return c.JSON(fiber.Map{
+		"data":  schedules,
+		"count": len(schedules),
+	})
+}
+
+func (h *BroadcastHandler) GetScheduleByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	schedule, err := h.service.GetScheduleByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": schedule})
+}
+
+// ScheduleBroadcast queues a broadcast for a future time, optionally recurring
+func (h *BroadcastHandler) ScheduleBroadcast(c *fiber.Ctx) error {
+	var req services.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
 
-	return c.JSON(fiber.Map{"message": "Broadcast started successfully"})
+	schedule, err := h.service.ScheduleBroadcast(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": schedule})
+}
+
+// Reschedule replaces the timing (send_at, timezone, recurrence, cron_expr) of a schedule
+func (h *BroadcastHandler) Reschedule(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req services.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	schedule, err := h.service.Reschedule(uint(id), &req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": schedule})
+}
+
+func (h *BroadcastHandler) CancelSchedule(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.service.CancelSchedule(uint(id)); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Schedule cancelled successfully"})
 }
 
 // History
@@ -108,3 +275,96 @@ func (h *BroadcastHandler) GetHistory(c *fiber.Ctx) error {
 	}
 	return c.JSON(fiber.Map{"data": history})
 }
+
+// GetHistoryByID returns a broadcast with its per-status recipient counts
+func (h *BroadcastHandler) GetHistoryByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	progress, err := h.service.GetProgress(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Broadcast not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": progress})
+}
+
+// GetRecipients returns per-recipient outcomes of a broadcast, optionally by ?status=
+func (h *BroadcastHandler) GetRecipients(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	recipients, err := h.service.GetRecipients(uint(id), c.Query("status"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  recipients,
+		"count": len(recipients),
+	})
+}
+
+// ExportRecipients downloads per-recipient outcomes as CSV
+func (h *BroadcastHandler) ExportRecipients(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	recipients, err := h.service.GetRecipients(uint(id), c.Query("status"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"contact_id", "contact_name", "channel", "channel_id", "status", "attempts", "error", "sent_at", "delivered_at"})
+	for _, r := range recipients {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(r.ContactID), 10),
+			r.ContactName,
+			r.Channel,
+			r.ChannelID,
+			r.Status,
+			strconv.Itoa(r.Attempts),
+			r.Error,
+			formatExportTime(r.SentAt),
+			formatExportTime(r.DeliveredAt),
+		})
+	}
+	writer.Flush()
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=broadcast-%d-recipients.csv", id))
+	return c.Send(buf.Bytes())
+}
+
+// RetryFailed requeues the failed recipients of a broadcast
+func (h *BroadcastHandler) RetryFailed(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	requeued, err := h.service.RetryFailed(uint(id))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Failed recipients requeued",
+		"count":   requeued,
+	})
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+type BusinessHoursHandler struct {
+	service *services.BusinessHoursService
+}
+
+func NewBusinessHoursHandler(service *services.BusinessHoursService) *BusinessHoursHandler {
+	return &BusinessHoursHandler{service: service}
+}
+
+// GetSettings returns the business hours settings
+func (h *BusinessHoursHandler) GetSettings(c *fiber.Ctx) error {
+	settings, err := h.service.GetSettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": settings})
+}
+
+// UpdateSettings updates timezone, off-hours message and SLA target; omitted fields are kept
+func (h *BusinessHoursHandler) UpdateSettings(c *fiber.Ctx) error {
+	var req services.BusinessHoursUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	settings, err := h.service.UpdateSettings(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": settings})
+}
+
+// GetStatus returns whether the business is currently open
+func (h *BusinessHoursHandler) GetStatus(c *fiber.Ctx) error {
+	status, err := h.service.GetStatus(time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": status})
+}
+
+// GetSchedule returns the weekly schedule
+func (h *BusinessHoursHandler) GetSchedule(c *fiber.Ctx) error {
+	schedule, err := h.service.GetSchedule()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": schedule})
+}
+
+// UpdateSchedule replaces the weekly schedule
+func (h *BusinessHoursHandler) UpdateSchedule(c *fiber.Ctx) error {
+	var schedule []models.BusinessSchedule
+	if err := c.BodyParser(&schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.service.UpdateSchedule(schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": schedule})
+}
+
+// GetHolidays returns all holidays
+func (h *BusinessHoursHandler) GetHolidays(c *fiber.Ctx) error {
+	holidays, err := h.service.GetHolidays()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": holidays})
+}
+
+// CreateHoliday adds a holiday (date format: YYYY-MM-DD)
+func (h *BusinessHoursHandler) CreateHoliday(c *fiber.Ctx) error {
+	var body struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	date, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid date, expected YYYY-MM-DD"})
+	}
+
+	holiday := models.Holiday{
+		Date: date,
+		Name: body.Name,
+	}
+	if err := h.service.CreateHoliday(&holiday); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": holiday})
+}
+
+// DeleteHoliday removes a holiday
+func (h *BusinessHoursHandler) DeleteHoliday(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.service.DeleteHoliday(uint(id)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Holiday deleted successfully"})
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type CannedResponseHandler struct {
+	service *services.CannedResponseService
+}
+
+func NewCannedResponseHandler(service *services.CannedResponseService) *CannedResponseHandler {
+	return &CannedResponseHandler{service: service}
+}
+
+// cannedResponseRequest is a canned response plus whether it belongs to the shared library
+type cannedResponseRequest struct {
+	models.CannedResponse
+	Shared bool `json:"shared"`
+}
+
+// GetAll returns the shared library and the agent's own responses, optionally by ?category=
+func (h *CannedResponseHandler) GetAll(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	responses, err := h.service.GetAll(agentID, c.Query("category"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  responses,
+		"count": len(responses),
+	})
+}
+
+// Search returns composer suggestions for ?q= (shortcut prefix, title or content)
+func (h *CannedResponseHandler) Search(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	responses, err := h.service.Search(agentID, c.Query("q"), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  responses,
+		"count": len(responses),
+	})
+}
+
+// GetCategories returns the categories visible to the agent
+func (h *CannedResponseHandler) GetCategories(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	categories, err := h.service.GetCategories(agentID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": categories})
+}
+
+// Create saves a personal canned response, or a shared one with "shared": true
+func (h *CannedResponseHandler) Create(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req cannedResponseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	response := req.CannedResponse
+	response.ID = 0
+	if err := h.service.Create(&response, agentID, currentRole(c), req.Shared); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": response})
+}
+
+// Update edits a canned response the agent owns or, for admins, a shared one
+func (h *CannedResponseHandler) Update(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var response models.CannedResponse
+	if err := c.BodyParser(&response); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	response.ID = uint(id)
+	if err := h.service.Update(&response, agentID, currentRole(c)); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": response})
+}
+
+// Delete removes a canned response
+func (h *CannedResponseHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.service.Delete(uint(id), agentID, currentRole(c)); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Canned response deleted successfully"})
+}
+
+// Render fills in the variables for a contact (or chat) and product
+func (h *CannedResponseHandler) Render(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req services.RenderRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	rendered, err := h.service.Render(uint(id), agentID, &req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": rendered})
+}
+
+// currentRole returns the authenticated agent role set by AuthMiddleware
+func currentRole(c *fiber.Ctx) string {
+	role, _ := c.Locals("role").(string)
+	return role
+}
@@ -5,6 +5,7 @@ import (
 	"divine-crm/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"strconv"
+	"strings"
 )
 
 type ChatHandler struct {
@@ -15,8 +16,24 @@ func NewChatHandler(service *services.ChatService) *ChatHandler {
 	return &ChatHandler{service: service}
 }
 
-// GetAll returns all chat messages
+// GetAll returns all chat messages, optionally filtered by ?label_ids=1,2
 func (h *ChatHandler) GetAll(c *fiber.Ctx) error {
+	if raw := c.Query("label_ids"); raw != "" {
+		labelIDs, err := parseIDList(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid label_ids"})
+		}
+
+		messages, err := h.service.GetByLabels(labelIDs)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{
+			"data":  messages,
+			"count": len(messages),
+		})
+	}
+
 	messages, err := h.service.GetAll()
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
@@ -75,6 +92,18 @@ func (h *ChatHandler) GetResolved(c *fiber.Ctx) error {
 	})
 }
 
+// GetSLABreached returns unanswered chat messages past their SLA deadline
+func (h *ChatHandler) GetSLABreached(c *fiber.Ctx) error {
+	messages, err := h.service.GetSLABreached()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"data":  messages,
+		"count": len(messages),
+	})
+}
+
 // Create creates a new chat message
 func (h *ChatHandler) Create(c *fiber.Ctx) error {
 	var message models.ChatMessage
@@ -232,23 +261,76 @@ func (h *ChatHandler) GetStats(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"data": stats})
 }
 
-// AddLabel adds a label to a chat message
-func (h *ChatHandler) AddLabel(c *fiber.Ctx) error {
+// AddLabels adds one or more labels to a chat message
+func (h *ChatHandler) AddLabels(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
 	var body struct {
-		LabelID string `json:"label_id"`
+		LabelIDs []uint `json:"label_ids"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	if err := h.service.AddLabel(uint(id), body.LabelID); err != nil {
+	if err := h.service.AddLabels(uint(id), body.LabelIDs); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Labels added successfully"})
+}
+
+// RemoveLabel removes a label from a chat message
+func (h *ChatHandler) RemoveLabel(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	labelID, err := strconv.Atoi(c.Params("labelId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid label ID"})
+	}
+
+	if err := h.service.RemoveLabel(uint(id), uint(labelID)); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"message": "Label added successfully"})
+	return c.JSON(fiber.Map{"message": "Label removed successfully"})
+}
+
+// BulkApplyLabels adds labels to many chat messages at once
+func (h *ChatHandler) BulkApplyLabels(c *fiber.Ctx) error {
+	var body struct {
+		ChatIDs  []uint `json:"chat_ids"`
+		LabelIDs []uint `json:"label_ids"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.service.BulkApplyLabels(body.ChatIDs, body.LabelIDs); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Labels applied successfully"})
+}
+
+// parseIDList parses a comma-separated list of IDs such as "1,2,3"
+func parseIDList(raw string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
 }
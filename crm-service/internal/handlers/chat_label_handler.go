@@ -23,6 +23,15 @@ func (h *ChatLabelHandler) GetAll(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"data": labels})
 }
 
+// GetCounts returns the number of conversations per label
+func (h *ChatLabelHandler) GetCounts(c *fiber.Ctx) error {
+	counts, err := h.service.GetCounts()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": counts})
+}
+
 func (h *ChatLabelHandler) GetByID(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
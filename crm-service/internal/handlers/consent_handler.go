@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type ConsentHandler struct {
+	service *services.ConsentService
+}
+
+func NewConsentHandler(service *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{service: service}
+}
+
+// currentEmail returns the email of the authenticated user
+func currentEmail(c *fiber.Ctx) string {
+	email, _ := c.Locals("email").(string)
+	return email
+}
+
+// GetContactConsent returns the consent, suppression and change history of a contact
+func (h *ConsentHandler) GetContactConsent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	state, err := h.service.GetState(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": state})
+}
+
+func (h *ConsentHandler) UpdateContactConsent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req services.ConsentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.ChangedBy == "" {
+		req.ChangedBy = currentEmail(c)
+	}
+
+	state, err := h.service.SetConsent(uint(id), &req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": state})
+}
+
+// GetSuppressions lists the suppression list, filtered by ?channel= and ?q=
+func (h *ConsentHandler) GetSuppressions(c *fiber.Ctx) error {
+	entries, err := h.service.GetSuppressions(c.Query("channel"), c.Query("q"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": entries, "count": len(entries)})
+}
+
+func (h *ConsentHandler) AddSuppression(c *fiber.Ctx) error {
+	var entry models.SuppressionEntry
+	if err := c.BodyParser(&entry); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if entry.CreatedBy == "" {
+		entry.CreatedBy = currentEmail(c)
+	}
+
+	if err := h.service.AddSuppression(&entry); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": entry})
+}
+
+func (h *ConsentHandler) DeleteSuppression(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.service.DeleteSuppression(uint(id)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Suppression removed"})
+}
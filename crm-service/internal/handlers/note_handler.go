@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type NoteHandler struct {
+	service *services.NoteService
+}
+
+func NewNoteHandler(service *services.NoteService) *NoteHandler {
+	return &NoteHandler{service: service}
+}
+
+// currentAgentID returns the authenticated agent ID set by AuthMiddleware
+func currentAgentID(c *fiber.Ctx) (uint, bool) {
+	id, ok := c.Locals("userID").(uint)
+	return id, ok && id != 0
+}
+
+// GetByChat returns internal notes on a conversation
+func (h *NoteHandler) GetByChat(c *fiber.Ctx) error {
+	chatID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	notes, err := h.service.GetByChatID(uint(chatID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  notes,
+		"count": len(notes),
+	})
+}
+
+// Create adds an internal note to a conversation
+func (h *NoteHandler) Create(c *fiber.Ctx) error {
+	chatID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	note, err := h.service.Create(uint(chatID), agentID, body.Content)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": note})
+}
+
+// Delete removes an internal note
+func (h *NoteHandler) Delete(c *fiber.Ctx) error {
+	chatID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	noteID, err := strconv.Atoi(c.Params("noteId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid note ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.service.Delete(uint(chatID), uint(noteID), agentID); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Note deleted successfully"})
+}
+
+// GetMentions returns the mention notification feed for the current agent
+func (h *NoteHandler) GetMentions(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	mentions, unread, err := h.service.GetMentions(agentID, c.QueryBool("unread", false))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":   mentions,
+		"count":  len(mentions),
+		"unread": unread,
+	})
+}
+
+// MarkMentionRead marks a single mention as read
+func (h *NoteHandler) MarkMentionRead(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.service.MarkMentionRead(uint(id), agentID); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Mention marked as read"})
+}
+
+// MarkAllMentionsRead marks every mention of the current agent as read
+func (h *NoteHandler) MarkAllMentionsRead(c *fiber.Ctx) error {
+	agentID, ok := currentAgentID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	if err := h.service.MarkAllMentionsRead(agentID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "All mentions marked as read"})
+}
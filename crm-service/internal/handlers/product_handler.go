@@ -102,3 +102,19 @@ func (h *ProductHandler) Delete(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"message": "Product deleted successfully"})
 }
+
+// SyncEmbeddings embeds catalog products that have no or a stale semantic search embedding
+func (h *ProductHandler) SyncEmbeddings(c *fiber.Ctx) error {
+	synced, err := h.service.SyncAllEmbeddings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "synced": synced})
+	}
+
+	return c.JSON(fiber.Map{"message": "Product embeddings synced", "synced": synced})
+}
+
+// GetFailedEmbeddingSyncs lists products whose last embedding sync failed
+func (h *ProductHandler) GetFailedEmbeddingSyncs(c *fiber.Ctx) error {
+	failures := h.service.FailedEmbeddingSyncs()
+	return c.JSON(fiber.Map{"data": failures, "count": len(failures)})
+}
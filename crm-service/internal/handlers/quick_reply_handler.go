@@ -30,7 +30,7 @@ func (h *QuickReplyHandler) Create(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.Create(&reply); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(201).JSON(fiber.Map{"data": reply})
@@ -49,7 +49,7 @@ func (h *QuickReplyHandler) Update(c *fiber.Ctx) error {
 
 	reply.ID = uint(id)
 	if err := h.service.Update(&reply); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(fiber.Map{"data": reply})
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type SegmentHandler struct {
+	service *services.SegmentService
+}
+
+func NewSegmentHandler(service *services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{service: service}
+}
+
+func (h *SegmentHandler) GetAll(c *fiber.Ctx) error {
+	segments, err := h.service.GetAll()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": segments})
+}
+
+func (h *SegmentHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	segment, err := h.service.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Segment not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": segment})
+}
+
+func (h *SegmentHandler) Create(c *fiber.Ctx) error {
+	var segment models.Segment
+	if err := c.BodyParser(&segment); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.service.Create(&segment); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": segment})
+}
+
+func (h *SegmentHandler) Update(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var segment models.Segment
+	if err := c.BodyParser(&segment); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	segment.ID = uint(id)
+	if err := h.service.Update(&segment); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": segment})
+}
+
+func (h *SegmentHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Segment deleted successfully"})
+}
+
+// GetContacts returns the contacts currently in a segment
+func (h *SegmentHandler) GetContacts(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	contacts, err := h.service.GetContacts(uint(id))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  contacts,
+		"count": len(contacts),
+	})
+}
+
+// Preview counts the contacts matching unsaved filters
+func (h *SegmentHandler) Preview(c *fiber.Ctx) error {
+	var filter models.SegmentFilter
+	if err := c.BodyParser(&filter); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	preview, err := h.service.Preview(&filter)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": preview})
+}
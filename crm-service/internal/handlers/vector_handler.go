@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"bytes"
 	"divine-crm/internal/services"
 	"divine-crm/internal/utils"
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -63,6 +69,98 @@ func (h *VectorHandler) GetAllKnowledge(c *fiber.Ctx) error {
 	return utils.SuccessResponse(c, results)
 }
 
+// ==================== KNOWLEDGE DOCUMENTS ====================
+
+// IngestDocument accepts a multipart upload in "file" (text, Markdown, HTML or PDF) or
+// raw text in "content", and stores it as embedded chunks. Fields: title, source,
+// content_type, category, tags and document_id to replace a specific document.
+func (h *VectorHandler) IngestDocument(c *fiber.Ctx) error {
+	var req struct {
+		DocumentID  uint   `json:"document_id" form:"document_id"`
+		Title       string `json:"title" form:"title"`
+		Source      string `json:"source" form:"source"`
+		ContentType string `json:"content_type" form:"content_type"`
+		Category    string `json:"category" form:"category"`
+		Tags        string `json:"tags" form:"tags"`
+		Content     string `json:"content" form:"content"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	ingest := &services.DocumentIngestRequest{
+		DocumentID:  req.DocumentID,
+		Title:       req.Title,
+		Source:      req.Source,
+		ContentType: req.ContentType,
+		Category:    req.Category,
+		Tags:        req.Tags,
+		Data:        []byte(req.Content),
+	}
+
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return utils.BadRequestResponse(c, "Failed to read uploaded file")
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return utils.BadRequestResponse(c, "Failed to read uploaded file")
+		}
+		ingest.Filename = file.Filename
+		ingest.Data = data
+	} else if req.Content == "" {
+		return utils.BadRequestResponse(c, "Either a file or content is required")
+	}
+
+	document, err := h.service.IngestDocument(ingest)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": true, "data": document})
+}
+
+func (h *VectorHandler) GetAllDocuments(c *fiber.Ctx) error {
+	results, err := h.service.GetAllDocuments()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, results)
+}
+
+// GetDocument returns a document with its chunks
+func (h *VectorHandler) GetDocument(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	result, err := h.service.GetDocument(uint(id))
+	if err != nil {
+		return utils.NotFoundResponse(c, err.Error())
+	}
+
+	return utils.SuccessResponse(c, result)
+}
+
+func (h *VectorHandler) DeleteDocument(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	if err := h.service.DeleteDocument(uint(id)); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"message": "Document deleted successfully"})
+}
+
 // ==================== PRODUCT SEARCH ====================
 
 func (h *VectorHandler) SearchProducts(c *fiber.Ctx) error {
@@ -159,3 +257,309 @@ func (h *VectorHandler) GetSimilarConversations(c *fiber.Ctx) error {
 
 	return utils.SuccessResponse(c, results)
 }
+
+// ==================== RAG ====================
+
+// RetrieveContext shows what the AI would be given for a message, with similarity scores
+// and whether anything relevant was found
+func (h *VectorHandler) RetrieveContext(c *fiber.Ctx) error {
+	query := c.Query("q")
+	if query == "" {
+		return utils.BadRequestResponse(c, "Query parameter 'q' is required")
+	}
+
+	result, err := h.service.RetrieveContext(query)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, result)
+}
+
+// ==================== VECTOR INDEXES ====================
+
+func (h *VectorHandler) GetVectorIndexes(c *fiber.Ctx) error {
+	result, err := h.service.GetVectorIndexes()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, result)
+}
+
+// RebuildVectorIndexes starts rebuilding the HNSW indexes in the background (admins only)
+func (h *VectorHandler) RebuildVectorIndexes(c *fiber.Ctx) error {
+	if !strings.EqualFold(currentRole(c), "Admin") {
+		return utils.ForbiddenResponse(c, "Only admins can rebuild vector indexes")
+	}
+
+	if err := h.service.StartVectorIndexRebuild(); err != nil {
+		if errors.Is(err, services.ErrIndexRebuildRunning) {
+			return utils.ErrorResponse(c, fiber.StatusConflict, err.Error())
+		}
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Vector index rebuild started, check GET /vectors/indexes for progress",
+	})
+}
+
+// ==================== EDITING & VERSIONS ====================
+
+func (h *VectorHandler) UpdateKnowledge(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	var req services.KnowledgeUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	kb, err := h.service.UpdateKnowledge(uint(id), &req)
+	if err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, kb)
+}
+
+func (h *VectorHandler) DeleteKnowledge(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	if err := h.service.DeleteKnowledge(uint(id)); err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"message": "Knowledge deleted successfully"})
+}
+
+// SetKnowledgeCategoryActive activates or deactivates all knowledge of a category
+func (h *VectorHandler) SetKnowledgeCategoryActive(c *fiber.Ctx) error {
+	var req struct {
+		Category string `json:"category"`
+		Active   bool   `json:"active"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	updated, err := h.service.SetKnowledgeCategoryActive(req.Category, req.Active)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"updated": updated})
+}
+
+func (h *VectorHandler) UpdateFAQ(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	var req services.FAQUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	faq, err := h.service.UpdateFAQ(uint(id), &req)
+	if err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, faq)
+}
+
+func (h *VectorHandler) DeleteFAQ(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	if err := h.service.DeleteFAQ(uint(id)); err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"message": "FAQ deleted successfully"})
+}
+
+// SetFAQCategoryActive activates or deactivates all FAQs of a category
+func (h *VectorHandler) SetFAQCategoryActive(c *fiber.Ctx) error {
+	var req struct {
+		Category string `json:"category"`
+		Active   bool   `json:"active"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	updated, err := h.service.SetFAQCategoryActive(req.Category, req.Active)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"updated": updated})
+}
+
+func (h *VectorHandler) UpdateProductEmbedding(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	var req services.ProductEmbeddingUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	pe, err := h.service.UpdateProductEmbedding(uint(id), &req)
+	if err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, pe)
+}
+
+func (h *VectorHandler) DeleteProductEmbedding(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	if err := h.service.DeleteProductEmbedding(uint(id)); err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, fiber.Map{"message": "Product embedding deleted successfully"})
+}
+
+// GetVersions lists earlier versions of an entry; :type is knowledge, faq or product
+func (h *VectorHandler) GetVersions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	versions, err := h.service.GetVersions(c.Params("type"), uint(id))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, versions)
+}
+
+// Rollback restores an entry to the version in the body; :type is knowledge, faq or product
+func (h *VectorHandler) Rollback(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body")
+	}
+
+	entry, err := h.service.Rollback(c.Params("type"), uint(id), req.Version)
+	if err != nil {
+		return entryErrorResponse(c, err)
+	}
+
+	return utils.SuccessResponse(c, entry)
+}
+
+// entryErrorResponse maps knowledge entry errors to 404, 409 or 400
+func entryErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrEntryNotFound) || errors.Is(err, services.ErrVersionNotFound) {
+		return utils.NotFoundResponse(c, err.Error())
+	}
+	if errors.Is(err, services.ErrVersionConflict) {
+		return utils.ErrorResponse(c, fiber.StatusConflict, err.Error())
+	}
+	return utils.BadRequestResponse(c, err.Error())
+}
+
+// ==================== IMPORT & EXPORT ====================
+
+// ImportKnowledge accepts a JSONL or CSV upload in "file" and imports it in the background.
+// The format comes from the "format" field or the file extension.
+func (h *VectorHandler) ImportKnowledge(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return utils.BadRequestResponse(c, "A JSONL or CSV file is required")
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return utils.BadRequestResponse(c, "Failed to read uploaded file")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Failed to read uploaded file")
+	}
+
+	job, err := h.service.StartImport(file.Filename, c.FormValue("format"), data)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"success": true, "data": job})
+}
+
+func (h *VectorHandler) GetImportJobs(c *fiber.Ctx) error {
+	jobs, err := h.service.GetImportJobs()
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return utils.SuccessResponse(c, jobs)
+}
+
+// GetImportJob reports the progress of an import
+func (h *VectorHandler) GetImportJob(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid ID")
+	}
+
+	job, err := h.service.GetImportJob(uint(id))
+	if err != nil {
+		return utils.NotFoundResponse(c, err.Error())
+	}
+
+	return utils.SuccessResponse(c, job)
+}
+
+// ExportKnowledge downloads all knowledge and FAQs, without document chunks; ?format=jsonl|csv, ?vectors=true adds embeddings
+func (h *VectorHandler) ExportKnowledge(c *fiber.Ctx) error {
+	format, err := services.DetectTransferFormat(c.Query("format", services.TransferJSONL), "")
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := h.service.ExportKnowledge(&buf, format, c.QueryBool("vectors")); err != nil {
+		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	contentType := "application/x-ndjson"
+	if format == services.TransferCSV {
+		contentType = "text/csv"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="knowledge-%s.%s"`, time.Now().Format("20060102"), format))
+	return c.Send(buf.Bytes())
+}
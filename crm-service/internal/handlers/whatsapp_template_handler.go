@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type WhatsAppTemplateHandler struct {
+	service *services.WhatsAppTemplateService
+}
+
+func NewWhatsAppTemplateHandler(service *services.WhatsAppTemplateService) *WhatsAppTemplateHandler {
+	return &WhatsAppTemplateHandler{service: service}
+}
+
+func (h *WhatsAppTemplateHandler) GetAll(c *fiber.Ctx) error {
+	templates, err := h.service.GetAll(c.Query("status"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": templates, "count": len(templates)})
+}
+
+func (h *WhatsAppTemplateHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	template, err := h.service.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Template not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": template})
+}
+
+// Sync pulls the templates of the WhatsApp Business account from Meta
+func (h *WhatsAppTemplateHandler) Sync(c *fiber.Ctx) error {
+	result, err := h.service.Sync()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": result})
+}
+
+func (h *WhatsAppTemplateHandler) UpdateParameters(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req struct {
+		Parameters []models.TemplateParameter `json:"parameters"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	template, err := h.service.UpdateParameters(uint(id), req.Parameters)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": template})
+}
+
+func (h *WhatsAppTemplateHandler) Preview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req struct {
+		ContactID uint `json:"contact_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	preview, err := h.service.Preview(uint(id), req.ContactID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": preview})
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// Automation triggers
+const (
+	TriggerMessageReceived = "message_received"
+	TriggerContactCreated  = "contact_created"
+	TriggerChatIdle        = "chat_idle"
+	TriggerLabelAdded      = "label_added"
+)
+
+// AutomationRule runs its actions when the trigger fires and all conditions match
+type AutomationRule struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	Name           string          `gorm:"size:255;not null" json:"name"`
+	Description    string          `gorm:"type:text" json:"description"`
+	Trigger        string          `gorm:"size:50;not null;index" json:"trigger"` // message_received, contact_created, chat_idle, label_added
+	Conditions     []RuleCondition `gorm:"type:text;serializer:json" json:"conditions"`
+	Actions        []RuleAction    `gorm:"type:text;serializer:json" json:"actions"`
+	Priority       int             `gorm:"default:0" json:"priority"`            // Higher runs first
+	StopProcessing bool            `gorm:"default:false" json:"stop_processing"` // Skip lower priority rules when matched
+	Active         bool            `gorm:"default:true" json:"active"`
+	RunCount       int             `gorm:"default:0" json:"run_count"`
+	LastRunAt      *time.Time      `json:"last_run_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// RuleCondition compares one field of the event against a value.
+// Fields: channel, temperature, contact_status, message, label, idle_minutes, time, day_of_week, business_hours
+// Operators: equals, not_equals, in, contains, regex, gte, lte, between
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// RuleAction is executed when a rule matches.
+// Types: reply, add_label, assign, set_temperature, set_status, webhook
+type RuleAction struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// AutomationLog records every rule execution
+type AutomationLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RuleID        uint      `gorm:"not null;index" json:"rule_id"`
+	Trigger       string    `gorm:"size:50" json:"trigger"`
+	ContactID     uint      `gorm:"index" json:"contact_id"`
+	ChatMessageID uint      `gorm:"index" json:"chat_message_id"`
+	Actions       string    `gorm:"type:text" json:"actions"` // Summary of executed actions
+	Error         string    `gorm:"type:text" json:"error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
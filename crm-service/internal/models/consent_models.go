@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// Marketing consent statuses. A contact without a consent record has not said either way.
+const (
+	ConsentOptedIn  = "opted_in"
+	ConsentOptedOut = "opted_out"
+)
+
+// ContactConsent is the current marketing consent of a contact on one channel
+type ContactConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ContactID uint      `gorm:"not null;uniqueIndex:idx_contact_consent_channel" json:"contact_id"`
+	Channel   string    `gorm:"size:50;not null;uniqueIndex:idx_contact_consent_channel" json:"channel"`
+	Status    string    `gorm:"size:20;not null;index" json:"status"` // opted_in, opted_out
+	Source    string    `gorm:"size:50" json:"source"`                // keyword, agent, api, import
+	Detail    string    `gorm:"type:text" json:"detail"`              // Keyword received, form name, agent note
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsentLog keeps every consent change as evidence for anti-spam obligations
+type ConsentLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ContactID uint      `gorm:"not null;index" json:"contact_id"`
+	Channel   string    `gorm:"size:50" json:"channel"`
+	Status    string    `gorm:"size:20" json:"status"`
+	Source    string    `gorm:"size:50" json:"source"`
+	Detail    string    `gorm:"type:text" json:"detail"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SuppressionReasonOptedOut marks entries added by a consent opt-out; opting back in lifts only those
+const SuppressionReasonOptedOut = "Opted out"
+
+// SuppressionEntry blocks broadcasts to a channel address, whether or not a contact exists for it.
+// Entries outlive contacts, so a deleted and re-created contact stays unsubscribed.
+type SuppressionEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Channel   string    `gorm:"size:50;not null;uniqueIndex:idx_suppression_channel_id" json:"channel"`
+	ChannelID string    `gorm:"size:255;not null;uniqueIndex:idx_suppression_channel_id" json:"channel_id"`
+	Reason    string    `gorm:"type:text" json:"reason"`
+	Source    string    `gorm:"size:50" json:"source"` // keyword, agent, import
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
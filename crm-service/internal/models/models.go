@@ -7,20 +7,26 @@ import (
 // ==================== CONTACTS ====================
 
 type Contact struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	Code          string    `json:"code" gorm:"unique;not null"`
-	Channel       string    `json:"channel"`    // WhatsApp, Telegram, Instagram
-	ChannelID     string    `json:"channel_id"` // Phone number, username, etc
-	Name          string    `json:"name"`
-	ContactStatus string    `json:"contact_status"` // Leads, Contact
-	Temperature   string    `json:"temperature"`    // Cold, Warm, Hot
-	FirstContact  time.Time `json:"first_contact"`
-	LastContact   time.Time `json:"last_contact"`
-	LastAgent     string    `json:"last_agent"`
-	LastAgentType string    `json:"last_agent_type"` // AI, Human
-	Notes         string    `json:"notes" gorm:"type:text"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	Code          string            `json:"code" gorm:"unique;not null"`
+	Channel       string            `json:"channel"`    // WhatsApp, Telegram, Instagram
+	ChannelID     string            `json:"channel_id"` // Phone number, username, etc
+	Name          string            `json:"name"`
+	ContactStatus string            `json:"contact_status"` // Leads, Contact
+	Temperature   string            `json:"temperature"`    // Cold, Warm, Hot
+	FirstContact  time.Time         `json:"first_contact"`
+	LastContact   time.Time         `json:"last_contact"`
+	LastInboundAt *time.Time        `json:"last_inbound_at"` // Last message from the contact, opens the WhatsApp 24h window
+	LastAgent     string            `json:"last_agent"`
+	LastAgentType string            `json:"last_agent_type"` // AI, Human
+	Notes         string            `json:"notes" gorm:"type:text"`
+	Attributes    map[string]string `json:"attributes" gorm:"type:text;serializer:json"` // Custom fields for templates and segments
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+
+	// Filled in by ContactService; stored in contact_consents and suppression_entries
+	Consent    *ContactConsent `json:"consent" gorm:"-"`
+	Suppressed bool            `json:"suppressed" gorm:"-"`
 }
 
 // ==================== PRODUCTS ====================
@@ -48,23 +54,62 @@ type ChatLabel struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// ChatMessageLabel joins conversations and labels
+type ChatMessageLabel struct {
+	ChatMessageID uint      `json:"chat_message_id" gorm:"primaryKey"`
+	ChatLabelID   uint      `json:"chat_label_id" gorm:"primaryKey;index"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 // ==================== CHAT MESSAGES ====================
 
 type ChatMessage struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	ContactID     uint      `json:"contact_id"`
-	Contact       Contact   `json:"contact" gorm:"foreignKey:ContactID"`
-	ContactName   string    `json:"contact_name"`
-	Message       string    `json:"message" gorm:"type:text"`
-	Response      string    `json:"response" gorm:"type:text"`
-	Status        string    `json:"status"` // Unassigned, Pending, Assigned, Resolved
-	AssignedTo    string    `json:"assigned_to"`
-	AssignedAgent string    `json:"assigned_agent"`
-	Channel       string    `json:"channel"`
-	Labels        string    `json:"labels"` // Comma-separated label IDs
-	TokensUsed    int       `json:"tokens_used"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                 uint        `json:"id" gorm:"primaryKey"`
+	ContactID          uint        `json:"contact_id"`
+	Contact            Contact     `json:"contact" gorm:"foreignKey:ContactID"`
+	ContactName        string      `json:"contact_name"`
+	Message            string      `json:"message" gorm:"type:text"`
+	Response           string      `json:"response" gorm:"type:text"`
+	Status             string      `json:"status"` // Unassigned, Pending, Assigned, Resolved
+	AssignedTo         string      `json:"assigned_to"`
+	AssignedAgent      string      `json:"assigned_agent"`
+	Channel            string      `json:"channel"`
+	ChatLabels         []ChatLabel `json:"labels" gorm:"many2many:chat_message_labels"`
+	LegacyLabels       string      `json:"-" gorm:"column:labels"` // Deprecated: comma-separated label IDs, migrated to chat_message_labels
+	TokensUsed         int         `json:"tokens_used"`
+	SLADueAt           *time.Time  `json:"sla_due_at"`                        // First response deadline, counted in business hours
+	FirstRespondedAt   *time.Time  `json:"first_responded_at"`                // When the contact first got an answer after this message
+	BroadcastHistoryID *uint       `json:"broadcast_history_id" gorm:"index"` // Broadcast this inbound message replies to
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+// ==================== INTERNAL NOTES ====================
+
+// ChatNote is an agent-only annotation on a conversation; it is never sent to the customer
+type ChatNote struct {
+	ID            uint          `json:"id" gorm:"primaryKey"`
+	ChatMessageID uint          `json:"chat_message_id" gorm:"not null;index"`
+	ContactID     uint          `json:"contact_id" gorm:"index"`
+	AuthorID      uint          `json:"author_id"`
+	AuthorName    string        `json:"author_name"`
+	Content       string        `json:"content" gorm:"type:text;not null"`
+	Mentions      []NoteMention `json:"mentions" gorm:"foreignKey:NoteID;constraint:OnDelete:CASCADE"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// NoteMention notifies a human agent that they were @mentioned in a note
+type NoteMention struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	NoteID        uint       `json:"note_id" gorm:"not null;index"`
+	Note          *ChatNote  `json:"note,omitempty" gorm:"foreignKey:NoteID"`
+	AgentID       uint       `json:"agent_id" gorm:"not null;index"`
+	ChatMessageID uint       `json:"chat_message_id"`
+	MentionedBy   string     `json:"mentioned_by"`
+	Read          bool       `json:"read" gorm:"default:false"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
 
 // ==================== AI CONFIGURATION ====================
@@ -126,14 +171,15 @@ type HumanAgent struct {
 // ==================== BROADCAST TEMPLATE ====================
 
 type BroadcastTemplate struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name" gorm:"not null"`
-	Content   string    `json:"content" gorm:"type:text"`
-	Channel   string    `json:"channel"` // WhatsApp, Instagram, Telegram, All
-	CreatedBy string    `json:"created_by"`
-	Active    bool      `json:"active" gorm:"default:true"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	Name               string    `json:"name" gorm:"not null"`
+	Content            string    `json:"content" gorm:"type:text"`
+	Channel            string    `json:"channel"`              // WhatsApp, Instagram, Telegram, All
+	WhatsAppTemplateID *uint     `json:"whatsapp_template_id"` // Approved template for contacts outside the 24h window
+	CreatedBy          string    `json:"created_by"`
+	Active             bool      `json:"active" gorm:"default:true"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // ==================== BROADCAST HISTORY ====================
@@ -142,24 +188,133 @@ type BroadcastHistory struct {
 	ID          uint              `json:"id" gorm:"primaryKey"`
 	TemplateID  uint              `json:"template_id"`
 	Template    BroadcastTemplate `json:"template" gorm:"foreignKey:TemplateID"`
-	SentTo      int               `json:"sent_to"` // Total recipients
+	SegmentID   *uint             `json:"segment_id"` // nil = everyone on the template channel
+	SentTo      int               `json:"sent_to"`    // Total recipients
 	Successful  int               `json:"successful"`
 	Failed      int               `json:"failed"`
-	Status      string            `json:"status"` // Pending, Processing, Completed, Failed
+	Suppressed  int               `json:"suppressed"` // Opted-out or suppressed contacts left out
+	Status      string            `json:"status"`     // Pending, Processing, Completed, Failed
 	SentBy      string            `json:"sent_by"`
 	CreatedAt   time.Time         `json:"created_at"`
 	CompletedAt *time.Time        `json:"completed_at"`
+
+	// A/B testing: with variants, recipients are split randomly between templates
+	Variants        []BroadcastVariant `json:"variants,omitempty" gorm:"foreignKey:HistoryID"`
+	AutoWinner      bool               `json:"auto_winner"`      // Send the best variant to the rest of the audience
+	TestPercentage  int                `json:"test_percentage"`  // Share of the audience in the test when AutoWinner
+	WinnerMetric    string             `json:"winner_metric"`    // reply_rate, delivery_rate, conversion_rate
+	WinnerDecideAt  *time.Time         `json:"winner_decide_at"` // When the winner is picked
+	WinnerVariantID *uint              `json:"winner_variant_id"`
+}
+
+// ==================== BROADCAST VARIANT ====================
+
+// BroadcastVariant is one template of an A/B tested broadcast with its results
+type BroadcastVariant struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	HistoryID      uint              `json:"history_id" gorm:"not null;index"`
+	TemplateID     uint              `json:"template_id" gorm:"not null"`
+	Template       BroadcastTemplate `json:"template" gorm:"foreignKey:TemplateID"`
+	Label          string            `json:"label"`      // A, B, C, ...
+	Percentage     int               `json:"percentage"` // Share of the test audience
+	Recipients     int               `json:"recipients"`
+	Sent           int               `json:"sent"`
+	Delivered      int               `json:"delivered"`
+	Failed         int               `json:"failed"`
+	Replied        int               `json:"replied"`
+	Converted      int               `json:"converted"`
+	DeliveryRate   float64           `json:"delivery_rate"`   // % of sent messages delivered
+	ReplyRate      float64           `json:"reply_rate"`      // % of sent messages replied to
+	ConversionRate float64           `json:"conversion_rate"` // % of sent messages followed by a conversion
+	IsWinner       bool              `json:"is_winner"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// ==================== BROADCAST RECIPIENT ====================
+
+// BroadcastRecipient tracks delivery of one broadcast message to one contact
+type BroadcastRecipient struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	HistoryID     uint       `json:"history_id" gorm:"not null;index"`
+	ContactID     uint       `json:"contact_id" gorm:"index"`
+	ContactName   string     `json:"contact_name"`
+	Channel       string     `json:"channel"`
+	ChannelID     string     `json:"channel_id"`
+	TemplateID    uint       `json:"template_id"`             // Template the message was rendered from
+	VariantID     *uint      `json:"variant_id" gorm:"index"` // A/B test variant
+	Message       string     `json:"message" gorm:"type:text"`
+	SendMode      string     `json:"send_mode"`           // text, or template when outside the WhatsApp 24h window
+	Status        string     `json:"status" gorm:"index"` // Pending, Sending, Sent, Delivered, Failed
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	Error         string     `json:"error" gorm:"type:text"`
+	ExternalID    string     `json:"external_id" gorm:"index"` // Platform message ID, used for delivery receipts
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	ClaimedAt     *time.Time `json:"claimed_at"` // When a worker took the recipient for sending
+	SentAt        *time.Time `json:"sent_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	ReadAt        *time.Time `json:"read_at"`
+	RepliedAt     *time.Time `json:"replied_at"`   // First reply from the contact after the broadcast
+	ConvertedAt   *time.Time `json:"converted_at"` // Contact became a customer after the broadcast
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ==================== SCHEDULED BROADCAST ====================
+
+// ScheduledBroadcast is a broadcast queued for a future time, optionally recurring
+type ScheduledBroadcast struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	TemplateID    uint              `json:"template_id" gorm:"not null"`
+	Template      BroadcastTemplate `json:"template" gorm:"foreignKey:TemplateID"`
+	SegmentID     *uint             `json:"segment_id"`
+	Timezone      string            `json:"timezone" gorm:"default:'Asia/Jakarta'"`
+	Recurrence    string            `json:"recurrence"` // "" (once), daily, weekly, cron
+	CronExpr      string            `json:"cron_expr"`
+	NextRunAt     *time.Time        `json:"next_run_at" gorm:"index"`
+	LastRunAt     *time.Time        `json:"last_run_at"`
+	LastHistoryID *uint             `json:"last_history_id"`
+	RunCount      int               `json:"run_count" gorm:"default:0"`
+	Status        string            `json:"status" gorm:"index"` // Scheduled, Running, Completed, Cancelled, Failed
+	ClaimedAt     *time.Time        `json:"claimed_at"`          // When a worker started running it
+	LastError     string            `json:"last_error" gorm:"type:text"`
+	SentBy        string            `json:"sent_by"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
 }
 
 // ==================== QUICK REPLY ====================
 
 type QuickReply struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Trigger   string    `json:"trigger" gorm:"unique;not null"` // Keyword to trigger
-	Response  string    `json:"response" gorm:"type:text"`
-	Active    bool      `json:"active" gorm:"default:true"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	Trigger             string     `json:"trigger" gorm:"unique;not null"` // Keyword to trigger
+	Response            string     `json:"response" gorm:"type:text"`
+	MatchMode           string     `json:"match_mode" gorm:"default:'word'"` // exact, prefix, word, regex, semantic
+	Channel             string     `json:"channel"`                          // WhatsApp, Instagram, Telegram; empty or All for every channel
+	Priority            int        `json:"priority" gorm:"default:0"`        // Higher is evaluated first
+	SimilarityThreshold float64    `json:"similarity_threshold" gorm:"default:0.85"`
+	Embedding           []float32  `json:"-" gorm:"type:text;serializer:json"` // Trigger embedding for semantic mode
+	HitCount            int        `json:"hit_count" gorm:"default:0"`
+	LastHitAt           *time.Time `json:"last_hit_at"`
+	Active              bool       `json:"active" gorm:"default:true"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// ==================== CANNED RESPONSES ====================
+
+// CannedResponse is a saved reply agents insert manually from the composer.
+// Responses without an owner are shared with every agent.
+type CannedResponse struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Shortcut   string    `json:"shortcut" gorm:"size:100;not null;index"` // e.g. /harga
+	Title      string    `json:"title"`
+	Content    string    `json:"content" gorm:"type:text;not null"` // Supports {name}, {product.price}, etc
+	Category   string    `json:"category" gorm:"index"`
+	OwnerID    *uint     `json:"owner_id" gorm:"index"` // nil = shared library
+	UsageCount int       `json:"usage_count" gorm:"default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // ==================== API SETTINGS ====================
@@ -173,6 +328,36 @@ type APISettings struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// ==================== BUSINESS HOURS ====================
+
+type BusinessHours struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	Timezone        string    `json:"timezone" gorm:"default:'Asia/Jakarta'"` // IANA name, e.g. Asia/Jakarta
+	Enabled         bool      `json:"enabled" gorm:"default:false"`
+	OffHoursMessage string    `json:"off_hours_message" gorm:"type:text"` // Supports {name} and {next_open}
+	SLAMinutes      int       `json:"sla_minutes" gorm:"default:60"`      // First response target in business minutes
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type BusinessSchedule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	DayOfWeek int       `json:"day_of_week" gorm:"uniqueIndex;not null"` // 0 = Sunday ... 6 = Saturday
+	OpenTime  string    `json:"open_time"`                               // HH:MM
+	CloseTime string    `json:"close_time"`                              // HH:MM
+	Closed    bool      `json:"closed" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Holiday struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Date      time.Time `json:"date" gorm:"type:date;uniqueIndex;not null"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // ==================== TOKEN BALANCE ====================
 
 type TokenBalance struct {
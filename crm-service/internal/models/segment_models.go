@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Segment is a saved audience of contacts defined by filters
+type Segment struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	Name        string        `gorm:"size:255;not null;unique" json:"name"`
+	Description string        `gorm:"type:text" json:"description"`
+	Filters     SegmentFilter `gorm:"type:text;serializer:json" json:"filters"`
+	CreatedBy   string        `json:"created_by"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// SegmentFilter narrows contacts; empty fields match everything and all set fields must match
+type SegmentFilter struct {
+	Channels          []string          `json:"channels,omitempty"`     // WhatsApp, Instagram, Telegram
+	Statuses          []string          `json:"statuses,omitempty"`     // Leads, Contact
+	Temperatures      []string          `json:"temperatures,omitempty"` // Cold, Warm, Hot
+	LabelIDs          []uint            `json:"label_ids,omitempty"`    // Contact has a chat with any of these labels
+	LastContactAfter  *time.Time        `json:"last_contact_after,omitempty"`
+	LastContactBefore *time.Time        `json:"last_contact_before,omitempty"`
+	LastContactDays   int               `json:"last_contact_days,omitempty"` // Contacted within the last N days
+	Attributes        []AttributeFilter `json:"attributes,omitempty"`
+
+	// Marketable leaves out opted-out and suppressed contacts; set for broadcasts, never stored
+	Marketable bool `json:"-"`
+}
+
+// AttributeFilter matches a custom contact attribute.
+// Operators: equals, not_equals, contains, exists, not_exists
+type AttributeFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
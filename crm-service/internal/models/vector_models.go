@@ -1,10 +1,13 @@
 package models
 
 import (
+	"fmt"
 	"github.com/pgvector/pgvector-go"
 	"time"
 )
 
+// Vector columns are created with EMBEDDING_DIMENSIONS dimensions by the migrations
+
 // KnowledgeBase stores company knowledge with vector embeddings
 type KnowledgeBase struct {
 	ID        uint            `gorm:"primaryKey" json:"id"`
@@ -13,10 +16,34 @@ type KnowledgeBase struct {
 	Category  string          `gorm:"size:100" json:"category"`
 	Tags      string          `gorm:"size:255" json:"tags"`
 	Source    string          `gorm:"size:255" json:"source"`
-	Embedding pgvector.Vector `gorm:"type:vector(1536)" json:"-"`
+	Embedding pgvector.Vector `gorm:"type:vector" json:"-"`
 	Active    bool            `gorm:"default:true" json:"active"`
+	Version   int             `gorm:"default:1" json:"version"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Set on chunks of an ingested document
+	DocumentID *uint `gorm:"index" json:"document_id,omitempty"`
+	ChunkIndex int   `gorm:"default:0" json:"chunk_index"`
+	// Filled by searches, not stored
+	Similarity float64 `gorm:"->;-:migration" json:"similarity,omitempty"` // Cosine similarity to the query
+	TextRank   float64 `gorm:"->;-:migration" json:"text_rank,omitempty"`  // Full-text rank
+	Score      float64 `gorm:"-" json:"score,omitempty"`                   // Relevance after fusion or reranking
+}
+
+// KnowledgeDocument is an uploaded document whose text is stored as KnowledgeBase chunks
+type KnowledgeDocument struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Source      string    `gorm:"size:255;uniqueIndex" json:"source"` // File name or URL, identifies the document on re-ingestion
+	ContentType string    `gorm:"size:50" json:"content_type"`        // text, markdown, html, pdf
+	Category    string    `gorm:"size:100" json:"category"`
+	Tags        string    `gorm:"size:255" json:"tags"`
+	Checksum    string    `gorm:"size:64" json:"checksum"` // SHA-256 of the extracted text
+	Characters  int       `json:"characters"`
+	ChunkCount  int       `json:"chunk_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // ChatHistory stores chat messages with embeddings for RAG
@@ -25,11 +52,13 @@ type ChatHistory struct {
 	ContactID         uint            `gorm:"not null;index" json:"contact_id"`
 	UserMessage       string          `gorm:"type:text;not null" json:"user_message"`
 	AIResponse        string          `gorm:"type:text" json:"ai_response"`
-	MessageEmbedding  pgvector.Vector `gorm:"type:vector(1536)" json:"-"`
-	ResponseEmbedding pgvector.Vector `gorm:"type:vector(1536)" json:"-"`
+	MessageEmbedding  pgvector.Vector `gorm:"type:vector" json:"-"`
+	ResponseEmbedding pgvector.Vector `gorm:"type:vector" json:"-"`
 	Sentiment         string          `gorm:"size:50" json:"sentiment"` // positive, negative, neutral
 	Intent            string          `gorm:"size:100" json:"intent"`   // inquiry, complaint, order, etc
 	CreatedAt         time.Time       `json:"created_at"`
+
+	Similarity float64 `gorm:"->;-:migration" json:"similarity,omitempty"` // Filled by searches, not stored
 }
 
 // ProductEmbedding stores product info with embeddings for semantic search
@@ -39,9 +68,15 @@ type ProductEmbedding struct {
 	Description string          `gorm:"type:text" json:"description"`
 	Features    string          `gorm:"type:text" json:"features"`
 	UseCases    string          `gorm:"type:text" json:"use_cases"`
-	Embedding   pgvector.Vector `gorm:"type:vector(1536)" json:"-"`
+	Embedding   pgvector.Vector `gorm:"type:vector" json:"-"`
+	Managed     bool            `gorm:"not null;default:false" json:"managed"` // Generated from the catalog and re-synced on product changes; cleared by manual edits
+	Version     int             `gorm:"default:1" json:"version"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+
+	// Filled by searches, not stored
+	Similarity float64  `gorm:"->;-:migration" json:"similarity,omitempty"`
+	Product    *Product `gorm:"-" json:"product,omitempty"` // Live catalog data: price, stock
 }
 
 // FAQEmbedding stores FAQs with embeddings
@@ -50,9 +85,141 @@ type FAQEmbedding struct {
 	Question  string          `gorm:"type:text;not null" json:"question"`
 	Answer    string          `gorm:"type:text;not null" json:"answer"`
 	Category  string          `gorm:"size:100" json:"category"`
-	Embedding pgvector.Vector `gorm:"type:vector(1536)" json:"-"`
+	Embedding pgvector.Vector `gorm:"type:vector" json:"-"`
 	HitCount  int             `gorm:"default:0" json:"hit_count"` // Track usage
 	Active    bool            `gorm:"default:true" json:"active"`
+	Version   int             `gorm:"default:1" json:"version"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Filled by searches, not stored
+	Similarity float64 `gorm:"->;-:migration" json:"similarity,omitempty"` // Cosine similarity to the query
+	TextRank   float64 `gorm:"->;-:migration" json:"text_rank,omitempty"`  // Full-text rank
+	Score      float64 `gorm:"-" json:"score,omitempty"`                   // Relevance after fusion or reranking
+}
+
+// KnowledgeImportJob tracks a bulk knowledge and FAQ import embedded in the background
+type KnowledgeImportJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Filename   string     `gorm:"size:255" json:"filename"`
+	Format     string     `gorm:"size:10" json:"format"`                   // jsonl or csv
+	Status     string     `gorm:"size:20;default:'pending'" json:"status"` // pending, running, completed, failed
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Imported   int        `json:"imported"`
+	Skipped    int        `json:"skipped"` // Already present
+	Failed     int        `json:"failed"`
+	Errors     string     `gorm:"type:text" json:"errors"` // One line per failed record, capped
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Entry types of KnowledgeVersion
+const (
+	EntryTypeKnowledge = "knowledge"
+	EntryTypeFAQ       = "faq"
+	EntryTypeProduct   = "product"
+)
+
+// KnowledgeVersion is an earlier state of a knowledge, FAQ or product embedding entry,
+// kept for audit and rollback
+type KnowledgeVersion struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	EntryType string            `gorm:"size:20;not null;index:idx_knowledge_versions_entry" json:"entry_type"`
+	EntryID   uint              `gorm:"not null;index:idx_knowledge_versions_entry" json:"entry_id"`
+	Version   int               `json:"version"`
+	Action    string            `gorm:"size:20" json:"action"` // What replaced it: update, delete or rollback
+	Snapshot  KnowledgeSnapshot `gorm:"type:text;serializer:json" json:"snapshot"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// KnowledgeSnapshot holds the editable fields of an entry; only those of its type are set
+type KnowledgeSnapshot struct {
+	Title       string `json:"title,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Tags        string `json:"tags,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Question    string `json:"question,omitempty"`
+	Answer      string `json:"answer,omitempty"`
+	ProductID   uint   `json:"product_id,omitempty"`
+	Description string `json:"description,omitempty"`
+	Features    string `json:"features,omitempty"`
+	UseCases    string `json:"use_cases,omitempty"`
+	Managed     bool   `json:"managed,omitempty"`
+	Active      bool   `json:"active"`
+}
+
+// EmbeddingModel records which model produced the stored vectors
+type EmbeddingModel struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Provider   string    `gorm:"size:50" json:"provider"`
+	Model      string    `gorm:"size:100" json:"model"`
+	Dimensions int       `json:"dimensions"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EmbeddingCacheEntry is a stored embedding keyed by the hash of the model and text
+type EmbeddingCacheEntry struct {
+	Hash       string          `gorm:"primaryKey;size:64" json:"hash"`
+	Model      string          `gorm:"size:150;index" json:"model"` // provider/model/dimensions
+	Embedding  pgvector.Vector `gorm:"type:vector" json:"-"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastUsedAt time.Time       `gorm:"not null;default:CURRENT_TIMESTAMP;index" json:"last_used_at"` // Entries unused for EMBEDDING_CACHE_TTL are pruned
+}
+
+// EmbeddingColumn is a vector column and the SQL expression of the text it embeds
+type EmbeddingColumn struct {
+	Table    string
+	Column   string
+	Source   string
+	Searched bool // Queried by similarity, so it gets an HNSW index
+}
+
+// HNSWMaxDimensions is the largest vector pgvector can index with HNSW
+const HNSWMaxDimensions = 2000
+
+// IndexName is the name of the column's HNSW index
+func (c EmbeddingColumn) IndexName() string {
+	return fmt.Sprintf("idx_%s_%s_hnsw", c.Table, c.Column)
+}
+
+// RebuildIndexName is the name a replacement index is built under before it takes over
+func (c EmbeddingColumn) RebuildIndexName() string {
+	return c.IndexName() + "_rebuild"
+}
+
+// CreateIndexSQL builds a cosine-distance HNSW index with the given name on the column
+func (c EmbeddingColumn) CreateIndexSQL(name string, m, efConstruction int, concurrently bool) string {
+	create := "CREATE INDEX"
+	if concurrently {
+		create += " CONCURRENTLY"
+	}
+	return fmt.Sprintf("%s IF NOT EXISTS %s ON %s USING hnsw (%s vector_cosine_ops) WITH (m = %d, ef_construction = %d)",
+		create, name, c.Table, c.Column, m, efConstruction)
+}
+
+// EmbeddingColumns lists every vector column, used to size, index and re-embed them
+var EmbeddingColumns = []EmbeddingColumn{
+	{Table: "knowledge_bases", Column: "embedding", Source: "content", Searched: true},
+	// Chat history is only searched within one contact, which the contact_id index narrows
+	// to a handful of rows; an HNSW scan would drop most matches before the contact filter
+	{Table: "chat_histories", Column: "message_embedding", Source: "user_message"},
+	{Table: "chat_histories", Column: "response_embedding", Source: "ai_response"},
+	{Table: "product_embeddings", Column: "embedding", Source: "concat_ws('. ', description, features, use_cases)", Searched: true},
+	{Table: "faq_embeddings", Column: "embedding", Source: "question", Searched: true},
+}
+
+// VectorIndexInfo describes an HNSW index on an embedding column
+type VectorIndexInfo struct {
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	Name      string `json:"name"`
+	Exists    bool   `json:"exists"`
+	Valid     bool   `json:"valid"` // False after an interrupted concurrent build
+	SizeBytes int64  `json:"size_bytes"`
+	Options   string `json:"options,omitempty"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// WhatsAppTemplate is a message template registered in the WhatsApp Business account.
+// Templates are synced from Meta; only the parameter mapping is edited locally.
+type WhatsAppTemplate struct {
+	ID         uint                        `gorm:"primaryKey" json:"id"`
+	ExternalID string                      `gorm:"size:100;index" json:"external_id"` // Meta template ID
+	Name       string                      `gorm:"size:512;not null;uniqueIndex:idx_wa_template_name_language" json:"name"`
+	Language   string                      `gorm:"size:20;not null;uniqueIndex:idx_wa_template_name_language" json:"language"`
+	Category   string                      `gorm:"size:50" json:"category"`     // MARKETING, UTILITY, AUTHENTICATION
+	Status     string                      `gorm:"size:50;index" json:"status"` // APPROVED, PENDING, REJECTED, PAUSED, DELETED
+	Components []WhatsAppTemplateComponent `gorm:"type:text;serializer:json" json:"components"`
+	Parameters []TemplateParameter         `gorm:"type:text;serializer:json" json:"parameters"`
+	SyncedAt   *time.Time                  `json:"synced_at"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+}
+
+// WhatsAppTemplateComponent is one part of a template as returned by the Graph API
+type WhatsAppTemplateComponent struct {
+	Type    string                   `json:"type"`             // HEADER, BODY, FOOTER, BUTTONS
+	Format  string                   `json:"format,omitempty"` // TEXT, IMAGE, VIDEO, DOCUMENT (headers)
+	Text    string                   `json:"text,omitempty"`
+	Buttons []map[string]interface{} `json:"buttons,omitempty"`
+}
+
+// TemplateParameter fills placeholder {{Index}} of a header or body with a
+// message template expression over the contact, e.g. "{{.Name}}" or "{name}"
+type TemplateParameter struct {
+	Component string `json:"component"` // header, body
+	Index     int    `json:"index"`
+	Source    string `json:"source"`
+}
+
+// WhatsAppConversation is a business-initiated conversation with a recipient. Each counts
+// against the messaging tier for 24 hours; storing them keeps the count across restarts.
+type WhatsAppConversation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Account   string    `gorm:"size:100;not null;uniqueIndex:idx_wa_conversation_recipient" json:"account"` // Phone number ID
+	Recipient string    `gorm:"size:100;not null;uniqueIndex:idx_wa_conversation_recipient" json:"recipient"`
+	StartedAt time.Time `gorm:"not null;index" json:"started_at"`
+}
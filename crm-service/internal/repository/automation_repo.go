@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+type AutomationRepository struct {
+	db *gorm.DB
+}
+
+func NewAutomationRepository(db *gorm.DB) *AutomationRepository {
+	return &AutomationRepository{db: db}
+}
+
+// ==================== RULES ====================
+
+func (r *AutomationRepository) FindAll() ([]models.AutomationRule, error) {
+	var rules []models.AutomationRule
+	err := r.db.Order("priority DESC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+func (r *AutomationRepository) FindByID(id uint) (*models.AutomationRule, error) {
+	var rule models.AutomationRule
+	err := r.db.First(&rule, id).Error
+	return &rule, err
+}
+
+// FindActiveByTrigger returns active rules for a trigger in execution order
+func (r *AutomationRepository) FindActiveByTrigger(trigger string) ([]models.AutomationRule, error) {
+	var rules []models.AutomationRule
+	err := r.db.Where("trigger = ? AND active = ?", trigger, true).
+		Order("priority DESC, id ASC").
+		Find(&rules).Error
+	return rules, err
+}
+
+func (r *AutomationRepository) Create(rule *models.AutomationRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *AutomationRepository) Update(rule *models.AutomationRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *AutomationRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AutomationRule{}, id).Error
+}
+
+func (r *AutomationRepository) IncrementRunCount(id uint) error {
+	return r.db.Model(&models.AutomationRule{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"run_count":   gorm.Expr("run_count + 1"),
+			"last_run_at": time.Now(),
+		}).Error
+}
+
+// ==================== LOGS ====================
+
+func (r *AutomationRepository) CreateLog(log *models.AutomationLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *AutomationRepository) FindLogs(ruleID uint, limit int) ([]models.AutomationLog, error) {
+	var logs []models.AutomationLog
+	query := r.db.Order("created_at DESC").Limit(limit)
+	if ruleID != 0 {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+// HasRun reports whether a rule already ran for a chat message
+func (r *AutomationRepository) HasRun(ruleID, chatMessageID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.AutomationLog{}).
+		Where("rule_id = ? AND chat_message_id = ?", ruleID, chatMessageID).
+		Count(&count).Error
+	return count > 0, err
+}
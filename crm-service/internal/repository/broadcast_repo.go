@@ -2,7 +2,11 @@ package repository
 
 import (
 	"divine-crm/internal/models"
+	"errors"
+	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type BroadcastRepository struct {
@@ -41,14 +45,376 @@ func (r *BroadcastRepository) DeleteTemplate(id uint) error {
 // History
 func (r *BroadcastRepository) FindAllHistory() ([]models.BroadcastHistory, error) {
 	var history []models.BroadcastHistory
-	err := r.db.Preload("Template").Order("created_at DESC").Find(&history).Error
+	err := r.db.Preload("Template").Preload("Variants", orderByID).Order("created_at DESC").Find(&history).Error
 	return history, err
 }
 
+func (r *BroadcastRepository) FindHistoryByID(id uint) (*models.BroadcastHistory, error) {
+	var history models.BroadcastHistory
+	err := r.db.Preload("Template").Preload("Variants", orderByID).Preload("Variants.Template").First(&history, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &history, err
+}
+
 func (r *BroadcastRepository) CreateHistory(history *models.BroadcastHistory) error {
 	return r.db.Create(history).Error
 }
 
+// CreateBroadcast stores a broadcast with its variants and recipients in one transaction,
+// so a failure never leaves a broadcast without its audience. recipients is called once
+// the history and variant IDs are known.
+func (r *BroadcastRepository) CreateBroadcast(history *models.BroadcastHistory, variants []models.BroadcastVariant, recipients func() []models.BroadcastRecipient) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+
+		if len(variants) > 0 {
+			for i := range variants {
+				variants[i].HistoryID = history.ID
+			}
+			if err := tx.Omit("Template").Create(&variants).Error; err != nil {
+				return err
+			}
+		}
+
+		rows := recipients()
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+}
+
 func (r *BroadcastRepository) UpdateHistory(history *models.BroadcastHistory) error {
 	return r.db.Save(history).Error
 }
+
+// UpdateHistoryProgress stores the delivery counters without touching the template association.
+// A completed broadcast keeps its first completion time when late receipts arrive.
+func (r *BroadcastRepository) UpdateHistoryProgress(id uint, successful, failed int, status string, completedAt *time.Time) error {
+	updates := map[string]interface{}{
+		"successful":   successful,
+		"failed":       failed,
+		"status":       status,
+		"completed_at": nil,
+	}
+	if completedAt != nil {
+		updates["completed_at"] = gorm.Expr("COALESCE(completed_at, ?)", *completedAt)
+	}
+	return r.db.Model(&models.BroadcastHistory{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// AddHistoryRecipients grows the audience of a broadcast, e.g. when an A/B test winner goes out
+func (r *BroadcastRepository) AddHistoryRecipients(id uint, count int) error {
+	return r.db.Model(&models.BroadcastHistory{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"sent_to":      gorm.Expr("sent_to + ?", count),
+			"completed_at": nil,
+		}).Error
+}
+
+// Variants
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id ASC")
+}
+
+func (r *BroadcastRepository) UpdateVariant(variant *models.BroadcastVariant) error {
+	return r.db.Omit("Template").Save(variant).Error
+}
+
+// VariantStats are the recipient outcomes of one A/B test variant
+type VariantStats struct {
+	VariantID  uint
+	Recipients int
+	Sent       int
+	Delivered  int
+	Failed     int
+	Replied    int
+	Converted  int
+}
+
+// CountRecipientsByVariant aggregates recipient outcomes per variant. Instagram and
+// Telegram send no delivery receipts, so a sent message on those channels counts as delivered.
+func (r *BroadcastRepository) CountRecipientsByVariant(historyID uint) ([]VariantStats, error) {
+	var stats []VariantStats
+	err := r.db.Model(&models.BroadcastRecipient{}).
+		Select(`variant_id,
+			COUNT(*) AS recipients,
+			COUNT(*) FILTER (WHERE status IN ('Sent', 'Delivered')) AS sent,
+			COUNT(*) FILTER (WHERE status = 'Delivered' OR (status = 'Sent' AND channel <> 'WhatsApp')) AS delivered,
+			COUNT(*) FILTER (WHERE status = 'Failed') AS failed,
+			COUNT(replied_at) AS replied,
+			COUNT(converted_at) AS converted`).
+		Where("history_id = ? AND variant_id IS NOT NULL", historyID).
+		Group("variant_id").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// FindDueWinnerTests returns A/B tests whose winner should be picked now
+func (r *BroadcastRepository) FindDueWinnerTests(now time.Time) ([]models.BroadcastHistory, error) {
+	var histories []models.BroadcastHistory
+	err := r.db.Where("auto_winner = ? AND winner_variant_id IS NULL AND winner_decide_at <= ?", true, now).
+		Find(&histories).Error
+	return histories, err
+}
+
+// ClaimWinner records the winning variant unless one was already picked
+func (r *BroadcastRepository) ClaimWinner(historyID, variantID uint) (bool, error) {
+	result := r.db.Model(&models.BroadcastHistory{}).
+		Where("id = ? AND winner_variant_id IS NULL", historyID).
+		Update("winner_variant_id", variantID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, r.db.Model(&models.BroadcastVariant{}).Where("id = ?", variantID).Update("is_winner", true).Error
+}
+
+// Recipients
+func (r *BroadcastRepository) CreateRecipients(recipients []models.BroadcastRecipient) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(&recipients, 500).Error
+}
+
+// FindRecipients returns the recipients of a broadcast, optionally filtered by status
+func (r *BroadcastRepository) FindRecipients(historyID uint, status string) ([]models.BroadcastRecipient, error) {
+	var recipients []models.BroadcastRecipient
+	query := r.db.Where("history_id = ?", historyID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id ASC").Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *BroadcastRepository) FindRecipientByExternalID(externalID string) (*models.BroadcastRecipient, error) {
+	var recipient models.BroadcastRecipient
+	err := r.db.Where("external_id = ?", externalID).First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &recipient, err
+}
+
+// ClaimPendingRecipients locks up to limit due recipients and marks them as sending.
+// SKIP LOCKED lets several workers share the queue without sending twice.
+func (r *BroadcastRepository) ClaimPendingRecipients(limit int, now time.Time) ([]models.BroadcastRecipient, error) {
+	var recipients []models.BroadcastRecipient
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", "Pending").
+			Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+			Order("id ASC").
+			Limit(limit).
+			Find(&recipients).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(recipients))
+		for i := range recipients {
+			ids[i] = recipients[i].ID
+			recipients[i].Status = "Sending"
+			recipients[i].ClaimedAt = &now
+		}
+		return tx.Model(&models.BroadcastRecipient{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{"status": "Sending", "claimed_at": now}).Error
+	})
+	return recipients, err
+}
+
+// RecipientContactIDs returns the contacts a broadcast was already queued for
+func (r *BroadcastRepository) RecipientContactIDs(historyID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.BroadcastRecipient{}).
+		Where("history_id = ?", historyID).
+		Pluck("contact_id", &ids).Error
+	return ids, err
+}
+
+// FindLatestSentRecipient returns the most recent broadcast message sent to a contact since the given time
+func (r *BroadcastRepository) FindLatestSentRecipient(contactID uint, since time.Time) (*models.BroadcastRecipient, error) {
+	var recipient models.BroadcastRecipient
+	err := r.db.Where("contact_id = ? AND status IN ? AND sent_at >= ?", contactID, []string{"Sent", "Delivered"}, since).
+		Order("sent_at DESC").
+		First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &recipient, err
+}
+
+// SetRecipientTime sets one timestamp column (replied_at, converted_at) without touching the rest of the row
+func (r *BroadcastRepository) SetRecipientTime(id uint, column string, at time.Time) error {
+	return r.db.Model(&models.BroadcastRecipient{}).Where("id = ?", id).UpdateColumn(column, at).Error
+}
+
+// UpdateRecipientColumns applies a delivery receipt without overwriting reply or conversion times
+func (r *BroadcastRepository) UpdateRecipientColumns(id uint, updates map[string]interface{}) error {
+	return r.db.Model(&models.BroadcastRecipient{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *BroadcastRepository) UpdateRecipient(recipient *models.BroadcastRecipient) error {
+	return r.db.Save(recipient).Error
+}
+
+// ResetSendingRecipients requeues recipients whose claim is older than staleBefore,
+// left behind by a worker that stopped mid-send. Fresh claims belong to a live worker.
+func (r *BroadcastRepository) ResetSendingRecipients(staleBefore time.Time) (int64, error) {
+	result := r.db.Model(&models.BroadcastRecipient{}).
+		Where("status = ?", "Sending").
+		Where("claimed_at IS NULL OR claimed_at < ?", staleBefore).
+		Updates(map[string]interface{}{"status": "Pending", "claimed_at": nil})
+	return result.RowsAffected, result.Error
+}
+
+// RetryFailedRecipients requeues every failed recipient of a broadcast
+func (r *BroadcastRepository) RetryFailedRecipients(historyID uint) (int64, error) {
+	result := r.db.Model(&models.BroadcastRecipient{}).
+		Where("history_id = ? AND status = ?", historyID, "Failed").
+		Updates(map[string]interface{}{
+			"status":          "Pending",
+			"attempts":        0,
+			"error":           "",
+			"next_attempt_at": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// RecipientStatusCount is the number of recipients of a broadcast in one status
+type RecipientStatusCount struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+func (r *BroadcastRepository) CountRecipientsByStatus(historyID uint) ([]RecipientStatusCount, error) {
+	var counts []RecipientStatusCount
+	err := r.db.Model(&models.BroadcastRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("history_id = ?", historyID).
+		Group("status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CampaignTotals are the recipient outcomes of a whole broadcast
+type CampaignTotals struct {
+	Recipients int64 `json:"recipients"`
+	Pending    int64 `json:"pending"`
+	Sent       int64 `json:"sent"`
+	Delivered  int64 `json:"delivered"`
+	Read       int64 `json:"read"`
+	Replied    int64 `json:"replied"`
+	Converted  int64 `json:"converted"`
+	Failed     int64 `json:"failed"`
+	Skipped    int64 `json:"skipped"`
+}
+
+// CountCampaignTotals aggregates recipient outcomes. As for variants, a sent message on a
+// channel without delivery receipts counts as delivered.
+func (r *BroadcastRepository) CountCampaignTotals(historyID uint) (*CampaignTotals, error) {
+	var totals CampaignTotals
+	err := r.db.Model(&models.BroadcastRecipient{}).
+		Select(`COUNT(*) AS recipients,
+			COUNT(*) FILTER (WHERE status IN ('Pending', 'Sending')) AS pending,
+			COUNT(*) FILTER (WHERE status IN ('Sent', 'Delivered')) AS sent,
+			COUNT(*) FILTER (WHERE status = 'Delivered' OR (status = 'Sent' AND channel <> 'WhatsApp')) AS delivered,
+			COUNT(read_at) AS read,
+			COUNT(replied_at) AS replied,
+			COUNT(converted_at) AS converted,
+			COUNT(*) FILTER (WHERE status = 'Failed') AS failed,
+			COUNT(*) FILTER (WHERE status = 'Skipped') AS skipped`).
+		Where("history_id = ?", historyID).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+// TimelineCount is the number of recipient events in one time bucket
+type TimelineCount struct {
+	Bucket time.Time
+	Count  int64
+}
+
+// CountRecipientTimeline buckets one timestamp column (sent_at, delivered_at, read_at, ...)
+// by hour or day
+func (r *BroadcastRepository) CountRecipientTimeline(historyID uint, column, unit string) ([]TimelineCount, error) {
+	var counts []TimelineCount
+	bucket := fmt.Sprintf("date_trunc('%s', %s)", unit, column)
+	err := r.db.Model(&models.BroadcastRecipient{}).
+		Select(bucket+" AS bucket, COUNT(*) AS count").
+		Where("history_id = ?", historyID).
+		Where(column + " IS NOT NULL").
+		Group("bucket").
+		Order("bucket ASC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CountAttributedMessages counts inbound chat messages linked to a broadcast
+func (r *BroadcastRepository) CountAttributedMessages(historyID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ChatMessage{}).Where("broadcast_history_id = ?", historyID).Count(&count).Error
+	return count, err
+}
+
+// Schedules
+func (r *BroadcastRepository) FindAllSchedules() ([]models.ScheduledBroadcast, error) {
+	var schedules []models.ScheduledBroadcast
+	err := r.db.Preload("Template").Order("next_run_at ASC NULLS LAST, id DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *BroadcastRepository) FindScheduleByID(id uint) (*models.ScheduledBroadcast, error) {
+	var schedule models.ScheduledBroadcast
+	err := r.db.Preload("Template").First(&schedule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &schedule, err
+}
+
+// FindDueSchedules returns scheduled broadcasts whose next run is at or before now
+func (r *BroadcastRepository) FindDueSchedules(now time.Time) ([]models.ScheduledBroadcast, error) {
+	var schedules []models.ScheduledBroadcast
+	err := r.db.Where("status = ? AND next_run_at <= ?", "Scheduled", now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *BroadcastRepository) CreateSchedule(schedule *models.ScheduledBroadcast) error {
+	return r.db.Omit("Template").Create(schedule).Error
+}
+
+func (r *BroadcastRepository) UpdateSchedule(schedule *models.ScheduledBroadcast) error {
+	return r.db.Omit("Template").Save(schedule).Error
+}
+
+// ClaimSchedule marks a due schedule as running; it returns false if another worker got it first
+func (r *BroadcastRepository) ClaimSchedule(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.ScheduledBroadcast{}).
+		Where("id = ? AND status = ?", id, "Scheduled").
+		Updates(map[string]interface{}{"status": "Running", "claimed_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ResetRunningSchedules puts schedules claimed before staleBefore back in the queue.
+// Those were interrupted by a crash; newer claims belong to a live worker.
+func (r *BroadcastRepository) ResetRunningSchedules(staleBefore time.Time) (int64, error) {
+	result := r.db.Model(&models.ScheduledBroadcast{}).
+		Where("status = ?", "Running").
+		Where("claimed_at IS NULL OR claimed_at < ?", staleBefore).
+		Updates(map[string]interface{}{"status": "Scheduled", "claimed_at": nil})
+	return result.RowsAffected, result.Error
+}
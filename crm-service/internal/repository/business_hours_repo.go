@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+)
+
+type BusinessHoursRepository struct {
+	db *gorm.DB
+}
+
+func NewBusinessHoursRepository(db *gorm.DB) *BusinessHoursRepository {
+	return &BusinessHoursRepository{db: db}
+}
+
+// ==================== SETTINGS ====================
+
+func (r *BusinessHoursRepository) GetSettings() (*models.BusinessHours, error) {
+	var settings models.BusinessHours
+	err := r.db.Order("id ASC").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &settings, err
+}
+
+func (r *BusinessHoursRepository) SaveSettings(settings *models.BusinessHours) error {
+	return r.db.Save(settings).Error
+}
+
+// ==================== WEEKLY SCHEDULE ====================
+
+func (r *BusinessHoursRepository) FindSchedule() ([]models.BusinessSchedule, error) {
+	var schedule []models.BusinessSchedule
+	err := r.db.Order("day_of_week ASC").Find(&schedule).Error
+	return schedule, err
+}
+
+// ReplaceSchedule swaps the whole weekly schedule in a single transaction
+func (r *BusinessHoursRepository) ReplaceSchedule(schedule []models.BusinessSchedule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.BusinessSchedule{}).Error; err != nil {
+			return err
+		}
+		if len(schedule) == 0 {
+			return nil
+		}
+		return tx.Create(&schedule).Error
+	})
+}
+
+// ==================== HOLIDAYS ====================
+
+func (r *BusinessHoursRepository) FindHolidays() ([]models.Holiday, error) {
+	var holidays []models.Holiday
+	err := r.db.Order("date ASC").Find(&holidays).Error
+	return holidays, err
+}
+
+func (r *BusinessHoursRepository) CreateHoliday(holiday *models.Holiday) error {
+	return r.db.Create(holiday).Error
+}
+
+func (r *BusinessHoursRepository) DeleteHoliday(id uint) error {
+	return r.db.Delete(&models.Holiday{}, id).Error
+}
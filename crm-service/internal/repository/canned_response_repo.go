@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+)
+
+type CannedResponseRepository struct {
+	db *gorm.DB
+}
+
+func NewCannedResponseRepository(db *gorm.DB) *CannedResponseRepository {
+	return &CannedResponseRepository{db: db}
+}
+
+// visibleTo limits a query to shared responses and the agent's own
+func (r *CannedResponseRepository) visibleTo(agentID uint) *gorm.DB {
+	return r.db.Where("owner_id IS NULL OR owner_id = ?", agentID)
+}
+
+// FindVisible returns shared responses and the agent's own, optionally filtered by category
+func (r *CannedResponseRepository) FindVisible(agentID uint, category string) ([]models.CannedResponse, error) {
+	var responses []models.CannedResponse
+	query := r.visibleTo(agentID)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	err := query.Order("shortcut ASC").Find(&responses).Error
+	return responses, err
+}
+
+// Search matches the shortcut prefix or any text in the title and content,
+// ranking shortcut hits and frequently used responses first
+func (r *CannedResponseRepository) Search(agentID uint, query string, limit int) ([]models.CannedResponse, error) {
+	var responses []models.CannedResponse
+	prefix := query + "%"
+	pattern := "%" + query + "%"
+	err := r.visibleTo(agentID).
+		Where("shortcut ILIKE ? OR title ILIKE ? OR content ILIKE ?", prefix, pattern, pattern).
+		Order(gorm.Expr("CASE WHEN shortcut ILIKE ? THEN 0 ELSE 1 END", prefix)).
+		Order("usage_count DESC, shortcut ASC").
+		Limit(limit).
+		Find(&responses).Error
+	return responses, err
+}
+
+func (r *CannedResponseRepository) FindByID(id uint) (*models.CannedResponse, error) {
+	var response models.CannedResponse
+	err := r.db.First(&response, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &response, err
+}
+
+// FindByShortcut returns the response with the shortcut in the same scope (shared or owned by ownerID)
+func (r *CannedResponseRepository) FindByShortcut(shortcut string, ownerID *uint) (*models.CannedResponse, error) {
+	var response models.CannedResponse
+	query := r.db.Where("LOWER(shortcut) = LOWER(?)", shortcut)
+	if ownerID == nil {
+		query = query.Where("owner_id IS NULL")
+	} else {
+		query = query.Where("owner_id = ?", *ownerID)
+	}
+	err := query.First(&response).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &response, err
+}
+
+// FindCategories returns the distinct categories visible to the agent
+func (r *CannedResponseRepository) FindCategories(agentID uint) ([]string, error) {
+	var categories []string
+	err := r.visibleTo(agentID).
+		Model(&models.CannedResponse{}).
+		Where("category <> ''").
+		Distinct("category").
+		Order("category ASC").
+		Pluck("category", &categories).Error
+	return categories, err
+}
+
+func (r *CannedResponseRepository) Create(response *models.CannedResponse) error {
+	return r.db.Create(response).Error
+}
+
+func (r *CannedResponseRepository) Update(response *models.CannedResponse) error {
+	return r.db.Save(response).Error
+}
+
+func (r *CannedResponseRepository) Delete(id uint) error {
+	return r.db.Delete(&models.CannedResponse{}, id).Error
+}
+
+func (r *CannedResponseRepository) IncrementUsage(id uint) error {
+	return r.db.Model(&models.CannedResponse{}).
+		Where("id = ?", id).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error
+}
@@ -31,6 +31,32 @@ func (r *ChatLabelRepository) FindByLabel(label string) (*models.ChatLabel, erro
 	return &chatLabel, err
 }
 
+func (r *ChatLabelRepository) FindByIDs(ids []uint) ([]models.ChatLabel, error) {
+	var labels []models.ChatLabel
+	err := r.db.Where("id IN ?", ids).Find(&labels).Error
+	return labels, err
+}
+
+// LabelCount is the number of conversations carrying a label
+type LabelCount struct {
+	LabelID uint   `json:"label_id"`
+	Label   string `json:"label"`
+	Color   string `json:"color"`
+	Count   int64  `json:"count"`
+}
+
+// CountChats returns the number of conversations per label, including unused labels
+func (r *ChatLabelRepository) CountChats() ([]LabelCount, error) {
+	var counts []LabelCount
+	err := r.db.Model(&models.ChatLabel{}).
+		Select("chat_labels.id AS label_id, chat_labels.label, chat_labels.color, COUNT(chat_message_labels.chat_message_id) AS count").
+		Joins("LEFT JOIN chat_message_labels ON chat_message_labels.chat_label_id = chat_labels.id").
+		Group("chat_labels.id, chat_labels.label, chat_labels.color").
+		Order("chat_labels.label ASC").
+		Scan(&counts).Error
+	return counts, err
+}
+
 func (r *ChatLabelRepository) Create(label *models.ChatLabel) error {
 	return r.db.Create(label).Error
 }
@@ -40,5 +66,10 @@ func (r *ChatLabelRepository) Update(label *models.ChatLabel) error {
 }
 
 func (r *ChatLabelRepository) Delete(id uint) error {
-	return r.db.Delete(&models.ChatLabel{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_label_id = ?", id).Delete(&models.ChatMessageLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ChatLabel{}, id).Error
+	})
 }
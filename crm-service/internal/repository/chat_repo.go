@@ -3,6 +3,8 @@ package repository
 import (
 	"divine-crm/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type ChatRepository struct {
@@ -15,19 +17,19 @@ func NewChatRepository(db *gorm.DB) *ChatRepository {
 
 func (r *ChatRepository) FindAll() ([]models.ChatMessage, error) {
 	var messages []models.ChatMessage
-	err := r.db.Preload("Contact").Order("created_at DESC").Find(&messages).Error
+	err := r.db.Preload("Contact").Preload("ChatLabels").Order("created_at DESC").Find(&messages).Error
 	return messages, err
 }
 
 func (r *ChatRepository) FindByID(id uint) (*models.ChatMessage, error) {
 	var message models.ChatMessage
-	err := r.db.Preload("Contact").First(&message, id).Error
+	err := r.db.Preload("Contact").Preload("ChatLabels").First(&message, id).Error
 	return &message, err
 }
 
 func (r *ChatRepository) FindByStatus(status string) ([]models.ChatMessage, error) {
 	var messages []models.ChatMessage
-	err := r.db.Preload("Contact").
+	err := r.db.Preload("Contact").Preload("ChatLabels").
 		Where("status = ?", status).
 		Order("created_at DESC").
 		Find(&messages).Error
@@ -36,7 +38,7 @@ func (r *ChatRepository) FindByStatus(status string) ([]models.ChatMessage, erro
 
 func (r *ChatRepository) FindByContactID(contactID uint) ([]models.ChatMessage, error) {
 	var messages []models.ChatMessage
-	err := r.db.Preload("Contact").
+	err := r.db.Preload("Contact").Preload("ChatLabels").
 		Where("contact_id = ?", contactID).
 		Order("created_at ASC").
 		Find(&messages).Error
@@ -45,23 +47,145 @@ func (r *ChatRepository) FindByContactID(contactID uint) ([]models.ChatMessage,
 
 func (r *ChatRepository) FindByChannel(channel string) ([]models.ChatMessage, error) {
 	var messages []models.ChatMessage
-	err := r.db.Preload("Contact").
+	err := r.db.Preload("Contact").Preload("ChatLabels").
 		Where("channel = ?", channel).
 		Order("created_at DESC").
 		Find(&messages).Error
 	return messages, err
 }
 
+// FindByLabels returns chat messages carrying any of the given labels
+func (r *ChatRepository) FindByLabels(labelIDs []uint) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	err := r.db.Preload("Contact").Preload("ChatLabels").
+		Where("id IN (?)", r.db.Model(&models.ChatMessageLabel{}).
+			Select("chat_message_id").
+			Where("chat_label_id IN ?", labelIDs)).
+		Order("created_at DESC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// A message breaches its SLA while nobody has answered it and the deadline has passed.
+// Replies are stored as separate rows, so the answer is tracked by first_responded_at.
+const slaBreachedCondition = "first_responded_at IS NULL AND status <> ? AND sla_due_at < ?"
+
+// FindSLABreached returns unanswered messages whose first response deadline has passed, oldest deadline first
+func (r *ChatRepository) FindSLABreached(now time.Time) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	err := r.db.Preload("Contact").Preload("ChatLabels").
+		Where(slaBreachedCondition, "Resolved", now).
+		Order("sla_due_at ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+func (r *ChatRepository) CountSLABreached(now time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ChatMessage{}).
+		Where(slaBreachedCondition, "Resolved", now).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkResponded records the first response on every message the contact sent up to
+// the given time that was still waiting for one
+func (r *ChatRepository) MarkResponded(contactID uint, upTo, respondedAt time.Time) error {
+	return r.db.Model(&models.ChatMessage{}).
+		Where("contact_id = ? AND created_at <= ? AND sla_due_at IS NOT NULL AND first_responded_at IS NULL", contactID, upTo).
+		Update("first_responded_at", respondedAt).Error
+}
+
+// FindIdleConversations returns the latest message of every unresolved conversation
+// whose last activity falls between since and before
+func (r *ChatRepository) FindIdleConversations(since, before time.Time) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	latest := r.db.Model(&models.ChatMessage{}).
+		Select("DISTINCT ON (contact_id) id").
+		Order("contact_id, created_at DESC")
+
+	err := r.db.Preload("Contact").Preload("ChatLabels").
+		Where("id IN (?)", latest).
+		Where("status <> ?", "Resolved").
+		Where("created_at BETWEEN ? AND ?", since, before).
+		Find(&messages).Error
+	return messages, err
+}
+
+// FindLatestReplyTime returns when a contact last got a reply from the given sender, or nil if never
+func (r *ChatRepository) FindLatestReplyTime(contactID uint, assignedAgent string) (*time.Time, error) {
+	var message models.ChatMessage
+	err := r.db.Select("created_at").
+		Where("contact_id = ? AND assigned_agent = ? AND response <> ''", contactID, assignedAgent).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&message).Error
+	if err != nil || message.CreatedAt.IsZero() {
+		return nil, err
+	}
+	return &message.CreatedAt, nil
+}
+
+// Labels are managed through AddLabels/RemoveLabel, never through Create/Update
 func (r *ChatRepository) Create(message *models.ChatMessage) error {
-	return r.db.Create(message).Error
+	return r.db.Omit("ChatLabels").Create(message).Error
 }
 
 func (r *ChatRepository) Update(message *models.ChatMessage) error {
-	return r.db.Save(message).Error
+	return r.db.Omit("ChatLabels").Save(message).Error
+}
+
+// UpdateAssignment sets who handles a chat message and its status without rewriting the rest of the row
+func (r *ChatRepository) UpdateAssignment(id uint, assignedTo, assignedAgent, status string) error {
+	return r.db.Model(&models.ChatMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"assigned_to":    assignedTo,
+		"assigned_agent": assignedAgent,
+		"status":         status,
+	}).Error
 }
 
 func (r *ChatRepository) Delete(id uint) error {
-	return r.db.Delete(&models.ChatMessage{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_message_id = ?", id).Delete(&models.ChatMessageLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ChatMessage{}, id).Error
+	})
+}
+
+// ==================== LABELS ====================
+
+// AddLabels attaches every label to every chat, ignoring pairs that already exist
+func (r *ChatRepository) AddLabels(chatIDs, labelIDs []uint) error {
+	if len(chatIDs) == 0 || len(labelIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	links := make([]models.ChatMessageLabel, 0, len(chatIDs)*len(labelIDs))
+	for _, chatID := range chatIDs {
+		for _, labelID := range labelIDs {
+			links = append(links, models.ChatMessageLabel{
+				ChatMessageID: chatID,
+				ChatLabelID:   labelID,
+				CreatedAt:     now,
+			})
+		}
+	}
+
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+func (r *ChatRepository) RemoveLabel(chatID, labelID uint) error {
+	return r.db.Where("chat_message_id = ? AND chat_label_id = ?", chatID, labelID).
+		Delete(&models.ChatMessageLabel{}).Error
+}
+
+// CountExisting returns how many of the given chat IDs exist
+func (r *ChatRepository) CountExisting(ids []uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ChatMessage{}).Where("id IN ?", ids).Count(&count).Error
+	return count, err
 }
 
 // Statistics methods
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds statements without a database and records the SQL it would run
+func dryRunDB(t *testing.T) (*gorm.DB, *[]string) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost sslmode=disable"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open dry run db: %v", err)
+	}
+
+	var statements []string
+	record := func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	return db, &statements
+}
+
+func TestSLABreachedQuery(t *testing.T) {
+	db, statements := dryRunDB(t)
+	repo := NewChatRepository(db)
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	if _, err := repo.FindSLABreached(now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CountSLABreached(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*statements) != 2 {
+		t.Fatalf("got %d statements, want 2: %v", len(*statements), *statements)
+	}
+	for _, sql := range *statements {
+		for _, want := range []string{
+			"first_responded_at IS NULL",
+			"status <> 'Resolved'",
+			"sla_due_at < '2026-03-02 10:00:00'",
+		} {
+			if !strings.Contains(sql, want) {
+				t.Errorf("breach query %q is missing %q", sql, want)
+			}
+		}
+		// Inbound rows keep their status after a reply is stored separately, so it can't decide the breach
+		if strings.Contains(sql, "'Unassigned'") {
+			t.Errorf("breach query %q still filters on the inbound status", sql)
+		}
+	}
+}
+
+func TestMarkRespondedQuery(t *testing.T) {
+	db, statements := dryRunDB(t)
+	repo := NewChatRepository(db)
+	at := time.Date(2026, 3, 2, 10, 5, 0, 0, time.UTC)
+
+	if err := repo.MarkResponded(7, at, at); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*statements) != 1 {
+		t.Fatalf("got %d statements, want 1: %v", len(*statements), *statements)
+	}
+	sql := (*statements)[0]
+	for _, want := range []string{
+		`UPDATE "chat_messages" SET "first_responded_at"='2026-03-02 10:05:00'`,
+		"contact_id = 7",
+		"created_at <= '2026-03-02 10:05:00'",
+		"sla_due_at IS NOT NULL",
+		"first_responded_at IS NULL",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("mark query %q is missing %q", sql, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConsentRepository struct {
+	db *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) *ConsentRepository {
+	return &ConsentRepository{db: db}
+}
+
+// Consent
+
+func (r *ConsentRepository) FindConsent(contactID uint, channel string) (*models.ContactConsent, error) {
+	var consent models.ContactConsent
+	err := r.db.Where("contact_id = ? AND channel = ?", contactID, channel).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &consent, err
+}
+
+func (r *ConsentRepository) FindConsentsByContactIDs(contactIDs []uint) ([]models.ContactConsent, error) {
+	var consents []models.ContactConsent
+	if len(contactIDs) == 0 {
+		return consents, nil
+	}
+	err := r.db.Where("contact_id IN ?", contactIDs).Find(&consents).Error
+	return consents, err
+}
+
+// SaveConsent upserts the consent of a contact on a channel, records the change in the log
+// and updates the suppression list of the contact's address in the same transaction. An
+// opt-out suppresses the address; an opt-in lifts only a suppression an opt-out added, so
+// entries from agents, imports or bounces stay.
+func (r *ConsentRepository) SaveConsent(consent *models.ContactConsent, channelID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "contact_id"}, {Name: "channel"}},
+			DoUpdates: clause.AssignmentColumns([]string{"status", "source", "detail", "changed_by", "changed_at", "updated_at"}),
+		}).Create(consent).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&models.ConsentLog{
+			ContactID: consent.ContactID,
+			Channel:   consent.Channel,
+			Status:    consent.Status,
+			Source:    consent.Source,
+			Detail:    consent.Detail,
+			ChangedBy: consent.ChangedBy,
+			CreatedAt: consent.ChangedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if consent.Status == models.ConsentOptedOut {
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SuppressionEntry{
+				Channel:   consent.Channel,
+				ChannelID: channelID,
+				Reason:    models.SuppressionReasonOptedOut,
+				Source:    consent.Source,
+				CreatedBy: consent.ChangedBy,
+				CreatedAt: consent.ChangedAt,
+			}).Error
+		}
+		return tx.Where("channel = ? AND channel_id = ? AND reason = ?", consent.Channel, channelID, models.SuppressionReasonOptedOut).
+			Delete(&models.SuppressionEntry{}).Error
+	})
+}
+
+func (r *ConsentRepository) FindLogs(contactID uint) ([]models.ConsentLog, error) {
+	var logs []models.ConsentLog
+	err := r.db.Where("contact_id = ?", contactID).Order("created_at DESC").Find(&logs).Error
+	return logs, err
+}
+
+// Suppression list
+
+func (r *ConsentRepository) FindSuppressions(channel, query string) ([]models.SuppressionEntry, error) {
+	var entries []models.SuppressionEntry
+	db := r.db.Order("created_at DESC")
+	if channel != "" {
+		db = db.Where("channel = ?", channel)
+	}
+	if query != "" {
+		db = db.Where("channel_id ILIKE ?", "%"+query+"%")
+	}
+	err := db.Find(&entries).Error
+	return entries, err
+}
+
+func (r *ConsentRepository) IsSuppressed(channel, channelID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.SuppressionEntry{}).
+		Where("channel = ? AND channel_id = ?", channel, channelID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SuppressedChannelIDs returns which of the given addresses on a channel are suppressed
+func (r *ConsentRepository) SuppressedChannelIDs(channel string, channelIDs []string) ([]string, error) {
+	var suppressed []string
+	if len(channelIDs) == 0 {
+		return suppressed, nil
+	}
+	err := r.db.Model(&models.SuppressionEntry{}).
+		Where("channel = ? AND channel_id IN ?", channel, channelIDs).
+		Pluck("channel_id", &suppressed).Error
+	return suppressed, err
+}
+
+// AddSuppression stores an entry, keeping the original one if the address is already suppressed
+func (r *ConsentRepository) AddSuppression(entry *models.SuppressionEntry) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(entry).Error
+}
+
+func (r *ConsentRepository) DeleteSuppression(id uint) error {
+	return r.db.Delete(&models.SuppressionEntry{}, id).Error
+}
@@ -68,6 +68,11 @@ func (r *ContactRepository) Update(contact *models.Contact) error {
 	return r.db.Save(contact).Error
 }
 
+// UpdateField sets one column of a contact, leaving changes others made to the rest of the row alone
+func (r *ContactRepository) UpdateField(id uint, column string, value interface{}) error {
+	return r.db.Model(&models.Contact{}).Where("id = ?", id).Update(column, value).Error
+}
+
 func (r *ContactRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Contact{}, id).Error
 }
@@ -36,6 +36,15 @@ func (r *HumanAgentRepository) FindByUsername(username string) (*models.HumanAge
 	return &agent, err
 }
 
+func (r *HumanAgentRepository) FindByUsernames(usernames []string) ([]models.HumanAgent, error) {
+	var agents []models.HumanAgent
+	if len(usernames) == 0 {
+		return agents, nil
+	}
+	err := r.db.Where("LOWER(username) IN ? AND active = ?", usernames, true).Find(&agents).Error
+	return agents, err
+}
+
 func (r *HumanAgentRepository) FindActive() ([]models.HumanAgent, error) {
 	var agents []models.HumanAgent
 	err := r.db.Where("active = ?", true).Order("username ASC").Find(&agents).Error
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+type NoteRepository struct {
+	db *gorm.DB
+}
+
+func NewNoteRepository(db *gorm.DB) *NoteRepository {
+	return &NoteRepository{db: db}
+}
+
+// ==================== NOTES ====================
+
+func (r *NoteRepository) FindByChatID(chatID uint) ([]models.ChatNote, error) {
+	var notes []models.ChatNote
+	err := r.db.Preload("Mentions").
+		Where("chat_message_id = ?", chatID).
+		Order("created_at ASC").
+		Find(&notes).Error
+	return notes, err
+}
+
+func (r *NoteRepository) FindByID(id uint) (*models.ChatNote, error) {
+	var note models.ChatNote
+	err := r.db.Preload("Mentions").First(&note, id).Error
+	return &note, err
+}
+
+// Create saves the note together with its mentions
+func (r *NoteRepository) Create(note *models.ChatNote) error {
+	return r.db.Create(note).Error
+}
+
+func (r *NoteRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("note_id = ?", id).Delete(&models.NoteMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ChatNote{}, id).Error
+	})
+}
+
+// ==================== MENTIONS ====================
+
+func (r *NoteRepository) FindMentionsByAgent(agentID uint, unreadOnly bool) ([]models.NoteMention, error) {
+	var mentions []models.NoteMention
+	query := r.db.Preload("Note").Where("agent_id = ?", agentID)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	err := query.Order("created_at DESC").Find(&mentions).Error
+	return mentions, err
+}
+
+func (r *NoteRepository) CountUnreadMentions(agentID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.NoteMention{}).
+		Where("agent_id = ? AND read = ?", agentID, false).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkMentionRead marks a mention as read, scoped to the owning agent
+func (r *NoteRepository) MarkMentionRead(id, agentID uint) (int64, error) {
+	result := r.db.Model(&models.NoteMention{}).
+		Where("id = ? AND agent_id = ?", id, agentID).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+func (r *NoteRepository) MarkAllMentionsRead(agentID uint) error {
+	return r.db.Model(&models.NoteMention{}).
+		Where("agent_id = ? AND read = ?", agentID, false).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()}).Error
+}
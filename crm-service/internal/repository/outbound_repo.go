@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type OutboundRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboundRepository(db *gorm.DB) *OutboundRepository {
+	return &OutboundRepository{db: db}
+}
+
+// ReserveConversation records a conversation with the recipient unless the account already
+// started limit conversations since the given time; a recipient whose conversation started
+// after that is always allowed. An advisory lock on the account serializes the check and
+// insert across every instance, so concurrent senders cannot both take the last slot.
+func (r *OutboundRepository) ReserveConversation(account, recipient string, now, since time.Time, limit int) (bool, error) {
+	reserved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "wa_conversation:"+account).Error; err != nil {
+			return err
+		}
+
+		var conversation models.WhatsAppConversation
+		err := tx.Where("account = ? AND recipient = ?", account, recipient).First(&conversation).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && conversation.StartedAt.After(since) {
+			reserved = true
+			return nil
+		}
+
+		var count int64
+		if err := tx.Model(&models.WhatsAppConversation{}).
+			Where("account = ? AND started_at > ?", account, since).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return nil
+		}
+
+		// Replaces an expired conversation with the same recipient
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account"}, {Name: "recipient"}},
+			DoUpdates: clause.AssignmentColumns([]string{"started_at"}),
+		}).Create(&models.WhatsAppConversation{
+			Account:   account,
+			Recipient: recipient,
+			StartedAt: now,
+		}).Error
+		reserved = err == nil
+		return err
+	})
+	return reserved, err
+}
+
+// DeleteConversationsBefore removes conversations that no longer count against the tier
+func (r *OutboundRepository) DeleteConversationsBefore(before time.Time) error {
+	return r.db.Where("started_at <= ?", before).Delete(&models.WhatsAppConversation{}).Error
+}
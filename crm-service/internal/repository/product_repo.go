@@ -2,6 +2,7 @@ package repository
 
 import (
 	"divine-crm/internal/models"
+	"errors"
 	"gorm.io/gorm"
 )
 
@@ -25,6 +26,21 @@ func (r *ProductRepository) FindByID(id uint) (*models.Product, error) {
 	return &product, err
 }
 
+// FindByIDOrNil returns nil without an error when the product does not exist
+func (r *ProductRepository) FindByIDOrNil(id uint) (*models.Product, error) {
+	product, err := r.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return product, err
+}
+
+func (r *ProductRepository) FindByCode(code string) (*models.Product, error) {
+	var product models.Product
+	err := r.db.Where("LOWER(code) = LOWER(?)", code).First(&product).Error
+	return &product, err
+}
+
 func (r *ProductRepository) FindActive() ([]models.Product, error) {
 	var products []models.Product
 	err := r.db.Where("stock > ?", 0).Order("name ASC").Find(&products).Error
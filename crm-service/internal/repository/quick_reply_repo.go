@@ -3,6 +3,7 @@ package repository
 import (
 	"divine-crm/internal/models"
 	"gorm.io/gorm"
+	"time"
 )
 
 type QuickReplyRepository struct {
@@ -19,6 +20,31 @@ func (r *QuickReplyRepository) FindAll() ([]models.QuickReply, error) {
 	return replies, err
 }
 
+func (r *QuickReplyRepository) FindByID(id uint) (*models.QuickReply, error) {
+	var reply models.QuickReply
+	err := r.db.First(&reply, id).Error
+	return &reply, err
+}
+
+// FindActiveForChannel returns active replies scoped to the channel (or to all channels), highest priority first
+func (r *QuickReplyRepository) FindActiveForChannel(channel string) ([]models.QuickReply, error) {
+	var replies []models.QuickReply
+	err := r.db.Where("active = ?", true).
+		Where("channel IS NULL OR channel = '' OR channel = 'All' OR channel = ?", channel).
+		Order("priority DESC, id ASC").
+		Find(&replies).Error
+	return replies, err
+}
+
+func (r *QuickReplyRepository) IncrementHit(id uint) error {
+	return r.db.Model(&models.QuickReply{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"hit_count":   gorm.Expr("hit_count + 1"),
+			"last_hit_at": time.Now(),
+		}).Error
+}
+
 func (r *QuickReplyRepository) FindByTrigger(trigger string) (*models.QuickReply, error) {
 	var reply models.QuickReply
 	err := r.db.Where("trigger = ? AND active = ?", trigger, true).First(&reply).Error
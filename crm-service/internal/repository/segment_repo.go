@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SegmentRepository struct {
+	db *gorm.DB
+}
+
+func NewSegmentRepository(db *gorm.DB) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+func (r *SegmentRepository) FindAll() ([]models.Segment, error) {
+	var segments []models.Segment
+	err := r.db.Order("name ASC").Find(&segments).Error
+	return segments, err
+}
+
+func (r *SegmentRepository) FindByID(id uint) (*models.Segment, error) {
+	var segment models.Segment
+	err := r.db.First(&segment, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &segment, err
+}
+
+func (r *SegmentRepository) Create(segment *models.Segment) error {
+	return r.db.Create(segment).Error
+}
+
+func (r *SegmentRepository) Update(segment *models.Segment) error {
+	return r.db.Save(segment).Error
+}
+
+func (r *SegmentRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Segment{}, id).Error
+}
+
+// FindContacts returns contacts matching the filter, most recently contacted first.
+// A limit of 0 returns every match.
+func (r *SegmentRepository) FindContacts(filter *models.SegmentFilter, limit int) ([]models.Contact, error) {
+	var contacts []models.Contact
+	query := r.filterContacts(filter).Order("last_contact DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&contacts).Error
+	return contacts, err
+}
+
+func (r *SegmentRepository) CountContacts(filter *models.SegmentFilter) (int64, error) {
+	var count int64
+	err := r.filterContacts(filter).Count(&count).Error
+	return count, err
+}
+
+// filterContacts builds the contact query for a segment filter
+func (r *SegmentRepository) filterContacts(filter *models.SegmentFilter) *gorm.DB {
+	query := r.db.Model(&models.Contact{})
+
+	if len(filter.Channels) > 0 {
+		query = query.Where("channel IN ?", filter.Channels)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("contact_status IN ?", filter.Statuses)
+	}
+	if len(filter.Temperatures) > 0 {
+		query = query.Where("temperature IN ?", filter.Temperatures)
+	}
+	if len(filter.LabelIDs) > 0 {
+		labelled := r.db.Model(&models.ChatMessage{}).
+			Select("contact_id").
+			Where("id IN (?)", r.db.Model(&models.ChatMessageLabel{}).
+				Select("chat_message_id").
+				Where("chat_label_id IN ?", filter.LabelIDs))
+		query = query.Where("id IN (?)", labelled)
+	}
+	if filter.LastContactAfter != nil {
+		query = query.Where("last_contact >= ?", *filter.LastContactAfter)
+	}
+	if filter.LastContactBefore != nil {
+		query = query.Where("last_contact <= ?", *filter.LastContactBefore)
+	}
+	if filter.LastContactDays > 0 {
+		query = query.Where("last_contact >= ?", time.Now().AddDate(0, 0, -filter.LastContactDays))
+	}
+
+	// Attributes are stored as JSON text
+	for _, attr := range filter.Attributes {
+		switch attr.Operator {
+		case "equals":
+			query = query.Where("(attributes::jsonb ->> ?) = ?", attr.Key, attr.Value)
+		case "not_equals":
+			query = query.Where("(attributes::jsonb ->> ?) IS DISTINCT FROM ?", attr.Key, attr.Value)
+		case "contains":
+			query = query.Where("(attributes::jsonb ->> ?) ILIKE ?", attr.Key, "%"+attr.Value+"%")
+		case "exists":
+			query = query.Where("COALESCE(attributes::jsonb ->> ?, '') <> ''", attr.Key)
+		case "not_exists":
+			query = query.Where("COALESCE(attributes::jsonb ->> ?, '') = ''", attr.Key)
+		}
+	}
+
+	if filter.Marketable {
+		query = query.
+			Where("NOT EXISTS (?)", r.db.Model(&models.ContactConsent{}).
+				Select("1").
+				Where("contact_consents.contact_id = contacts.id AND contact_consents.channel = contacts.channel").
+				Where("contact_consents.status = ?", models.ConsentOptedOut)).
+			Where("NOT EXISTS (?)", r.db.Model(&models.SuppressionEntry{}).
+				Select("1").
+				Where("suppression_entries.channel = contacts.channel AND suppression_entries.channel_id = contacts.channel_id"))
+	}
+
+	return query
+}
@@ -2,17 +2,38 @@ package repository
 
 import (
 	"divine-crm/internal/models"
+	"errors"
 	"fmt"
 	"github.com/pgvector/pgvector-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
+// ErrVersionConflict is returned when an entry changed since it was read
+var ErrVersionConflict = errors.New("entry was changed by someone else, reload it and try again")
+
 type VectorRepository struct {
-	db *gorm.DB
+	db       *gorm.DB
+	efSearch int // hnsw.ef_search for similarity searches, 0 keeps the server default
+}
+
+func NewVectorRepository(db *gorm.DB, efSearch int) *VectorRepository {
+	return &VectorRepository{db: db, efSearch: efSearch}
 }
 
-func NewVectorRepository(db *gorm.DB) *VectorRepository {
-	return &VectorRepository{db: db}
+// similaritySearch runs a nearest-neighbour query with the configured hnsw.ef_search.
+// SET LOCAL only lasts for a transaction, so the query gets one.
+func (r *VectorRepository) similaritySearch(dest interface{}, query string, args ...interface{}) error {
+	if r.efSearch <= 0 {
+		return r.db.Raw(query, args...).Scan(dest).Error
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", r.efSearch)).Error; err != nil {
+			return err
+		}
+		return tx.Raw(query, args...).Scan(dest).Error
+	})
 }
 
 // ==================== KNOWLEDGE BASE ====================
@@ -27,22 +48,100 @@ func (r *VectorRepository) GetAllKnowledge() ([]models.KnowledgeBase, error) {
 	return knowledge, err
 }
 
+func (r *VectorRepository) FindKnowledgeByID(id uint) (*models.KnowledgeBase, error) {
+	var kb models.KnowledgeBase
+	err := r.db.First(&kb, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &kb, err
+}
+
+// SetKnowledgeActiveByCategory activates or deactivates all knowledge of a category
+func (r *VectorRepository) SetKnowledgeActiveByCategory(category string, active bool) (int64, error) {
+	result := r.db.Model(&models.KnowledgeBase{}).
+		Where("LOWER(category) = LOWER(?)", category).
+		Update("active", active)
+	return result.RowsAffected, result.Error
+}
+
 func (r *VectorRepository) FindSimilarKnowledge(embedding pgvector.Vector, limit int) ([]models.KnowledgeBase, error) {
 	var results []models.KnowledgeBase
 
 	query := `
 		SELECT *, 
-		       embedding <=> ? as distance
+		       1 - (embedding <=> ?) as similarity
 		FROM knowledge_bases 
 		WHERE active = true
 		ORDER BY embedding <=> ?
 		LIMIT ?
 	`
 
-	err := r.db.Raw(query, embedding, embedding, limit).Scan(&results).Error
+	err := r.similaritySearch(&results, query, embedding, embedding, limit)
 	return results, err
 }
 
+// ==================== KNOWLEDGE DOCUMENTS ====================
+
+func (r *VectorRepository) GetAllDocuments() ([]models.KnowledgeDocument, error) {
+	var documents []models.KnowledgeDocument
+	err := r.db.Order("updated_at DESC").Find(&documents).Error
+	return documents, err
+}
+
+func (r *VectorRepository) FindDocumentByID(id uint) (*models.KnowledgeDocument, error) {
+	var document models.KnowledgeDocument
+	err := r.db.First(&document, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &document, err
+}
+
+func (r *VectorRepository) FindDocumentBySource(source string) (*models.KnowledgeDocument, error) {
+	var document models.KnowledgeDocument
+	err := r.db.Where("source = ?", source).First(&document).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &document, err
+}
+
+func (r *VectorRepository) FindDocumentChunks(documentID uint) ([]models.KnowledgeBase, error) {
+	var chunks []models.KnowledgeBase
+	err := r.db.Where("document_id = ?", documentID).Order("chunk_index").Find(&chunks).Error
+	return chunks, err
+}
+
+// SaveDocument stores a document and replaces all of its chunks in one transaction
+func (r *VectorRepository) SaveDocument(document *models.KnowledgeDocument, chunks []models.KnowledgeBase) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(document).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.KnowledgeBase{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+
+		for i := range chunks {
+			chunks[i].DocumentID = &document.ID
+		}
+		return tx.CreateInBatches(chunks, 100).Error
+	})
+}
+
+func (r *VectorRepository) DeleteDocument(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", id).Delete(&models.KnowledgeBase{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.KnowledgeDocument{}, id).Error
+	})
+}
+
 // ==================== CHAT HISTORY ====================
 
 func (r *VectorRepository) SaveChatHistory(history *models.ChatHistory) error {
@@ -52,16 +151,20 @@ func (r *VectorRepository) SaveChatHistory(history *models.ChatHistory) error {
 func (r *VectorRepository) FindSimilarChats(contactID uint, embedding pgvector.Vector, limit int) ([]models.ChatHistory, error) {
 	var results []models.ChatHistory
 
+	// The contact's rows are picked first through the contact_id index and ranked exactly;
+	// an approximate index scan would filter by contact only after choosing its candidates
 	query := `
+		WITH history AS MATERIALIZED (
+			SELECT * FROM chat_histories WHERE contact_id = ?
+		)
 		SELECT *,
-		       message_embedding <=> ? as distance
-		FROM chat_histories
-		WHERE contact_id = ?
+		       1 - (message_embedding <=> ?) as similarity
+		FROM history
 		ORDER BY message_embedding <=> ?
 		LIMIT ?
 	`
 
-	err := r.db.Raw(query, embedding, contactID, embedding, limit).Scan(&results).Error
+	err := r.db.Raw(query, contactID, embedding, embedding, limit).Scan(&results).Error
 	return results, err
 }
 
@@ -71,19 +174,59 @@ func (r *VectorRepository) CreateProductEmbedding(pe *models.ProductEmbedding) e
 	return r.db.Create(pe).Error
 }
 
+func (r *VectorRepository) FindProductEmbeddingByID(id uint) (*models.ProductEmbedding, error) {
+	var pe models.ProductEmbedding
+	err := r.db.First(&pe, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &pe, err
+}
+
+func (r *VectorRepository) FindProductEmbeddingsByProductID(productID uint) ([]models.ProductEmbedding, error) {
+	var embeddings []models.ProductEmbedding
+	err := r.db.Where("product_id = ?", productID).Order("id").Find(&embeddings).Error
+	return embeddings, err
+}
+
+// FindSimilarProducts returns the closest embeddings of products still in the catalog,
+// each with its live Product (price, stock)
 func (r *VectorRepository) FindSimilarProducts(embedding pgvector.Vector, limit int) ([]models.ProductEmbedding, error) {
 	var results []models.ProductEmbedding
 
 	query := `
-		SELECT *,
-		       embedding <=> ? as distance
-		FROM product_embeddings
-		ORDER BY embedding <=> ?
+		SELECT pe.*,
+		       1 - (pe.embedding <=> ?) as similarity
+		FROM product_embeddings pe
+		WHERE EXISTS (SELECT 1 FROM products p WHERE p.id = pe.product_id)
+		ORDER BY pe.embedding <=> ?
 		LIMIT ?
 	`
 
-	err := r.db.Raw(query, embedding, embedding, limit).Scan(&results).Error
-	return results, err
+	if err := r.similaritySearch(&results, query, embedding, embedding, limit); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	productIDs := make([]uint, len(results))
+	for i, pe := range results {
+		productIDs[i] = pe.ProductID
+	}
+	var products []models.Product
+	if err := r.db.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	for i := range results {
+		results[i].Product = byID[results[i].ProductID]
+	}
+	return results, nil
 }
 
 // ==================== FAQ ====================
@@ -98,19 +241,36 @@ func (r *VectorRepository) GetAllFAQ() ([]models.FAQEmbedding, error) {
 	return faqs, err
 }
 
+func (r *VectorRepository) FindFAQByID(id uint) (*models.FAQEmbedding, error) {
+	var faq models.FAQEmbedding
+	err := r.db.First(&faq, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &faq, err
+}
+
+// SetFAQActiveByCategory activates or deactivates all FAQs of a category
+func (r *VectorRepository) SetFAQActiveByCategory(category string, active bool) (int64, error) {
+	result := r.db.Model(&models.FAQEmbedding{}).
+		Where("LOWER(category) = LOWER(?)", category).
+		Update("active", active)
+	return result.RowsAffected, result.Error
+}
+
 func (r *VectorRepository) FindSimilarFAQ(embedding pgvector.Vector, limit int) ([]models.FAQEmbedding, error) {
 	var results []models.FAQEmbedding
 
 	query := `
 		SELECT *,
-		       embedding <=> ? as distance
+		       1 - (embedding <=> ?) as similarity
 		FROM faq_embeddings
 		WHERE active = true
 		ORDER BY embedding <=> ?
 		LIMIT ?
 	`
 
-	err := r.db.Raw(query, embedding, embedding, limit).Scan(&results).Error
+	err := r.similaritySearch(&results, query, embedding, embedding, limit)
 	return results, err
 }
 
@@ -120,17 +280,339 @@ func (r *VectorRepository) IncrementFAQHit(id uint) error {
 		Update("hit_count", gorm.Expr("hit_count + 1")).Error
 }
 
-// ==================== SIMPLE KEYWORD SEARCH (Fallback) ====================
+// ==================== VERSIONS ====================
+
+// SaveVersioned stores an entry together with the version it replaces. entry must be a
+// pointer to a KnowledgeBase, FAQEmbedding or ProductEmbedding. The entry is only written
+// while the stored row is still at previous.Version, so of two concurrent edits the second
+// fails with ErrVersionConflict instead of overwriting the first. Without previous the
+// entry is recreated.
+func (r *VectorRepository) SaveVersioned(entry interface{}, previous *models.KnowledgeVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if previous == nil {
+			return tx.Create(entry).Error
+		}
+
+		result := tx.Model(entry).Where("version = ?", previous.Version).Select("*").Updates(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return tx.Create(previous).Error
+	})
+}
+
+// DeleteVersioned deletes an entry and keeps its last state as a version. Like SaveVersioned
+// it fails with ErrVersionConflict when the entry changed since it was read.
+func (r *VectorRepository) DeleteVersioned(entry interface{}, previous *models.KnowledgeVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", previous.Version).Delete(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return tx.Create(previous).Error
+	})
+}
+
+func (r *VectorRepository) FindVersions(entryType string, entryID uint) ([]models.KnowledgeVersion, error) {
+	var versions []models.KnowledgeVersion
+	err := r.db.Where("entry_type = ? AND entry_id = ?", entryType, entryID).
+		Order("version DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+func (r *VectorRepository) FindVersion(entryType string, entryID uint, version int) (*models.KnowledgeVersion, error) {
+	var v models.KnowledgeVersion
+	err := r.db.Where("entry_type = ? AND entry_id = ? AND version = ?", entryType, entryID, version).
+		Order("id DESC").
+		First(&v).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &v, err
+}
+
+// LatestVersion is the highest version number recorded for an entry, 0 when there is none
+func (r *VectorRepository) LatestVersion(entryType string, entryID uint) (int, error) {
+	var latest int
+	err := r.db.Model(&models.KnowledgeVersion{}).
+		Where("entry_type = ? AND entry_id = ?", entryType, entryID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error
+	return latest, err
+}
+
+// ==================== IMPORT & EXPORT ====================
+
+func (r *VectorRepository) CreateImportJob(job *models.KnowledgeImportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *VectorRepository) SaveImportJob(job *models.KnowledgeImportJob) error {
+	return r.db.Save(job).Error
+}
+
+func (r *VectorRepository) FindImportJob(id uint) (*models.KnowledgeImportJob, error) {
+	var job models.KnowledgeImportJob
+	err := r.db.First(&job, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &job, err
+}
+
+func (r *VectorRepository) GetImportJobs(limit int) ([]models.KnowledgeImportJob, error) {
+	var jobs []models.KnowledgeImportJob
+	err := r.db.Order("created_at DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// FailUnfinishedImportJobs marks jobs cut off by a restart as failed
+func (r *VectorRepository) FailUnfinishedImportJobs() (int64, error) {
+	result := r.db.Model(&models.KnowledgeImportJob{}).
+		Where("status IN ?", []string{"pending", "running"}).
+		Updates(map[string]interface{}{"status": "failed", "errors": gorm.Expr("errors || ?", "interrupted by a server restart\n")})
+	return result.RowsAffected, result.Error
+}
+
+func (r *VectorRepository) KnowledgeExists(title, content string) (bool, error) {
+	var exists bool
+	err := r.db.Raw("SELECT EXISTS(SELECT 1 FROM knowledge_bases WHERE title = ? AND content = ?)", title, content).
+		Scan(&exists).Error
+	return exists, err
+}
+
+func (r *VectorRepository) FAQExists(question string) (bool, error) {
+	var exists bool
+	err := r.db.Raw("SELECT EXISTS(SELECT 1 FROM faq_embeddings WHERE LOWER(question) = LOWER(?))", question).
+		Scan(&exists).Error
+	return exists, err
+}
 
-func (r *VectorRepository) SearchKnowledgeByKeyword(query string, limit int) ([]models.KnowledgeBase, error) {
+// CreateImported stores a batch of imported knowledge and FAQs in one transaction
+func (r *VectorRepository) CreateImported(knowledge []models.KnowledgeBase, faqs []models.FAQEmbedding) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(knowledge) > 0 {
+			if err := tx.Create(&knowledge).Error; err != nil {
+				return err
+			}
+		}
+		if len(faqs) > 0 {
+			if err := tx.Create(&faqs).Error; err != nil {
+				return err
+			}
+		}
+
+		// Create leaves false to the column default, which is active
+		var inactiveKnowledge, inactiveFAQs []uint
+		for _, kb := range knowledge {
+			if !kb.Active {
+				inactiveKnowledge = append(inactiveKnowledge, kb.ID)
+			}
+		}
+		for _, faq := range faqs {
+			if !faq.Active {
+				inactiveFAQs = append(inactiveFAQs, faq.ID)
+			}
+		}
+		if len(inactiveKnowledge) > 0 {
+			if err := tx.Model(&models.KnowledgeBase{}).Where("id IN ?", inactiveKnowledge).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		if len(inactiveFAQs) > 0 {
+			if err := tx.Model(&models.FAQEmbedding{}).Where("id IN ?", inactiveFAQs).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetKnowledgeForExport returns every standalone knowledge entry, inactive ones included.
+// Chunks of uploaded documents are left out; they come back by uploading the document again.
+func (r *VectorRepository) GetKnowledgeForExport() ([]models.KnowledgeBase, error) {
+	var knowledge []models.KnowledgeBase
+	err := r.db.Where("document_id IS NULL").Order("id").Find(&knowledge).Error
+	return knowledge, err
+}
+
+// GetFAQForExport returns every FAQ, inactive ones included
+func (r *VectorRepository) GetFAQForExport() ([]models.FAQEmbedding, error) {
+	var faqs []models.FAQEmbedding
+	err := r.db.Order("id").Find(&faqs).Error
+	return faqs, err
+}
+
+// ==================== EMBEDDING MODEL ====================
+
+func (r *VectorRepository) GetEmbeddingModel() (*models.EmbeddingModel, error) {
+	var model models.EmbeddingModel
+	err := r.db.Order("id").First(&model).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &model, err
+}
+
+func (r *VectorRepository) SaveEmbeddingModel(model *models.EmbeddingModel) error {
+	return r.db.Save(model).Error
+}
+
+func (r *VectorRepository) FindCachedEmbeddings(hashes []string) ([]models.EmbeddingCacheEntry, error) {
+	var entries []models.EmbeddingCacheEntry
+	err := r.db.Where("hash IN ?", hashes).Find(&entries).Error
+	return entries, err
+}
+
+func (r *VectorRepository) SaveCachedEmbeddings(entries []models.EmbeddingCacheEntry) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(entries, 100).Error
+}
+
+// TouchCachedEmbeddings marks cached embeddings as used. Entries already used since
+// staleBefore are skipped, so hot entries are not rewritten on every lookup.
+func (r *VectorRepository) TouchCachedEmbeddings(hashes []string, now, staleBefore time.Time) error {
+	return r.db.Model(&models.EmbeddingCacheEntry{}).
+		Where("hash IN ? AND last_used_at < ?", hashes, staleBefore).
+		Update("last_used_at", now).Error
+}
+
+// DeleteCachedEmbeddingsUnusedSince drops cached embeddings not used since the given time
+func (r *VectorRepository) DeleteCachedEmbeddingsUnusedSince(before time.Time) (int64, error) {
+	result := r.db.Where("last_used_at < ?", before).Delete(&models.EmbeddingCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteCachedEmbeddingsExcept drops cached embeddings of every other model
+func (r *VectorRepository) DeleteCachedEmbeddingsExcept(model string) error {
+	return r.db.Where("model <> ?", model).Delete(&models.EmbeddingCacheEntry{}).Error
+}
+
+// EmbeddingRow is the text behind one stored vector
+type EmbeddingRow struct {
+	ID   uint
+	Text string
+}
+
+// AddEmbeddingColumn adds an empty vector column next to an existing one
+func (r *VectorRepository) AddEmbeddingColumn(table, column string, dimensions int) error {
+	return r.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s vector(%d)", table, column, dimensions)).Error
+}
+
+// FindRowsToEmbed returns rows after afterID whose target column is still empty
+func (r *VectorRepository) FindRowsToEmbed(col models.EmbeddingColumn, target string, afterID uint, limit int) ([]EmbeddingRow, error) {
+	var rows []EmbeddingRow
+	err := r.db.Raw(fmt.Sprintf(
+		"SELECT id, COALESCE(%s, '') AS text FROM %s WHERE id > ? AND %s IS NULL ORDER BY id LIMIT ?",
+		col.Source, col.Table, target,
+	), afterID, limit).Scan(&rows).Error
+	return rows, err
+}
+
+func (r *VectorRepository) SetEmbedding(table, column string, id uint, embedding pgvector.Vector) error {
+	return r.db.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", table, column), embedding, id).Error
+}
+
+// SwapEmbeddingColumns replaces each vector column with its re-embedded copy in one transaction
+func (r *VectorRepository) SwapEmbeddingColumns(columns []models.EmbeddingColumn, suffix string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, col := range columns {
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", col.Table, col.Column)).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s%s TO %s", col.Table, col.Column, suffix, col.Column)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ==================== FULL-TEXT SEARCH ====================
+
+// SearchKnowledgeFullText ranks knowledge by the generated search_vector column.
+// tsQuery is a to_tsquery expression such as "harga | f003".
+func (r *VectorRepository) SearchKnowledgeFullText(tsQuery string, embedding pgvector.Vector, limit int) ([]models.KnowledgeBase, error) {
 	var results []models.KnowledgeBase
 
-	searchTerm := fmt.Sprintf("%%%s%%", query)
-	err := r.db.Where("active = ?", true).
-		Where("title ILIKE ? OR content ILIKE ? OR tags ILIKE ?",
-			searchTerm, searchTerm, searchTerm).
-		Limit(limit).
-		Find(&results).Error
+	query := `
+		SELECT *,
+		       ts_rank_cd(search_vector, to_tsquery('simple', ?)) as text_rank,
+		       1 - (embedding <=> ?) as similarity
+		FROM knowledge_bases
+		WHERE active = true AND search_vector @@ to_tsquery('simple', ?)
+		ORDER BY text_rank DESC
+		LIMIT ?
+	`
 
+	err := r.db.Raw(query, tsQuery, embedding, tsQuery, limit).Scan(&results).Error
 	return results, err
 }
+
+func (r *VectorRepository) SearchFAQFullText(tsQuery string, embedding pgvector.Vector, limit int) ([]models.FAQEmbedding, error) {
+	var results []models.FAQEmbedding
+
+	query := `
+		SELECT *,
+		       ts_rank_cd(search_vector, to_tsquery('simple', ?)) as text_rank,
+		       1 - (embedding <=> ?) as similarity
+		FROM faq_embeddings
+		WHERE active = true AND search_vector @@ to_tsquery('simple', ?)
+		ORDER BY text_rank DESC
+		LIMIT ?
+	`
+
+	err := r.db.Raw(query, tsQuery, embedding, tsQuery, limit).Scan(&results).Error
+	return results, err
+}
+
+// ==================== VECTOR INDEXES ====================
+
+// GetVectorIndexes reports the HNSW index of every searched embedding column
+func (r *VectorRepository) GetVectorIndexes() ([]models.VectorIndexInfo, error) {
+	var indexes []models.VectorIndexInfo
+	for _, col := range models.EmbeddingColumns {
+		if !col.Searched {
+			continue
+		}
+
+		info := models.VectorIndexInfo{Table: col.Table, Column: col.Column, Name: col.IndexName()}
+		err := r.db.Raw(`
+			SELECT true AS exists, i.indisvalid AS valid, pg_relation_size(c.oid) AS size_bytes,
+			       COALESCE(array_to_string(c.reloptions, ', '), '') AS options
+			FROM pg_class c
+			JOIN pg_index i ON i.indexrelid = c.oid
+			WHERE c.relname = ?
+		`, col.IndexName()).Scan(&info).Error
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, info)
+	}
+	return indexes, nil
+}
+
+// RebuildVectorIndex replaces a column's HNSW index with one built with the given parameters.
+// The replacement is built concurrently under another name and only then swapped in, so
+// searches keep their index and writes are not blocked while it builds.
+func (r *VectorRepository) RebuildVectorIndex(col models.EmbeddingColumn, m, efConstruction int) error {
+	// An earlier build that was interrupted leaves an invalid index behind
+	if err := r.db.Exec(fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", col.RebuildIndexName())).Error; err != nil {
+		return err
+	}
+	if err := r.db.Exec(col.CreateIndexSQL(col.RebuildIndexName(), m, efConstruction, true)).Error; err != nil {
+		r.db.Exec(fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", col.RebuildIndexName()))
+		return err
+	}
+
+	if err := r.db.Exec(fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", col.IndexName())).Error; err != nil {
+		return err
+	}
+	return r.db.Exec(fmt.Sprintf("ALTER INDEX %s RENAME TO %s", col.RebuildIndexName(), col.IndexName())).Error
+}
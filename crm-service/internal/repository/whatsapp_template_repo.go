@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type WhatsAppTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewWhatsAppTemplateRepository(db *gorm.DB) *WhatsAppTemplateRepository {
+	return &WhatsAppTemplateRepository{db: db}
+}
+
+// FindAll returns templates, optionally filtered by status
+func (r *WhatsAppTemplateRepository) FindAll(status string) ([]models.WhatsAppTemplate, error) {
+	var templates []models.WhatsAppTemplate
+	query := r.db.Order("name ASC, language ASC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&templates).Error
+	return templates, err
+}
+
+func (r *WhatsAppTemplateRepository) FindByID(id uint) (*models.WhatsAppTemplate, error) {
+	var template models.WhatsAppTemplate
+	err := r.db.First(&template, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &template, err
+}
+
+func (r *WhatsAppTemplateRepository) FindByNameAndLanguage(name, language string) (*models.WhatsAppTemplate, error) {
+	var template models.WhatsAppTemplate
+	err := r.db.Where("name = ? AND language = ?", name, language).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &template, err
+}
+
+func (r *WhatsAppTemplateRepository) Save(template *models.WhatsAppTemplate) error {
+	return r.db.Save(template).Error
+}
+
+// MarkMissing flags templates that were not returned by the latest sync as deleted
+func (r *WhatsAppTemplateRepository) MarkMissing(syncedAt time.Time) (int64, error) {
+	result := r.db.Model(&models.WhatsAppTemplate{}).
+		Where("synced_at IS NULL OR synced_at < ?", syncedAt).
+		Where("status <> ?", "DELETED").
+		Update("status", "DELETED")
+	return result.RowsAffected, result.Error
+}
@@ -13,6 +13,12 @@ func SetupVectorRoutes(api fiber.Router, h *handlers.VectorHandler) {
 	vectors.Get("/knowledge", h.GetAllKnowledge)
 	vectors.Get("/knowledge/search", h.SearchKnowledge)
 
+	// Knowledge Documents (chunked uploads)
+	vectors.Post("/knowledge/documents", h.IngestDocument)
+	vectors.Get("/knowledge/documents", h.GetAllDocuments)
+	vectors.Get("/knowledge/documents/:id", h.GetDocument)
+	vectors.Delete("/knowledge/documents/:id", h.DeleteDocument)
+
 	// FAQ
 	vectors.Post("/faq", h.AddFAQ)
 	vectors.Get("/faq", h.GetAllFAQ)
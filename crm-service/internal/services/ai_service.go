@@ -28,23 +28,42 @@ func NewAIService(repo *repository.AIRepository, vectorService *VectorService, c
 	}
 }
 
+// AIReply is a generated answer and what the retrieval found for it
+type AIReply struct {
+	Text          string
+	NoGoodContext bool // The knowledge base had nothing relevant
+	NeedsAgent    bool // No good context and RAG_HANDOFF_WITHOUT_CONTEXT is on
+}
+
 // GenerateResponse generates AI response with RAG
 func (s *AIService) GenerateResponse(ctx context.Context, userMessage string, contactName string, contactID uint) (string, error) {
+	reply, err := s.GenerateReply(ctx, userMessage, contactName, contactID, "")
+	if err != nil {
+		return "", err
+	}
+	return reply.Text, nil
+}
+
+// GenerateReply generates an AI response with RAG and reports whether relevant context was found.
+// productContext lists catalog products with live price and stock, or is empty.
+func (s *AIService) GenerateReply(ctx context.Context, userMessage string, contactName string, contactID uint, productContext string) (*AIReply, error) {
 	// 1. Build RAG context from vector search
 	ragContext := ""
+	noGoodContext := false
 	if s.vectorService != nil {
-		if context, err := s.vectorService.BuildRAGContext(userMessage); err == nil {
-			ragContext = context
+		if result, err := s.vectorService.RetrieveContext(userMessage); err == nil {
+			ragContext = result.Context
+			noGoodContext = result.NoGoodContext && productContext == ""
 		}
 	}
 
-	// 2. Build system prompt with RAG context
-	systemPrompt := s.buildSystemPrompt(ragContext)
+	// 2. Build system prompt with RAG and product context
+	systemPrompt := s.buildSystemPrompt(ragContext, productContext, noGoodContext)
 
 	// 3. Generate response with OpenAI
 	response, err := s.generateWithOpenAI(ctx, systemPrompt, userMessage, contactName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 4. Save chat history with embeddings (async)
@@ -60,10 +79,14 @@ func (s *AIService) GenerateResponse(ctx context.Context, userMessage string, co
 		}()
 	}
 
-	return response, nil
+	return &AIReply{
+		Text:          response,
+		NoGoodContext: noGoodContext,
+		NeedsAgent:    noGoodContext && s.config.RAG.HandoffWithoutContext,
+	}, nil
 }
 
-func (s *AIService) buildSystemPrompt(ragContext string) string {
+func (s *AIService) buildSystemPrompt(ragContext, productContext string, noGoodContext bool) string {
 	basePrompt := `Anda adalah AI assistant untuk Divine CRM, sebuah platform CRM berbasis AI.
 
 Tugas Anda:
@@ -80,6 +103,15 @@ Tone: Ramah, profesional, helpful, natural (seperti chat biasa)`
 		basePrompt += "\n\n" + ragContext
 		basePrompt += "\n\nGunakan informasi di atas untuk menjawab pertanyaan customer."
 	}
+	if productContext != "" {
+		basePrompt += "\n\n" + productContext
+		basePrompt += "\nHarga dan stok di atas adalah data terkini dan lebih diutamakan daripada knowledge base. " +
+			"Jangan menawarkan produk yang stoknya habis."
+	}
+	if noGoodContext {
+		basePrompt += "\n\nKnowledge base tidak memiliki informasi yang relevan untuk pertanyaan ini. " +
+			"Jangan menebak harga, stok, atau kebijakan; sampaikan bahwa tim kami akan membantu."
+	}
 
 	return basePrompt
 }
@@ -0,0 +1,824 @@
+package services
+
+import (
+	"bytes"
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	idleWatchWindow = 7 * 24 * time.Hour // Conversations idle longer than this are ignored
+	idleMinimum     = time.Minute
+)
+
+var (
+	automationTriggers = map[string]bool{
+		models.TriggerMessageReceived: true,
+		models.TriggerContactCreated:  true,
+		models.TriggerChatIdle:        true,
+		models.TriggerLabelAdded:      true,
+	}
+	automationFields = map[string]bool{
+		"channel": true, "temperature": true, "contact_status": true, "message": true, "label": true,
+		"idle_minutes": true, "time": true, "day_of_week": true, "business_hours": true,
+	}
+	automationOperators = map[string]bool{
+		"equals": true, "not_equals": true, "in": true, "contains": true, "regex": true,
+		"gte": true, "lte": true, "between": true,
+	}
+	automationActions = map[string]bool{
+		"reply": true, "add_label": true, "assign": true, "set_temperature": true, "set_status": true, "webhook": true,
+	}
+)
+
+type AutomationService struct {
+	repo                 *repository.AutomationRepository
+	chatRepo             *repository.ChatRepository
+	contactRepo          *repository.ContactRepository
+	chatLabelRepo        *repository.ChatLabelRepository
+	businessHoursService *BusinessHoursService
+	whatsappService      *WhatsAppService
+	instagramService     *InstagramService
+	telegramService      *TelegramService
+	logger               *utils.Logger
+	client               *http.Client
+}
+
+func NewAutomationService(
+	repo *repository.AutomationRepository,
+	chatRepo *repository.ChatRepository,
+	contactRepo *repository.ContactRepository,
+	chatLabelRepo *repository.ChatLabelRepository,
+	businessHoursService *BusinessHoursService,
+	whatsappService *WhatsAppService,
+	instagramService *InstagramService,
+	telegramService *TelegramService,
+	logger *utils.Logger,
+) *AutomationService {
+	return &AutomationService{
+		repo:                 repo,
+		chatRepo:             chatRepo,
+		contactRepo:          contactRepo,
+		chatLabelRepo:        chatLabelRepo,
+		businessHoursService: businessHoursService,
+		whatsappService:      whatsappService,
+		instagramService:     instagramService,
+		telegramService:      telegramService,
+		logger:               logger,
+		client:               &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AutomationEvent carries everything rules can match against
+type AutomationEvent struct {
+	Trigger  string              `json:"trigger"`
+	Contact  *models.Contact     `json:"contact,omitempty"`
+	Chat     *models.ChatMessage `json:"chat,omitempty"`
+	Message  string              `json:"message,omitempty"`
+	LabelIDs []uint              `json:"label_ids,omitempty"`
+	IdleFor  time.Duration       `json:"-"`
+	Time     time.Time           `json:"time"`
+}
+
+// AutomationResult summarises the rules that ran for an event
+type AutomationResult struct {
+	Matched []uint
+	Reply   string // Collected reply text when the caller sends replies itself
+	Handled bool   // A reply or assign action ran, so the caller should not answer the message
+}
+
+// ConditionResult is the outcome of a single condition during evaluation
+type ConditionResult struct {
+	models.RuleCondition
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RuleEvaluation is the dry-run outcome of a rule
+type RuleEvaluation struct {
+	RuleID     uint                `json:"rule_id"`
+	RuleName   string              `json:"rule_name"`
+	Matched    bool                `json:"matched"`
+	Conditions []ConditionResult   `json:"conditions"`
+	Actions    []models.RuleAction `json:"actions"`
+}
+
+// ==================== RULES ====================
+
+func (s *AutomationService) GetAll() ([]models.AutomationRule, error) {
+	return s.repo.FindAll()
+}
+
+func (s *AutomationService) GetByID(id uint) (*models.AutomationRule, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *AutomationService) Create(rule *models.AutomationRule) error {
+	if err := ValidateRule(rule); err != nil {
+		return err
+	}
+	s.logger.Info("Creating automation rule", "name", rule.Name, "trigger", rule.Trigger)
+	return s.repo.Create(rule)
+}
+
+func (s *AutomationService) Update(rule *models.AutomationRule) error {
+	if err := ValidateRule(rule); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.FindByID(rule.ID)
+	if err != nil {
+		return errors.New("rule not found")
+	}
+	rule.RunCount = existing.RunCount
+	rule.LastRunAt = existing.LastRunAt
+	rule.CreatedAt = existing.CreatedAt
+
+	s.logger.Info("Updating automation rule", "id", rule.ID)
+	return s.repo.Update(rule)
+}
+
+func (s *AutomationService) Delete(id uint) error {
+	s.logger.Info("Deleting automation rule", "id", id)
+	return s.repo.Delete(id)
+}
+
+func (s *AutomationService) GetLogs(ruleID uint, limit int) ([]models.AutomationLog, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.repo.FindLogs(ruleID, limit)
+}
+
+// ValidateRule checks trigger, conditions and actions before a rule is stored
+func ValidateRule(rule *models.AutomationRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return errors.New("name is required")
+	}
+	if !automationTriggers[rule.Trigger] {
+		return fmt.Errorf("unknown trigger: %s", rule.Trigger)
+	}
+	if len(rule.Actions) == 0 {
+		return errors.New("at least one action is required")
+	}
+
+	for _, cond := range rule.Conditions {
+		if !automationFields[cond.Field] {
+			return fmt.Errorf("unknown condition field: %s", cond.Field)
+		}
+		if !automationOperators[cond.Operator] {
+			return fmt.Errorf("unknown condition operator: %s", cond.Operator)
+		}
+		if cond.Operator == "regex" {
+			if _, err := regexp.Compile(cond.Value); err != nil {
+				return fmt.Errorf("invalid regex %q: %v", cond.Value, err)
+			}
+		}
+		if cond.Field == "time" {
+			if _, _, err := parseClockRange(cond.Value); err != nil {
+				return err
+			}
+		}
+		if cond.Operator == "between" {
+			switch cond.Field {
+			case "time":
+			case "idle_minutes", "day_of_week":
+				if _, _, err := parseNumberRange(cond.Value); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("operator between is not supported for %s", cond.Field)
+			}
+		}
+	}
+
+	for _, action := range rule.Actions {
+		if !automationActions[action.Type] {
+			return fmt.Errorf("unknown action type: %s", action.Type)
+		}
+		switch action.Type {
+		case "add_label":
+			if _, err := strconv.ParseUint(action.Value, 10, 64); err != nil {
+				return fmt.Errorf("add_label expects a label ID, got %q", action.Value)
+			}
+		case "webhook":
+			u, err := url.Parse(action.Value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("webhook expects an http(s) URL, got %q", action.Value)
+			}
+		default:
+			if strings.TrimSpace(action.Value) == "" {
+				return fmt.Errorf("%s requires a value", action.Type)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ==================== EXECUTION ====================
+
+// Fire runs every active rule for the event's trigger.
+// With deferReply set, reply actions are collected in the result instead of being sent.
+func (s *AutomationService) Fire(event *AutomationEvent, deferReply bool) *AutomationResult {
+	result := &AutomationResult{}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	rules, err := s.repo.FindActiveByTrigger(event.Trigger)
+	if err != nil {
+		s.logger.Error("Failed to load automation rules", "trigger", event.Trigger, "error", err)
+		return result
+	}
+
+	s.runRules(rules, event, deferReply, result)
+	return result
+}
+
+func (s *AutomationService) runRules(rules []models.AutomationRule, event *AutomationEvent, deferReply bool, result *AutomationResult) {
+	for _, rule := range rules {
+		// Idle rules fire once per conversation message
+		if event.Trigger == models.TriggerChatIdle && event.Chat != nil {
+			if ran, err := s.repo.HasRun(rule.ID, event.Chat.ID); err != nil || ran {
+				continue
+			}
+		}
+
+		evaluation := s.evaluateRule(rule, event)
+		if !evaluation.Matched {
+			continue
+		}
+
+		s.logger.Info("⚙️ Automation rule matched", "rule_id", rule.ID, "name", rule.Name, "trigger", event.Trigger)
+		summary, reply, handled, execErr := s.executeActions(rule, event, deferReply)
+		if reply != "" {
+			result.Reply = reply
+		}
+		if handled {
+			result.Handled = true
+		}
+		result.Matched = append(result.Matched, rule.ID)
+
+		entry := &models.AutomationLog{
+			RuleID:  rule.ID,
+			Trigger: event.Trigger,
+			Actions: strings.Join(summary, "; "),
+		}
+		if event.Contact != nil {
+			entry.ContactID = event.Contact.ID
+		}
+		if event.Chat != nil {
+			entry.ChatMessageID = event.Chat.ID
+		}
+		if execErr != nil {
+			entry.Error = execErr.Error()
+			s.logger.Error("Automation rule failed", "rule_id", rule.ID, "error", execErr)
+		}
+		if err := s.repo.CreateLog(entry); err != nil {
+			s.logger.Warn("Failed to write automation log", "error", err)
+		}
+		if err := s.repo.IncrementRunCount(rule.ID); err != nil {
+			s.logger.Warn("Failed to update rule run count", "error", err)
+		}
+
+		if rule.StopProcessing {
+			break
+		}
+	}
+}
+
+// executeActions performs every action of a matched rule, continuing past failures.
+// handled reports whether a reply or assign action took over the conversation.
+func (s *AutomationService) executeActions(rule models.AutomationRule, event *AutomationEvent, deferReply bool) ([]string, string, bool, error) {
+	var summary []string
+	var errs []string
+	reply := ""
+	handled := false
+
+	for _, action := range rule.Actions {
+		var err error
+
+		switch action.Type {
+		case "reply":
+			text := s.interpolate(action.Value, event)
+			if deferReply {
+				reply = text
+			} else {
+				err = s.sendToContact(event.Contact, text)
+			}
+
+		case "add_label":
+			err = s.addLabel(event, action.Value)
+
+		case "assign":
+			if event.Chat == nil {
+				err = errors.New("no conversation to assign")
+				break
+			}
+			event.Chat.AssignedTo = "Human"
+			event.Chat.AssignedAgent = action.Value
+			event.Chat.Status = "Assigned"
+			err = s.chatRepo.UpdateAssignment(event.Chat.ID, event.Chat.AssignedTo, event.Chat.AssignedAgent, event.Chat.Status)
+
+		case "set_temperature":
+			if event.Contact == nil {
+				err = errors.New("no contact to update")
+				break
+			}
+			event.Contact.Temperature = action.Value
+			err = s.contactRepo.UpdateField(event.Contact.ID, "temperature", action.Value)
+
+		case "set_status":
+			if event.Contact == nil {
+				err = errors.New("no contact to update")
+				break
+			}
+			event.Contact.ContactStatus = action.Value
+			err = s.contactRepo.UpdateField(event.Contact.ID, "contact_status", action.Value)
+
+		case "webhook":
+			err = s.queueWebhook(action.Value, rule, event)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", action.Type, err))
+			summary = append(summary, action.Type+" (failed)")
+			continue
+		}
+		if action.Type == "reply" || action.Type == "assign" {
+			handled = true
+		}
+		summary = append(summary, action.Type)
+	}
+
+	if len(errs) > 0 {
+		return summary, reply, handled, errors.New(strings.Join(errs, "; "))
+	}
+	return summary, reply, handled, nil
+}
+
+func (s *AutomationService) addLabel(event *AutomationEvent, value string) error {
+	if event.Chat == nil {
+		return errors.New("no conversation to label")
+	}
+
+	labelID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid label ID: %s", value)
+	}
+
+	labels, err := s.chatLabelRepo.FindByIDs([]uint{uint(labelID)})
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("label %d does not exist", labelID)
+	}
+
+	// Labels added by automation do not fire label_added again, to avoid loops
+	return s.chatRepo.AddLabels([]uint{event.Chat.ID}, []uint{uint(labelID)})
+}
+
+func (s *AutomationService) sendToContact(contact *models.Contact, message string) error {
+	if contact == nil {
+		return errors.New("no contact to reply to")
+	}
+
+	switch contact.Channel {
+	case "WhatsApp":
+		// Free-form messages are rejected by WhatsApp outside the customer service window
+		if !WithinServiceWindow(contact, time.Now()) {
+			return errors.New("contact is outside the 24-hour WhatsApp window")
+		}
+		return s.whatsappService.SendMessage(contact.ChannelID, message)
+	case "Instagram":
+		return s.instagramService.SendMessage(contact.ChannelID, message)
+	case "Telegram":
+		return s.telegramService.SendMessage(contact.ChannelID, message)
+	}
+
+	return fmt.Errorf("unsupported channel: %s", contact.Channel)
+}
+
+// queueWebhook snapshots the event and posts it in the background so a slow
+// endpoint never holds up message processing
+func (s *AutomationService) queueWebhook(target string, rule models.AutomationRule, event *AutomationEvent) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"rule_id":   rule.ID,
+		"rule_name": rule.Name,
+		"event":     event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	go func() {
+		if err := s.callWebhook(target, payload); err != nil {
+			s.logger.Error("Automation webhook failed", "rule_id", rule.ID, "url", target, "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *AutomationService) callWebhook(target string, payload []byte) error {
+	req, err := http.NewRequest("POST", target, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *AutomationService) interpolate(text string, event *AutomationEvent) string {
+	if event.Contact == nil {
+		return text
+	}
+	text = strings.ReplaceAll(text, "{name}", event.Contact.Name)
+	text = strings.ReplaceAll(text, "{code}", event.Contact.Code)
+	text = strings.ReplaceAll(text, "{channel}", event.Contact.Channel)
+	return text
+}
+
+// ==================== IDLE WATCHER ====================
+
+// StartIdleWatcher periodically fires chat_idle rules for unresolved conversations
+func (s *AutomationService) StartIdleWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.checkIdleChats()
+	}
+}
+
+func (s *AutomationService) checkIdleChats() {
+	rules, err := s.repo.FindActiveByTrigger(models.TriggerChatIdle)
+	if err != nil {
+		s.logger.Error("Failed to load idle rules", "error", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	now := time.Now()
+	chats, err := s.chatRepo.FindIdleConversations(now.Add(-idleWatchWindow), now.Add(-idleMinimum))
+	if err != nil {
+		s.logger.Error("Failed to load idle conversations", "error", err)
+		return
+	}
+
+	for i := range chats {
+		chat := &chats[i]
+		event := &AutomationEvent{
+			Trigger: models.TriggerChatIdle,
+			Contact: &chat.Contact,
+			Chat:    chat,
+			Message: chat.Message,
+			IdleFor: now.Sub(chat.CreatedAt),
+			Time:    now,
+		}
+		s.runRules(rules, event, false, &AutomationResult{})
+	}
+}
+
+// ==================== DRY RUN ====================
+
+// DryRunRequest describes a hypothetical event to evaluate rules against
+type DryRunRequest struct {
+	RuleID      uint                   `json:"rule_id"`
+	Rule        *models.AutomationRule `json:"rule"`
+	Trigger     string                 `json:"trigger"`
+	ContactID   uint                   `json:"contact_id"`
+	ChatID      uint                   `json:"chat_id"`
+	Message     string                 `json:"message"`
+	Channel     string                 `json:"channel"`
+	LabelIDs    []uint                 `json:"label_ids"`
+	IdleMinutes int                    `json:"idle_minutes"`
+	Time        *time.Time             `json:"time"`
+}
+
+// DryRun evaluates rules against a hypothetical event without executing any action
+func (s *AutomationService) DryRun(req *DryRunRequest) ([]RuleEvaluation, error) {
+	event := &AutomationEvent{
+		Trigger:  req.Trigger,
+		Message:  req.Message,
+		LabelIDs: req.LabelIDs,
+		IdleFor:  time.Duration(req.IdleMinutes) * time.Minute,
+		Time:     time.Now(),
+	}
+	if req.Time != nil {
+		event.Time = *req.Time
+	}
+
+	if req.ContactID != 0 {
+		contact, err := s.contactRepo.FindByID(req.ContactID)
+		if err != nil || contact == nil {
+			return nil, errors.New("contact not found")
+		}
+		event.Contact = contact
+	}
+	if req.ChatID != 0 {
+		chat, err := s.chatRepo.FindByID(req.ChatID)
+		if err != nil {
+			return nil, errors.New("chat not found")
+		}
+		event.Chat = chat
+		if event.Contact == nil {
+			event.Contact = &chat.Contact
+		}
+	}
+	if req.Channel != "" {
+		if event.Contact == nil {
+			event.Contact = &models.Contact{}
+		}
+		contact := *event.Contact
+		contact.Channel = req.Channel
+		event.Contact = &contact
+	}
+
+	var rules []models.AutomationRule
+	switch {
+	case req.Rule != nil:
+		if err := ValidateRule(req.Rule); err != nil {
+			return nil, err
+		}
+		rules = []models.AutomationRule{*req.Rule}
+	case req.RuleID != 0:
+		rule, err := s.repo.FindByID(req.RuleID)
+		if err != nil {
+			return nil, errors.New("rule not found")
+		}
+		rules = []models.AutomationRule{*rule}
+	default:
+		if !automationTriggers[req.Trigger] {
+			return nil, fmt.Errorf("unknown trigger: %s", req.Trigger)
+		}
+		active, err := s.repo.FindActiveByTrigger(req.Trigger)
+		if err != nil {
+			return nil, err
+		}
+		rules = active
+	}
+
+	evaluations := make([]RuleEvaluation, 0, len(rules))
+	for _, rule := range rules {
+		if event.Trigger == "" {
+			event.Trigger = rule.Trigger
+		}
+		evaluations = append(evaluations, s.evaluateRule(rule, event))
+	}
+
+	return evaluations, nil
+}
+
+// ==================== CONDITIONS ====================
+
+func (s *AutomationService) evaluateRule(rule models.AutomationRule, event *AutomationEvent) RuleEvaluation {
+	evaluation := RuleEvaluation{
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		Matched:  rule.Trigger == event.Trigger,
+		Actions:  rule.Actions,
+	}
+
+	for _, cond := range rule.Conditions {
+		matched, err := s.evaluateCondition(cond, event)
+		result := ConditionResult{RuleCondition: cond, Matched: matched}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		evaluation.Conditions = append(evaluation.Conditions, result)
+
+		// All conditions must match
+		if !matched {
+			evaluation.Matched = false
+		}
+	}
+
+	return evaluation
+}
+
+func (s *AutomationService) evaluateCondition(cond models.RuleCondition, event *AutomationEvent) (bool, error) {
+	switch cond.Field {
+	case "channel":
+		channel := ""
+		if event.Contact != nil {
+			channel = event.Contact.Channel
+		} else if event.Chat != nil {
+			channel = event.Chat.Channel
+		}
+		return compareText(channel, cond)
+
+	case "temperature":
+		if event.Contact == nil {
+			return false, errors.New("event has no contact")
+		}
+		return compareText(event.Contact.Temperature, cond)
+
+	case "contact_status":
+		if event.Contact == nil {
+			return false, errors.New("event has no contact")
+		}
+		return compareText(event.Contact.ContactStatus, cond)
+
+	case "message":
+		return compareText(event.Message, cond)
+
+	case "label":
+		labelIDs := event.LabelIDs
+		if len(labelIDs) == 0 && event.Chat != nil {
+			for _, label := range event.Chat.ChatLabels {
+				labelIDs = append(labelIDs, label.ID)
+			}
+		}
+		expected := make(map[string]bool)
+		for _, option := range strings.Split(cond.Value, ",") {
+			expected[strings.TrimSpace(option)] = true
+		}
+		hasLabel := false
+		for _, id := range labelIDs {
+			if expected[strconv.FormatUint(uint64(id), 10)] {
+				hasLabel = true
+				break
+			}
+		}
+
+		switch cond.Operator {
+		case "equals", "in", "contains":
+			return hasLabel, nil
+		case "not_equals":
+			return !hasLabel, nil
+		}
+		return false, fmt.Errorf("operator %s is not supported for label", cond.Operator)
+
+	case "idle_minutes":
+		return compareNumber(event.IdleFor.Minutes(), cond)
+
+	case "time":
+		start, end, err := parseClockRange(cond.Value)
+		if err != nil {
+			return false, err
+		}
+		local := event.Time.In(s.location())
+		minutes := local.Hour()*60 + local.Minute()
+		inside := minutes >= start && minutes < end
+		if start > end {
+			// Overnight range such as 22:00-06:00
+			inside = minutes >= start || minutes < end
+		}
+
+		switch cond.Operator {
+		case "between", "equals", "in":
+			return inside, nil
+		case "not_equals":
+			return !inside, nil
+		}
+		return false, fmt.Errorf("operator %s is not supported for time", cond.Operator)
+
+	case "day_of_week":
+		day := event.Time.In(s.location()).Weekday()
+		if cond.Operator == "between" {
+			return compareNumber(float64(day), cond)
+		}
+		return compareText(strconv.Itoa(int(day)), cond)
+
+	case "business_hours":
+		if s.businessHoursService == nil {
+			return false, errors.New("business hours not configured")
+		}
+		state := "closed"
+		if s.businessHoursService.IsOpen(event.Time) {
+			state = "open"
+		}
+		return compareText(state, cond)
+	}
+
+	return false, fmt.Errorf("unknown condition field: %s", cond.Field)
+}
+
+// location is the business hours timezone, so time and day_of_week match the
+// same clock the team works by rather than the server's
+func (s *AutomationService) location() *time.Location {
+	if s.businessHoursService == nil {
+		return time.Local
+	}
+	return s.businessHoursService.Location()
+}
+
+func compareText(actual string, cond models.RuleCondition) (bool, error) {
+	actualLower := strings.ToLower(strings.TrimSpace(actual))
+	expectedLower := strings.ToLower(strings.TrimSpace(cond.Value))
+
+	switch cond.Operator {
+	case "equals":
+		return actualLower == expectedLower, nil
+	case "not_equals":
+		return actualLower != expectedLower, nil
+	case "contains":
+		return strings.Contains(actualLower, expectedLower), nil
+	case "in":
+		for _, option := range strings.Split(expectedLower, ",") {
+			if strings.TrimSpace(option) == actualLower {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "regex":
+		re, err := regexp.Compile(cond.Value)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(actual), nil
+	}
+
+	return false, fmt.Errorf("operator %s is not supported for %s", cond.Operator, cond.Field)
+}
+
+func compareNumber(actual float64, cond models.RuleCondition) (bool, error) {
+	if cond.Operator == "between" {
+		low, high, err := parseNumberRange(cond.Value)
+		if err != nil {
+			return false, err
+		}
+		return actual >= low && actual <= high, nil
+	}
+
+	expected, err := strconv.ParseFloat(strings.TrimSpace(cond.Value), 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid number: %s", cond.Value)
+	}
+
+	switch cond.Operator {
+	case "gte":
+		return actual >= expected, nil
+	case "lte":
+		return actual <= expected, nil
+	case "equals":
+		return actual == expected, nil
+	}
+
+	return false, fmt.Errorf("operator %s is not supported for %s", cond.Operator, cond.Field)
+}
+
+// parseNumberRange parses an inclusive "min-max" range such as "1-5"
+func parseNumberRange(value string) (float64, float64, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("range must look like 10-60, got %q", value)
+	}
+
+	low, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start: %s", parts[0])
+	}
+	high, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end: %s", parts[1])
+	}
+	if low > high {
+		return 0, 0, fmt.Errorf("range start must not exceed its end, got %q", value)
+	}
+
+	return low, high, nil
+}
+
+// parseClockRange parses "HH:MM-HH:MM" into minutes since midnight
+func parseClockRange(value string) (int, int, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("time range must look like 09:00-17:00, got %q", value)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start time: %s", parts[0])
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end time: %s", parts[1])
+	}
+
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
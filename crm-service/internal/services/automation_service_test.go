@@ -0,0 +1,98 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"testing"
+	"time"
+)
+
+func TestCompareNumberBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		actual  float64
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"inside", 30, "10-60", true, false},
+		{"lower bound", 10, "10-60", true, false},
+		{"upper bound", 60, "10-60", true, false},
+		{"below", 9, "10-60", false, false},
+		{"above", 61, "10-60", false, false},
+		{"spaces", 3, " 1 - 5 ", true, false},
+		{"reversed", 3, "5-1", false, true},
+		{"not a range", 3, "5", false, true},
+		{"not a number", 3, "a-b", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compareNumber(tt.actual, models.RuleCondition{Field: "idle_minutes", Operator: "between", Value: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compareNumber(%v, %q) error = %v, wantErr %v", tt.actual, tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("compareNumber(%v, %q) = %v, want %v", tt.actual, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateTimeConditions(t *testing.T) {
+	s := &AutomationService{}
+	// Wednesday 23:30 server time
+	at := time.Date(2026, 10, 14, 23, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		cond models.RuleCondition
+		want bool
+	}{
+		{"overnight between", models.RuleCondition{Field: "time", Operator: "between", Value: "22:00-06:00"}, true},
+		{"office hours between", models.RuleCondition{Field: "time", Operator: "between", Value: "09:00-17:00"}, false},
+		{"outside office hours", models.RuleCondition{Field: "time", Operator: "not_equals", Value: "09:00-17:00"}, true},
+		{"weekday between", models.RuleCondition{Field: "day_of_week", Operator: "between", Value: "1-5"}, true},
+		{"weekend between", models.RuleCondition{Field: "day_of_week", Operator: "between", Value: "6-7"}, false},
+		{"day equals", models.RuleCondition{Field: "day_of_week", Operator: "equals", Value: "3"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.evaluateCondition(tt.cond, &AutomationEvent{Time: at})
+			if err != nil {
+				t.Fatalf("evaluateCondition(%+v) error = %v", tt.cond, err)
+			}
+			if got != tt.want {
+				t.Errorf("evaluateCondition(%+v) = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRuleBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		cond    models.RuleCondition
+		wantErr bool
+	}{
+		{"idle minutes", models.RuleCondition{Field: "idle_minutes", Operator: "between", Value: "10-60"}, false},
+		{"time", models.RuleCondition{Field: "time", Operator: "between", Value: "09:00-17:00"}, false},
+		{"day of week", models.RuleCondition{Field: "day_of_week", Operator: "between", Value: "1-5"}, false},
+		{"bad range", models.RuleCondition{Field: "idle_minutes", Operator: "between", Value: "60"}, true},
+		{"text field", models.RuleCondition{Field: "message", Operator: "between", Value: "a-z"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &models.AutomationRule{
+				Name:       "test",
+				Trigger:    models.TriggerMessageReceived,
+				Conditions: []models.RuleCondition{tt.cond},
+				Actions:    []models.RuleAction{{Type: "assign", Value: "Agent"}},
+			}
+			if err := ValidateRule(rule); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRule(%+v) error = %v, wantErr %v", tt.cond, err, tt.wantErr)
+			}
+		})
+	}
+}
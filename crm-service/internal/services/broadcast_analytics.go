@@ -0,0 +1,171 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CampaignReport is the outcome of one broadcast with its event timeline
+type CampaignReport struct {
+	History         *models.BroadcastHistory   `json:"history"`
+	Totals          *repository.CampaignTotals `json:"totals"`
+	Rates           CampaignRates              `json:"rates"`
+	InboundMessages int64                      `json:"inbound_messages"` // All replies, not just the first per contact
+	Interval        string                     `json:"interval"`
+	Timeline        []CampaignTimelinePoint    `json:"timeline"`
+}
+
+// CampaignRates are percentages of sent messages
+type CampaignRates struct {
+	Delivery   float64 `json:"delivery"`
+	Read       float64 `json:"read"`
+	Reply      float64 `json:"reply"`
+	Conversion float64 `json:"conversion"`
+}
+
+// CampaignTimelinePoint counts the events of one hour or day
+type CampaignTimelinePoint struct {
+	Time      time.Time `json:"time"`
+	Sent      int64     `json:"sent"`
+	Delivered int64     `json:"delivered"`
+	Read      int64     `json:"read"`
+	Replied   int64     `json:"replied"`
+	Converted int64     `json:"converted"`
+}
+
+// ReplyTarget returns the broadcast message an inbound message from the contact answers:
+// the most recent one sent within the reply window
+func (s *BroadcastService) ReplyTarget(contactID uint, at time.Time) *models.BroadcastRecipient {
+	recipient, err := s.repo.FindLatestSentRecipient(contactID, at.Add(-s.replyWindow))
+	if err != nil {
+		s.logger.Warn("Failed to find broadcast for reply", "contact_id", contactID, "error", err)
+		return nil
+	}
+	return recipient
+}
+
+// RecordReply counts the first reply to a broadcast message
+func (s *BroadcastService) RecordReply(recipient *models.BroadcastRecipient, at time.Time) {
+	if recipient.RepliedAt != nil {
+		return
+	}
+
+	if err := s.repo.SetRecipientTime(recipient.ID, "replied_at", at); err != nil {
+		s.logger.Warn("Failed to record broadcast reply", "recipient_id", recipient.ID, "error", err)
+		return
+	}
+	recipient.RepliedAt = &at
+
+	s.logger.Info("↩️ Broadcast reply", "history_id", recipient.HistoryID, "contact_id", recipient.ContactID)
+	s.refreshHistory(recipient.HistoryID)
+}
+
+// RecordConversion credits a conversion (the contact becoming a customer, an order, ...)
+// to the broadcast the contact received last. It reports whether a broadcast was credited.
+func (s *BroadcastService) RecordConversion(contactID uint, at time.Time) (bool, error) {
+	recipient, err := s.repo.FindLatestSentRecipient(contactID, at.Add(-s.conversionWindow))
+	if err != nil {
+		return false, err
+	}
+	if recipient == nil || recipient.ConvertedAt != nil {
+		return false, nil
+	}
+
+	if err := s.repo.SetRecipientTime(recipient.ID, "converted_at", at); err != nil {
+		return false, err
+	}
+
+	s.logger.Info("💰 Broadcast conversion", "history_id", recipient.HistoryID, "contact_id", contactID)
+	s.refreshHistory(recipient.HistoryID)
+	return true, nil
+}
+
+// GetReport returns the totals, rates and hourly or daily timeline of a broadcast
+func (s *BroadcastService) GetReport(historyID uint, interval string) (*CampaignReport, error) {
+	if interval == "" {
+		interval = "hour"
+	}
+	if interval != "hour" && interval != "day" {
+		return nil, fmt.Errorf("interval must be hour or day")
+	}
+
+	history, err := s.repo.FindHistoryByID(historyID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, fmt.Errorf("broadcast not found")
+	}
+
+	totals, err := s.repo.CountCampaignTotals(historyID)
+	if err != nil {
+		return nil, err
+	}
+
+	inbound, err := s.repo.CountAttributedMessages(historyID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline, err := s.campaignTimeline(historyID, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	sent := int(totals.Sent)
+	return &CampaignReport{
+		History: history,
+		Totals:  totals,
+		Rates: CampaignRates{
+			Delivery:   percentage(int(totals.Delivered), sent),
+			Read:       percentage(int(totals.Read), sent),
+			Reply:      percentage(int(totals.Replied), sent),
+			Conversion: percentage(int(totals.Converted), sent),
+		},
+		InboundMessages: inbound,
+		Interval:        interval,
+		Timeline:        timeline,
+	}, nil
+}
+
+// campaignTimeline merges the per-event bucket counts into one series
+func (s *BroadcastService) campaignTimeline(historyID uint, interval string) ([]CampaignTimelinePoint, error) {
+	points := map[int64]*CampaignTimelinePoint{} // By bucket start in Unix seconds
+	events := []struct {
+		column string
+		add    func(p *CampaignTimelinePoint, n int64)
+	}{
+		{"sent_at", func(p *CampaignTimelinePoint, n int64) { p.Sent += n }},
+		{"delivered_at", func(p *CampaignTimelinePoint, n int64) { p.Delivered += n }},
+		{"read_at", func(p *CampaignTimelinePoint, n int64) { p.Read += n }},
+		{"replied_at", func(p *CampaignTimelinePoint, n int64) { p.Replied += n }},
+		{"converted_at", func(p *CampaignTimelinePoint, n int64) { p.Converted += n }},
+	}
+
+	for _, event := range events {
+		counts, err := s.repo.CountRecipientTimeline(historyID, event.column, interval)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range counts {
+			point, ok := points[c.Bucket.Unix()]
+			if !ok {
+				point = &CampaignTimelinePoint{Time: c.Bucket}
+				points[c.Bucket.Unix()] = point
+			}
+			event.add(point, c.Count)
+		}
+	}
+
+	timeline := make([]CampaignTimelinePoint, 0, len(points))
+	for _, point := range points {
+		timeline = append(timeline, *point)
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+	return timeline, nil
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Broadcast recipient statuses
+const (
+	RecipientPending   = "Pending"
+	RecipientSending   = "Sending"
+	RecipientSent      = "Sent"
+	RecipientDelivered = "Delivered"
+	RecipientFailed    = "Failed"
+	RecipientSkipped   = "Skipped" // Opted out or suppressed after the broadcast was queued
+)
+
+// How a recipient was messaged
+const (
+	SendModeText     = "text"
+	SendModeTemplate = "template" // WhatsApp contact outside the 24h customer service window
+)
+
+const (
+	deliveryBatchSize   = 50
+	maxDeliveryAttempts = 3
+	deliveryClaimLease  = 15 * time.Minute // A recipient still Sending after this is assumed abandoned
+)
+
+var errRecipientSuppressed = errors.New("contact opted out of broadcasts")
+
+// BroadcastProgress is a broadcast with its per-status recipient counts
+type BroadcastProgress struct {
+	History *models.BroadcastHistory `json:"history"`
+	Counts  map[string]int64         `json:"counts"`
+}
+
+// StartDeliveryWorker sends queued broadcast recipients. Recipients are stored in
+// the database, so a broadcast interrupted by a restart resumes where it stopped.
+func (s *BroadcastService) StartDeliveryWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.reclaimStaleRecipients()
+		s.deliverPending()
+
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// reclaimStaleRecipients requeues recipients whose worker's claim has expired.
+// Claims held by other running instances are left alone.
+func (s *BroadcastService) reclaimStaleRecipients() {
+	reset, err := s.repo.ResetSendingRecipients(time.Now().Add(-deliveryClaimLease))
+	if err != nil {
+		s.logger.Error("Failed to recover interrupted deliveries", "error", err)
+	} else if reset > 0 {
+		s.logger.Warn("Resuming interrupted broadcast deliveries", "recipients", reset)
+	}
+}
+
+// wakeDelivery tells the worker there is new work without blocking the caller
+func (s *BroadcastService) wakeDelivery() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverPending drains every due recipient in batches
+func (s *BroadcastService) deliverPending() {
+	for {
+		recipients, err := s.repo.ClaimPendingRecipients(deliveryBatchSize, time.Now())
+		if err != nil {
+			s.logger.Error("Failed to claim broadcast recipients", "error", err)
+			return
+		}
+		if len(recipients) == 0 {
+			return
+		}
+
+		histories := map[uint]bool{}
+		// WhatsApp templates of the broadcast templates in this batch
+		waTemplates := map[uint]*uint{}
+		// Throughput is shaped by the outbound limiter inside each platform service
+		for i := range recipients {
+			s.deliverRecipient(&recipients[i], waTemplates)
+			histories[recipients[i].HistoryID] = true
+		}
+
+		for historyID := range histories {
+			s.refreshHistory(historyID)
+		}
+	}
+}
+
+func (s *BroadcastService) deliverRecipient(recipient *models.BroadcastRecipient, waTemplates map[uint]*uint) {
+	externalID, err := s.sendRecipient(recipient, waTemplates)
+	now := time.Now()
+
+	// Rate limits are not the recipient's fault: requeue without using up an attempt
+	var rateLimit *RateLimitError
+	if errors.As(err, &rateLimit) {
+		retryAt := now.Add(rateLimit.RetryAfter)
+		if rateLimit.RetryAfter < 5*time.Second {
+			retryAt = now.Add(5 * time.Second)
+		}
+		recipient.Status = RecipientPending
+		recipient.Error = err.Error()
+		recipient.NextAttemptAt = &retryAt
+		if err := s.repo.UpdateRecipient(recipient); err != nil {
+			s.logger.Error("Failed to update broadcast recipient", "recipient_id", recipient.ID, "error", err)
+		}
+		return
+	}
+
+	if errors.Is(err, errRecipientSuppressed) {
+		recipient.Status = RecipientSkipped
+		recipient.Error = err.Error()
+		recipient.NextAttemptAt = nil
+		if err := s.repo.UpdateRecipient(recipient); err != nil {
+			s.logger.Error("Failed to update broadcast recipient", "recipient_id", recipient.ID, "error", err)
+		}
+		return
+	}
+
+	recipient.Attempts++
+	if err == nil {
+		recipient.Status = RecipientSent
+		recipient.ExternalID = externalID
+		recipient.Error = ""
+		recipient.SentAt = &now
+		recipient.NextAttemptAt = nil
+	} else if recipient.Attempts < maxDeliveryAttempts {
+		// Back off 30s, 2m, ... before trying again
+		retryAt := now.Add(time.Duration(recipient.Attempts*recipient.Attempts) * 30 * time.Second)
+		recipient.Status = RecipientPending
+		recipient.Error = err.Error()
+		recipient.NextAttemptAt = &retryAt
+		s.logger.Warn("Broadcast send failed, will retry",
+			"recipient_id", recipient.ID,
+			"attempt", recipient.Attempts,
+			"error", err,
+		)
+	} else {
+		recipient.Status = RecipientFailed
+		recipient.Error = err.Error()
+		recipient.NextAttemptAt = nil
+		s.logger.Error("Failed to send broadcast", "contact", recipient.ContactName, "error", err)
+	}
+
+	if err := s.repo.UpdateRecipient(recipient); err != nil {
+		s.logger.Error("Failed to update broadcast recipient", "recipient_id", recipient.ID, "error", err)
+	}
+}
+
+// sendRecipient sends the broadcast message. WhatsApp only allows free-form text within
+// 24 hours of the contact's last message; outside it the broadcast's approved template is sent.
+func (s *BroadcastService) sendRecipient(recipient *models.BroadcastRecipient, waTemplates map[uint]*uint) (string, error) {
+	contact, err := s.contactRepo.FindByID(recipient.ContactID)
+	if err != nil {
+		return "", err
+	}
+	if contact == nil {
+		return "", fmt.Errorf("contact not found")
+	}
+
+	// The contact may have opted out while the broadcast was queued
+	if s.consentService != nil {
+		allowed, err := s.consentService.CanBroadcast(contact)
+		if err != nil {
+			return "", err
+		}
+		if !allowed {
+			return "", errRecipientSuppressed
+		}
+	}
+
+	recipient.SendMode = SendModeText
+	if recipient.Channel != "WhatsApp" || WithinServiceWindow(contact, time.Now()) {
+		return s.sendToChannel(recipient.Channel, recipient.ChannelID, recipient.Message)
+	}
+
+	templateID, err := s.whatsAppTemplateFor(recipient, waTemplates)
+	if err != nil {
+		return "", err
+	}
+	if templateID == nil {
+		return "", fmt.Errorf("contact is outside the 24-hour WhatsApp window and the broadcast has no WhatsApp template")
+	}
+
+	recipient.SendMode = SendModeTemplate
+	if err := s.whatsappService.ReserveConversation(contact.ChannelID); err != nil {
+		return "", err
+	}
+	return s.waTemplates.SendToContact(*templateID, contact)
+}
+
+// whatsAppTemplateFor returns the WhatsApp template of the broadcast template a recipient's
+// message was rendered from, caching it for the batch
+func (s *BroadcastService) whatsAppTemplateFor(recipient *models.BroadcastRecipient, cache map[uint]*uint) (*uint, error) {
+	broadcastTemplateID := recipient.TemplateID
+	if broadcastTemplateID == 0 {
+		// Queued before recipients recorded their template
+		history, err := s.repo.FindHistoryByID(recipient.HistoryID)
+		if err != nil {
+			return nil, err
+		}
+		if history == nil {
+			return nil, fmt.Errorf("broadcast not found")
+		}
+		broadcastTemplateID = history.TemplateID
+	}
+
+	if templateID, ok := cache[broadcastTemplateID]; ok {
+		return templateID, nil
+	}
+
+	var templateID *uint
+	if template, err := s.repo.FindTemplateByID(broadcastTemplateID); err == nil {
+		templateID = template.WhatsAppTemplateID
+	}
+	cache[broadcastTemplateID] = templateID
+	return templateID, nil
+}
+
+// sendToChannel sends a message and returns the platform message ID when the platform provides one
+func (s *BroadcastService) sendToChannel(channel, channelID, message string) (string, error) {
+	switch channel {
+	case "WhatsApp":
+		// Broadcasts start conversations, so they count against the messaging tier
+		if err := s.whatsappService.ReserveConversation(channelID); err != nil {
+			return "", err
+		}
+		return s.whatsappService.SendMessageWithID(channelID, message)
+	case "Instagram":
+		return "", s.instagramService.SendMessage(channelID, message)
+	case "Telegram":
+		return "", s.telegramService.SendMessage(channelID, message)
+	}
+	return "", fmt.Errorf("unsupported channel: %s", channel)
+}
+
+// refreshHistory recomputes the aggregate counters of a broadcast from its recipients
+func (s *BroadcastService) refreshHistory(historyID uint) {
+	history, err := s.repo.FindHistoryByID(historyID)
+	if err != nil || history == nil {
+		s.logger.Error("Failed to load broadcast history", "history_id", historyID, "error", err)
+		return
+	}
+
+	counts, err := s.recipientCounts(historyID)
+	if err != nil {
+		s.logger.Error("Failed to count broadcast recipients", "history_id", historyID, "error", err)
+		return
+	}
+
+	if len(history.Variants) > 0 {
+		s.refreshVariants(history)
+	}
+
+	successful := int(counts[RecipientSent] + counts[RecipientDelivered])
+	failed := int(counts[RecipientFailed])
+
+	status := "Processing"
+	var completedAt *time.Time
+	if counts[RecipientPending]+counts[RecipientSending] == 0 {
+		if history.AutoWinner && history.WinnerVariantID == nil {
+			// The test is sent; the rest of the audience waits for the winner
+			status = "Testing"
+		} else {
+			status = "Completed"
+			now := time.Now()
+			completedAt = &now
+		}
+	}
+
+	if err := s.repo.UpdateHistoryProgress(historyID, successful, failed, status, completedAt); err != nil {
+		s.logger.Error("Failed to update broadcast history", "history_id", historyID, "error", err)
+		return
+	}
+
+	if completedAt != nil {
+		s.logger.Info("Broadcast completed", "history_id", historyID, "successful", successful, "failed", failed)
+	}
+}
+
+func (s *BroadcastService) recipientCounts(historyID uint) (map[string]int64, error) {
+	rows, err := s.repo.CountRecipientsByStatus(historyID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{
+		RecipientPending:   0,
+		RecipientSending:   0,
+		RecipientSent:      0,
+		RecipientDelivered: 0,
+		RecipientFailed:    0,
+		RecipientSkipped:   0,
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// GetProgress returns a broadcast with its per-status recipient counts
+func (s *BroadcastService) GetProgress(historyID uint) (*BroadcastProgress, error) {
+	history, err := s.repo.FindHistoryByID(historyID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, fmt.Errorf("broadcast not found")
+	}
+
+	counts, err := s.recipientCounts(historyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BroadcastProgress{History: history, Counts: counts}, nil
+}
+
+// GetRecipients returns per-recipient outcomes, optionally filtered by status
+func (s *BroadcastService) GetRecipients(historyID uint, status string) ([]models.BroadcastRecipient, error) {
+	return s.repo.FindRecipients(historyID, status)
+}
+
+// RetryFailed requeues the failed recipients of a broadcast
+func (s *BroadcastService) RetryFailed(historyID uint) (int64, error) {
+	history, err := s.repo.FindHistoryByID(historyID)
+	if err != nil {
+		return 0, err
+	}
+	if history == nil {
+		return 0, fmt.Errorf("broadcast not found")
+	}
+
+	requeued, err := s.repo.RetryFailedRecipients(historyID)
+	if err != nil {
+		return 0, err
+	}
+
+	if requeued > 0 {
+		s.logger.Info("Retrying failed broadcast recipients", "history_id", historyID, "recipients", requeued)
+		s.refreshHistory(historyID)
+		s.wakeDelivery()
+	}
+	return requeued, nil
+}
+
+// HandleDeliveryStatus applies a platform delivery receipt (sent, delivered, read, failed)
+func (s *BroadcastService) HandleDeliveryStatus(externalID, status, errorMessage string, at time.Time) error {
+	recipient, err := s.repo.FindRecipientByExternalID(externalID)
+	if err != nil || recipient == nil {
+		return err
+	}
+
+	// Only the receipt columns are written so reply and conversion times are kept
+	updates := map[string]interface{}{}
+	switch strings.ToLower(status) {
+	case "delivered", "read":
+		if recipient.Status != RecipientDelivered {
+			updates["status"] = RecipientDelivered
+		}
+		if recipient.DeliveredAt == nil {
+			updates["delivered_at"] = at
+		}
+		if strings.EqualFold(status, "read") && recipient.ReadAt == nil {
+			updates["read_at"] = at
+		}
+	case "failed":
+		updates["status"] = RecipientFailed
+		updates["error"] = errorMessage
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := s.repo.UpdateRecipientColumns(recipient.ID, updates); err != nil {
+		return err
+	}
+	s.refreshHistory(recipient.HistoryID)
+	return nil
+}
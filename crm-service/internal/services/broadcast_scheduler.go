@@ -0,0 +1,334 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule statuses
+const (
+	ScheduleScheduled = "Scheduled"
+	ScheduleRunning   = "Running"
+	ScheduleCompleted = "Completed"
+	ScheduleCancelled = "Cancelled"
+	ScheduleFailed    = "Failed"
+)
+
+// A schedule still Running after this is assumed abandoned; queueing a broadcast takes seconds
+const scheduleClaimLease = 10 * time.Minute
+
+// Schedule recurrences
+const (
+	RecurrenceNone   = ""
+	RecurrenceDaily  = "daily"
+	RecurrenceWeekly = "weekly"
+	RecurrenceCron   = "cron"
+)
+
+var scheduleTimeLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// ScheduleRequest creates or reschedules a broadcast.
+// SendAt is either RFC3339 or a local time (YYYY-MM-DDTHH:MM) in Timezone.
+// For cron recurrence SendAt is optional; the first run is the next cron match.
+type ScheduleRequest struct {
+	TemplateID uint   `json:"template_id"`
+	SegmentID  uint   `json:"segment_id"`
+	SendAt     string `json:"send_at"`
+	Timezone   string `json:"timezone"`
+	Recurrence string `json:"recurrence"` // "", daily, weekly, cron
+	CronExpr   string `json:"cron_expr"`
+	SentBy     string `json:"sent_by"`
+}
+
+func (s *BroadcastService) GetSchedules() ([]models.ScheduledBroadcast, error) {
+	return s.repo.FindAllSchedules()
+}
+
+func (s *BroadcastService) GetScheduleByID(id uint) (*models.ScheduledBroadcast, error) {
+	schedule, err := s.repo.FindScheduleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, fmt.Errorf("schedule not found")
+	}
+	return schedule, nil
+}
+
+// ScheduleBroadcast persists a broadcast to be sent later by the scheduler
+func (s *BroadcastService) ScheduleBroadcast(req *ScheduleRequest) (*models.ScheduledBroadcast, error) {
+	template, err := s.repo.FindTemplateByID(req.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("template not found")
+	}
+	if _, err := ParseMessageTemplate(template.Content); err != nil {
+		return nil, err
+	}
+	if _, err := s.audienceFilter(template, req.SegmentID); err != nil {
+		return nil, err
+	}
+
+	schedule := &models.ScheduledBroadcast{
+		TemplateID: req.TemplateID,
+		SentBy:     req.SentBy,
+		Status:     ScheduleScheduled,
+	}
+	if req.SegmentID != 0 {
+		segmentID := req.SegmentID
+		schedule.SegmentID = &segmentID
+	}
+	if err := applyScheduleTiming(schedule, req, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("📅 Broadcast scheduled",
+		"schedule_id", schedule.ID,
+		"template_id", schedule.TemplateID,
+		"next_run_at", schedule.NextRunAt,
+		"recurrence", schedule.Recurrence,
+	)
+	return schedule, nil
+}
+
+// Reschedule changes the timing of a schedule and puts it back in the queue
+func (s *BroadcastService) Reschedule(id uint, req *ScheduleRequest) (*models.ScheduledBroadcast, error) {
+	schedule, err := s.GetScheduleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status == ScheduleRunning {
+		return nil, fmt.Errorf("schedule is running, try again shortly")
+	}
+
+	if err := applyScheduleTiming(schedule, req, time.Now()); err != nil {
+		return nil, err
+	}
+	schedule.Status = ScheduleScheduled
+	schedule.LastError = ""
+
+	if err := s.repo.UpdateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("📅 Broadcast rescheduled", "schedule_id", schedule.ID, "next_run_at", schedule.NextRunAt)
+	return schedule, nil
+}
+
+// CancelSchedule stops a schedule from running again
+func (s *BroadcastService) CancelSchedule(id uint) error {
+	schedule, err := s.GetScheduleByID(id)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != ScheduleScheduled && schedule.Status != ScheduleFailed {
+		return fmt.Errorf("cannot cancel a %s schedule", strings.ToLower(schedule.Status))
+	}
+
+	schedule.Status = ScheduleCancelled
+	schedule.NextRunAt = nil
+
+	s.logger.Info("Cancelling scheduled broadcast", "schedule_id", id)
+	return s.repo.UpdateSchedule(schedule)
+}
+
+// StartScheduler runs due broadcasts on every tick. Schedules are stored in the
+// database, so anything due while the server was down runs on the first tick.
+func (s *BroadcastService) StartScheduler(interval time.Duration) {
+	s.runDueSchedules()
+	s.decideWinners(time.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.runDueSchedules()
+		s.decideWinners(now)
+	}
+}
+
+func (s *BroadcastService) runDueSchedules() {
+	now := time.Now()
+	if reset, err := s.repo.ResetRunningSchedules(now.Add(-scheduleClaimLease)); err != nil {
+		s.logger.Error("Failed to recover interrupted schedules", "error", err)
+	} else if reset > 0 {
+		s.logger.Warn("Recovered interrupted broadcast schedules", "count", reset)
+	}
+
+	schedules, err := s.repo.FindDueSchedules(now)
+	if err != nil {
+		s.logger.Error("Failed to load due broadcasts", "error", err)
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		claimed, err := s.repo.ClaimSchedule(schedule.ID, now)
+		if err != nil || !claimed {
+			continue
+		}
+
+		s.runSchedule(schedule, now)
+	}
+}
+
+func (s *BroadcastService) runSchedule(schedule *models.ScheduledBroadcast, now time.Time) {
+	segmentID := uint(0)
+	if schedule.SegmentID != nil {
+		segmentID = *schedule.SegmentID
+	}
+
+	s.logger.Info("📣 Running scheduled broadcast", "schedule_id", schedule.ID, "template_id", schedule.TemplateID)
+	history, err := s.SendBroadcast(&BroadcastRequest{
+		TemplateID: schedule.TemplateID,
+		SegmentID:  segmentID,
+		SentBy:     schedule.SentBy,
+	})
+
+	schedule.LastRunAt = &now
+	schedule.ClaimedAt = nil
+	schedule.RunCount++
+	schedule.LastError = ""
+	if err != nil {
+		schedule.LastError = err.Error()
+		s.logger.Error("Scheduled broadcast failed", "schedule_id", schedule.ID, "error", err)
+	} else {
+		schedule.LastHistoryID = &history.ID
+	}
+
+	next, nextErr := nextScheduleRun(schedule, now)
+	switch {
+	case nextErr != nil:
+		schedule.Status = ScheduleFailed
+		schedule.LastError = nextErr.Error()
+		schedule.NextRunAt = nil
+	case next != nil:
+		schedule.Status = ScheduleScheduled
+		schedule.NextRunAt = next
+	case err != nil:
+		schedule.Status = ScheduleFailed
+		schedule.NextRunAt = nil
+	default:
+		schedule.Status = ScheduleCompleted
+		schedule.NextRunAt = nil
+	}
+
+	if err := s.repo.UpdateSchedule(schedule); err != nil {
+		s.logger.Error("Failed to update schedule", "schedule_id", schedule.ID, "error", err)
+	}
+}
+
+// applyScheduleTiming validates the timing fields of a request and sets the first run
+func applyScheduleTiming(schedule *models.ScheduledBroadcast, req *ScheduleRequest, now time.Time) error {
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = "Asia/Jakarta"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %s", timezone)
+	}
+
+	recurrence := strings.ToLower(strings.TrimSpace(req.Recurrence))
+	cronExpr := strings.TrimSpace(req.CronExpr)
+	switch recurrence {
+	case RecurrenceNone, RecurrenceDaily, RecurrenceWeekly:
+		cronExpr = ""
+	case RecurrenceCron:
+		if _, err := utils.ParseCron(cronExpr); err != nil {
+			return fmt.Errorf("invalid cron expression: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown recurrence: %s", req.Recurrence)
+	}
+
+	schedule.Timezone = timezone
+	schedule.Recurrence = recurrence
+	schedule.CronExpr = cronExpr
+
+	if strings.TrimSpace(req.SendAt) == "" {
+		if recurrence != RecurrenceCron {
+			return fmt.Errorf("send_at is required")
+		}
+		next, err := nextScheduleRun(schedule, now)
+		if err != nil {
+			return err
+		}
+		schedule.NextRunAt = next
+		return nil
+	}
+
+	sendAt, err := parseScheduleTime(req.SendAt, loc)
+	if err != nil {
+		return err
+	}
+	if !sendAt.After(now) {
+		return fmt.Errorf("send_at must be in the future")
+	}
+	schedule.NextRunAt = &sendAt
+	return nil
+}
+
+// nextScheduleRun returns the next run after now, or nil for one-off schedules
+func nextScheduleRun(schedule *models.ScheduledBroadcast, now time.Time) (*time.Time, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", schedule.Timezone)
+	}
+
+	var next time.Time
+	switch schedule.Recurrence {
+	case RecurrenceDaily, RecurrenceWeekly:
+		days := 1
+		if schedule.Recurrence == RecurrenceWeekly {
+			days = 7
+		}
+		// Step in local time so the wall clock hour stays fixed across DST changes
+		next = now.In(loc)
+		if schedule.NextRunAt != nil {
+			next = schedule.NextRunAt.In(loc)
+		}
+		for !next.After(now) {
+			next = next.AddDate(0, 0, days)
+		}
+	case RecurrenceCron:
+		cron, err := utils.ParseCron(schedule.CronExpr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %v", err)
+		}
+		next = cron.Next(now.In(loc))
+		if next.IsZero() {
+			return nil, fmt.Errorf("cron expression never matches")
+		}
+	default:
+		return nil, nil
+	}
+
+	next = next.UTC()
+	return &next, nil
+}
+
+func parseScheduleTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range scheduleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid send_at, use YYYY-MM-DDTHH:MM or RFC3339")
+}
@@ -1,37 +1,63 @@
 package services
 
 import (
+	"divine-crm/internal/config"
 	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
-	"strings"
+	"fmt"
 	"time"
 )
 
 type BroadcastService struct {
 	repo             *repository.BroadcastRepository
 	contactRepo      *repository.ContactRepository
+	segmentRepo      *repository.SegmentRepository
 	whatsappService  *WhatsAppService
 	instagramService *InstagramService
 	telegramService  *TelegramService
+	waTemplates      *WhatsAppTemplateService
+	consentService   *ConsentService
+	replyWindow      time.Duration // Inbound messages this long after a broadcast are attributed to it
+	conversionWindow time.Duration
 	logger           *utils.Logger
+	wake             chan struct{} // Nudges the delivery worker when recipients are queued
 }
 
 func NewBroadcastService(
 	repo *repository.BroadcastRepository,
 	contactRepo *repository.ContactRepository,
+	segmentRepo *repository.SegmentRepository,
 	whatsappService *WhatsAppService,
 	instagramService *InstagramService,
 	telegramService *TelegramService,
+	waTemplates *WhatsAppTemplateService,
+	consentService *ConsentService,
+	cfg *config.BroadcastConfig,
 	logger *utils.Logger,
 ) *BroadcastService {
+	replyWindow, err := time.ParseDuration(cfg.ReplyWindow)
+	if err != nil || replyWindow <= 0 {
+		replyWindow = 72 * time.Hour
+	}
+	conversionWindow, err := time.ParseDuration(cfg.ConversionWindow)
+	if err != nil || conversionWindow <= 0 {
+		conversionWindow = 7 * 24 * time.Hour
+	}
+
 	return &BroadcastService{
 		repo:             repo,
 		contactRepo:      contactRepo,
+		segmentRepo:      segmentRepo,
 		whatsappService:  whatsappService,
 		instagramService: instagramService,
 		telegramService:  telegramService,
+		waTemplates:      waTemplates,
+		consentService:   consentService,
+		replyWindow:      replyWindow,
+		conversionWindow: conversionWindow,
 		logger:           logger,
+		wake:             make(chan struct{}, 1),
 	}
 }
 
@@ -45,104 +71,223 @@ func (s *BroadcastService) GetTemplateByID(id uint) (*models.BroadcastTemplate,
 }
 
 func (s *BroadcastService) CreateTemplate(template *models.BroadcastTemplate) error {
+	if err := s.validateTemplate(template); err != nil {
+		return err
+	}
+
 	s.logger.Info("Creating broadcast template", "name", template.Name)
 	return s.repo.CreateTemplate(template)
 }
 
 func (s *BroadcastService) UpdateTemplate(template *models.BroadcastTemplate) error {
+	if err := s.validateTemplate(template); err != nil {
+		return err
+	}
+
 	s.logger.Info("Updating broadcast template", "id", template.ID)
 	return s.repo.UpdateTemplate(template)
 }
 
+// validateTemplate checks the content and the WhatsApp template used outside the 24h window
+func (s *BroadcastService) validateTemplate(template *models.BroadcastTemplate) error {
+	if _, err := ParseMessageTemplate(template.Content); err != nil {
+		return err
+	}
+
+	if template.WhatsAppTemplateID != nil {
+		waTemplate, err := s.waTemplates.GetByID(*template.WhatsAppTemplateID)
+		if err != nil {
+			return fmt.Errorf("whatsapp template: %v", err)
+		}
+		if waTemplate.Status != "APPROVED" {
+			return fmt.Errorf("whatsapp template %s is not approved", waTemplate.Name)
+		}
+	}
+	return nil
+}
+
 func (s *BroadcastService) DeleteTemplate(id uint) error {
 	s.logger.Info("Deleting broadcast template", "id", id)
 	return s.repo.DeleteTemplate(id)
 }
 
+// PreviewRequest renders either a saved template or unsaved content for a contact
+type PreviewRequest struct {
+	TemplateID uint   `json:"template_id"`
+	Content    string `json:"content"`
+	ContactID  uint   `json:"contact_id"`
+}
+
+// PreviewTemplate renders a template against a specific contact without sending it
+func (s *BroadcastService) PreviewTemplate(req *PreviewRequest) (string, error) {
+	content := req.Content
+	if req.TemplateID != 0 {
+		template, err := s.repo.FindTemplateByID(req.TemplateID)
+		if err != nil {
+			return "", fmt.Errorf("template not found")
+		}
+		content = template.Content
+	}
+
+	tmpl, err := ParseMessageTemplate(content)
+	if err != nil {
+		return "", err
+	}
+
+	contact, err := s.contactRepo.FindByID(req.ContactID)
+	if err != nil {
+		return "", err
+	}
+	if contact == nil {
+		return "", fmt.Errorf("contact not found")
+	}
+
+	return RenderMessageTemplate(tmpl, contact)
+}
+
 // Broadcast Execution
-func (s *BroadcastService) SendBroadcast(templateID uint, sentBy string) error {
+
+// audienceFilter resolves the recipients filter: the segment (if any) restricted to the template channel
+func (s *BroadcastService) audienceFilter(template *models.BroadcastTemplate, segmentID uint) (*models.SegmentFilter, error) {
+	filter := models.SegmentFilter{}
+	if segmentID != 0 {
+		segment, err := s.segmentRepo.FindByID(segmentID)
+		if err != nil {
+			return nil, err
+		}
+		if segment == nil {
+			return nil, fmt.Errorf("segment not found")
+		}
+		filter = segment.Filters
+	}
+
+	filter, ok := restrictToChannel(filter, template.Channel)
+	if !ok {
+		return nil, fmt.Errorf("segment does not include channel %s", template.Channel)
+	}
+	// Opted-out and suppressed contacts never receive broadcasts
+	filter.Marketable = true
+	return &filter, nil
+}
+
+// AudienceCount is how many contacts a broadcast reaches and how many are left out
+type AudienceCount struct {
+	Count      int64 `json:"count"`
+	Suppressed int64 `json:"suppressed"`
+}
+
+// CountAudience returns how many contacts a broadcast would reach
+func (s *BroadcastService) CountAudience(templateID, segmentID uint) (*AudienceCount, error) {
 	template, err := s.repo.FindTemplateByID(templateID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("template not found")
 	}
 
-	// Get recipients based on channel
-	var contacts []models.Contact
-	if template.Channel == "All" {
-		contacts, err = s.contactRepo.FindAll()
-	} else {
-		contacts, err = s.contactRepo.Search(template.Channel)
+	filter, err := s.audienceFilter(template, segmentID)
+	if err != nil {
+		return nil, err
 	}
 
+	count, err := s.segmentRepo.CountContacts(filter)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	suppressed, err := s.countSuppressed(filter, count)
+	if err != nil {
+		return nil, err
+	}
+	return &AudienceCount{Count: count, Suppressed: suppressed}, nil
+}
+
+// countSuppressed returns how many contacts of the filter are left out for lack of consent
+func (s *BroadcastService) countSuppressed(filter *models.SegmentFilter, marketable int64) (int64, error) {
+	all := *filter
+	all.Marketable = false
+	total, err := s.segmentRepo.CountContacts(&all)
+	if err != nil {
+		return 0, err
+	}
+	return total - marketable, nil
+}
+
+// BroadcastRequest describes a broadcast. With two or more variants it is an A/B test.
+type BroadcastRequest struct {
+	TemplateID       uint             `json:"template_id"`
+	SegmentID        uint             `json:"segment_id"` // 0 = everyone on the template channel
+	SentBy           string           `json:"sent_by"`
+	Variants         []VariantRequest `json:"variants"`
+	AutoWinner       bool             `json:"auto_winner"`        // Test on part of the audience, then send the best variant to the rest
+	TestPercentage   int              `json:"test_percentage"`    // Share of the audience in the test (default 20)
+	WinnerMetric     string           `json:"winner_metric"`      // reply_rate (default), delivery_rate, conversion_rate
+	WinnerAfterHours int              `json:"winner_after_hours"` // How long the test runs (default 24)
+}
+
+// SendBroadcast queues a broadcast for a segment (or everyone on the template channel when no segment is given)
+func (s *BroadcastService) SendBroadcast(req *BroadcastRequest) (*models.BroadcastHistory, error) {
+	variants, err := s.loadVariants(req)
+	if err != nil {
+		return nil, err
+	}
+	template := variants[0].template
+
+	filter, err := s.audienceFilter(template, req.SegmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts, err := s.segmentRepo.FindContacts(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressed, err := s.countSuppressed(filter, int64(len(contacts)))
+	if err != nil {
+		return nil, err
 	}
 
 	// Create broadcast history
 	history := &models.BroadcastHistory{
-		TemplateID: templateID,
-		SentTo:     len(contacts),
+		TemplateID: template.ID,
 		Successful: 0,
 		Failed:     0,
+		Suppressed: int(suppressed),
 		Status:     "Processing",
-		SentBy:     sentBy,
+		SentBy:     req.SentBy,
 		CreatedAt:  time.Now(),
 	}
+	if req.SegmentID != 0 {
+		segmentID := req.SegmentID
+		history.SegmentID = &segmentID
+	}
 
-	s.repo.CreateHistory(history)
-
-	// Send to each contact
-	go s.processBroadcast(history, template, contacts)
-
-	return nil
-}
-
-func (s *BroadcastService) processBroadcast(history *models.BroadcastHistory, template *models.BroadcastTemplate, contacts []models.Contact) {
-	successful := 0
-	failed := 0
-
-	for _, contact := range contacts {
-		// Personalize message
-		message := s.personalizeMessage(template.Content, contact)
+	audience := contacts
+	if len(variants) > 1 {
+		audience = startABTest(history, req, contacts, len(variants))
+	}
+	history.SentTo = len(audience)
 
-		// Send based on channel
-		var err error
-		switch contact.Channel {
-		case "WhatsApp":
-			err = s.whatsappService.SendMessage(contact.ChannelID, message)
-		case "Instagram":
-			err = s.instagramService.SendMessage(contact.ChannelID, message)
-		case "Telegram":
-			err = s.telegramService.SendMessage(contact.ChannelID, message)
-		}
+	// History, variants and recipients are stored together so a failure leaves nothing half-queued
+	var recipients []models.BroadcastRecipient
+	rows := variantRows(variants)
+	err = s.repo.CreateBroadcast(history, rows, func() []models.BroadcastRecipient {
+		linkVariants(history, variants, rows)
 
-		if err != nil {
-			failed++
-			s.logger.Error("Failed to send broadcast", "contact", contact.Name, "error", err)
-		} else {
-			successful++
+		// Personalize every message up front so the worker only has to send
+		recipients = make([]models.BroadcastRecipient, 0, len(audience))
+		for i, group := range splitAudience(audience, variants) {
+			recipients = append(recipients, buildRecipients(history.ID, group, variants[i])...)
 		}
-
-		// Small delay to avoid rate limits
-		time.Sleep(100 * time.Millisecond)
+		return recipients
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Update history
-	now := time.Now()
-	history.Successful = successful
-	history.Failed = failed
-	history.Status = "Completed"
-	history.CompletedAt = &now
-
-	s.repo.UpdateHistory(history)
-	s.logger.Info("Broadcast completed", "successful", successful, "failed", failed)
-}
+	s.logger.Info("📣 Broadcast queued", "history_id", history.ID, "recipients", len(recipients), "suppressed", suppressed)
+	s.refreshHistory(history.ID)
+	s.wakeDelivery()
 
-func (s *BroadcastService) personalizeMessage(template string, contact models.Contact) string {
-	message := template
-	message = strings.ReplaceAll(message, "{name}", contact.Name)
-	message = strings.ReplaceAll(message, "{code}", contact.Code)
-	return message
+	return history, nil
 }
 
 // History
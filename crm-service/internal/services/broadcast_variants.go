@@ -0,0 +1,426 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"fmt"
+	"math"
+	"math/rand"
+	"text/template"
+	"time"
+)
+
+// Metrics an A/B test winner can be picked by
+const (
+	WinnerReplyRate      = "reply_rate"
+	WinnerDeliveryRate   = "delivery_rate"
+	WinnerConversionRate = "conversion_rate"
+)
+
+const maxBroadcastVariants = 5
+
+// VariantRequest is one template of an A/B test and its share of the test audience
+type VariantRequest struct {
+	TemplateID uint `json:"template_id"`
+	Percentage int  `json:"percentage"`
+}
+
+// broadcastVariant is a parsed template ready to render for one group of recipients
+type broadcastVariant struct {
+	id         *uint // nil when the broadcast is not an A/B test
+	label      string
+	percentage int
+	template   *models.BroadcastTemplate
+	tmpl       *template.Template
+}
+
+// loadVariants validates the request and parses the template of every variant.
+// A broadcast without variants is treated as a single variant of its template.
+func (s *BroadcastService) loadVariants(req *BroadcastRequest) ([]*broadcastVariant, error) {
+	requests := req.Variants
+	if len(requests) == 0 {
+		requests = []VariantRequest{{TemplateID: req.TemplateID, Percentage: 100}}
+	}
+	if len(requests) > maxBroadcastVariants {
+		return nil, fmt.Errorf("a broadcast can have at most %d variants", maxBroadcastVariants)
+	}
+	if len(requests) == 1 {
+		if req.AutoWinner {
+			return nil, fmt.Errorf("auto winner needs at least two variants")
+		}
+		requests[0].Percentage = 100
+	}
+	if err := normalizeABTest(req); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	variants := make([]*broadcastVariant, 0, len(requests))
+	for i, r := range requests {
+		label := string(rune('A' + i))
+		if r.Percentage <= 0 {
+			return nil, fmt.Errorf("variant %s: percentage must be positive", label)
+		}
+		total += r.Percentage
+
+		template, err := s.repo.FindTemplateByID(r.TemplateID)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: template not found", label)
+		}
+		if i > 0 && template.Channel != variants[0].template.Channel {
+			return nil, fmt.Errorf("variant %s: all variants must use the %s channel", label, variants[0].template.Channel)
+		}
+
+		tmpl, err := ParseMessageTemplate(template.Content)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %v", label, err)
+		}
+
+		variants = append(variants, &broadcastVariant{
+			label:      label,
+			percentage: r.Percentage,
+			template:   template,
+			tmpl:       tmpl,
+		})
+	}
+
+	if total != 100 {
+		return nil, fmt.Errorf("variant percentages must add up to 100, got %d", total)
+	}
+	return variants, nil
+}
+
+// normalizeABTest fills in the auto-winner defaults
+func normalizeABTest(req *BroadcastRequest) error {
+	if !req.AutoWinner {
+		return nil
+	}
+
+	if req.TestPercentage == 0 {
+		req.TestPercentage = 20
+	}
+	if req.TestPercentage < 1 || req.TestPercentage > 99 {
+		return fmt.Errorf("test_percentage must be between 1 and 99")
+	}
+
+	if req.WinnerMetric == "" {
+		req.WinnerMetric = WinnerReplyRate
+	}
+	switch req.WinnerMetric {
+	case WinnerReplyRate, WinnerDeliveryRate, WinnerConversionRate:
+	default:
+		return fmt.Errorf("unknown winner metric: %s", req.WinnerMetric)
+	}
+
+	if req.WinnerAfterHours == 0 {
+		req.WinnerAfterHours = 24
+	}
+	if req.WinnerAfterHours < 1 || req.WinnerAfterHours > 30*24 {
+		return fmt.Errorf("winner_after_hours must be between 1 and 720")
+	}
+	return nil
+}
+
+// startABTest shuffles the audience so variants get random recipients. With auto winner
+// only the test share is returned; the rest of the audience gets the winner later.
+func startABTest(history *models.BroadcastHistory, req *BroadcastRequest, contacts []models.Contact, variantCount int) []models.Contact {
+	rand.Shuffle(len(contacts), func(i, j int) {
+		contacts[i], contacts[j] = contacts[j], contacts[i]
+	})
+
+	if !req.AutoWinner {
+		return contacts
+	}
+
+	decideAt := time.Now().Add(time.Duration(req.WinnerAfterHours) * time.Hour)
+	history.AutoWinner = true
+	history.TestPercentage = req.TestPercentage
+	history.WinnerMetric = req.WinnerMetric
+	history.WinnerDecideAt = &decideAt
+
+	size := len(contacts) * req.TestPercentage / 100
+	if size < variantCount {
+		size = variantCount
+	}
+	if size > len(contacts) {
+		size = len(contacts)
+	}
+	return contacts[:size]
+}
+
+// variantRows returns the variants of an A/B test to store with the broadcast.
+// A broadcast of a single template has none.
+func variantRows(variants []*broadcastVariant) []models.BroadcastVariant {
+	if len(variants) < 2 {
+		return nil
+	}
+
+	rows := make([]models.BroadcastVariant, len(variants))
+	for i, v := range variants {
+		rows[i] = models.BroadcastVariant{
+			TemplateID: v.template.ID,
+			Label:      v.label,
+			Percentage: v.percentage,
+		}
+	}
+	return rows
+}
+
+// linkVariants points each variant at its stored row so recipients record which one they got
+func linkVariants(history *models.BroadcastHistory, variants []*broadcastVariant, rows []models.BroadcastVariant) {
+	for i := range rows {
+		variants[i].id = &rows[i].ID
+	}
+	history.Variants = rows
+}
+
+// splitAudience divides the shuffled audience between variants by their percentages
+func splitAudience(audience []models.Contact, variants []*broadcastVariant) [][]models.Contact {
+	groups := make([][]models.Contact, len(variants))
+	start, cumulative := 0, 0
+	for i, v := range variants {
+		cumulative += v.percentage
+		end := len(audience) * cumulative / 100
+		if i == len(variants)-1 {
+			end = len(audience)
+		}
+		groups[i] = audience[start:end]
+		start = end
+	}
+	return groups
+}
+
+// buildRecipients renders the variant template for each contact
+func buildRecipients(historyID uint, contacts []models.Contact, variant *broadcastVariant) []models.BroadcastRecipient {
+	recipients := make([]models.BroadcastRecipient, 0, len(contacts))
+	for i := range contacts {
+		contact := &contacts[i]
+		recipient := models.BroadcastRecipient{
+			HistoryID:   historyID,
+			ContactID:   contact.ID,
+			ContactName: contact.Name,
+			Channel:     contact.Channel,
+			ChannelID:   contact.ChannelID,
+			TemplateID:  variant.template.ID,
+			VariantID:   variant.id,
+			Status:      RecipientPending,
+		}
+
+		message, err := RenderMessageTemplate(variant.tmpl, contact)
+		if err != nil {
+			recipient.Status = RecipientFailed
+			recipient.Error = "render: " + err.Error()
+		}
+		recipient.Message = message
+
+		recipients = append(recipients, recipient)
+	}
+	return recipients
+}
+
+// refreshVariants recomputes the results of each variant of an A/B test
+func (s *BroadcastService) refreshVariants(history *models.BroadcastHistory) {
+	stats, err := s.repo.CountRecipientsByVariant(history.ID)
+	if err != nil {
+		s.logger.Error("Failed to count variant results", "history_id", history.ID, "error", err)
+		return
+	}
+
+	byVariant := make(map[uint]repository.VariantStats, len(stats))
+	for _, row := range stats {
+		byVariant[row.VariantID] = row
+	}
+
+	for i := range history.Variants {
+		variant := &history.Variants[i]
+		counts, ok := byVariant[variant.ID]
+		if !ok {
+			continue
+		}
+
+		variant.Recipients = counts.Recipients
+		variant.Sent = counts.Sent
+		variant.Delivered = counts.Delivered
+		variant.Failed = counts.Failed
+		variant.Replied = counts.Replied
+		variant.Converted = counts.Converted
+		variant.DeliveryRate = percentage(counts.Delivered, counts.Sent)
+		variant.ReplyRate = percentage(counts.Replied, counts.Sent)
+		variant.ConversionRate = percentage(counts.Converted, counts.Sent)
+
+		if err := s.repo.UpdateVariant(variant); err != nil {
+			s.logger.Error("Failed to update broadcast variant", "variant_id", variant.ID, "error", err)
+		}
+	}
+}
+
+// decideWinners sends the best variant of finished A/B tests to the rest of their audience
+func (s *BroadcastService) decideWinners(now time.Time) {
+	tests, err := s.repo.FindDueWinnerTests(now)
+	if err != nil {
+		s.logger.Error("Failed to load A/B tests", "error", err)
+		return
+	}
+
+	for i := range tests {
+		if err := s.sendWinner(tests[i].ID, 0); err != nil {
+			s.logger.Error("Failed to send A/B test winner", "history_id", tests[i].ID, "error", err)
+		}
+	}
+}
+
+// ChooseWinner ends an A/B test with the given variant instead of waiting for the automatic pick
+func (s *BroadcastService) ChooseWinner(historyID, variantID uint) (*models.BroadcastHistory, error) {
+	if err := s.sendWinner(historyID, variantID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindHistoryByID(historyID)
+}
+
+// sendWinner marks the winning variant (the best by the test metric when variantID is 0)
+// and, for auto-winner tests, queues it for the contacts that were not in the test
+func (s *BroadcastService) sendWinner(historyID, variantID uint) error {
+	s.refreshHistory(historyID)
+
+	history, err := s.repo.FindHistoryByID(historyID)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		return fmt.Errorf("broadcast not found")
+	}
+	if len(history.Variants) == 0 {
+		return fmt.Errorf("broadcast is not an A/B test")
+	}
+	if history.WinnerVariantID != nil {
+		return fmt.Errorf("a winner was already chosen")
+	}
+
+	var winner *models.BroadcastVariant
+	for i := range history.Variants {
+		if history.Variants[i].ID == variantID {
+			winner = &history.Variants[i]
+		}
+	}
+	if variantID == 0 {
+		winner = bestVariant(history.Variants, history.WinnerMetric)
+	}
+	if winner == nil {
+		return fmt.Errorf("variant not found")
+	}
+	if history.AutoWinner && winner.Template.ID == 0 {
+		return fmt.Errorf("template of variant %s no longer exists", winner.Label)
+	}
+
+	claimed, err := s.repo.ClaimWinner(history.ID, winner.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("a winner was already chosen")
+	}
+
+	s.logger.Info("🏆 A/B test winner chosen",
+		"history_id", history.ID,
+		"variant", winner.Label,
+		"metric", history.WinnerMetric,
+		"reply_rate", winner.ReplyRate,
+	)
+
+	// Without auto winner the whole audience already received a variant
+	if !history.AutoWinner {
+		s.refreshHistory(history.ID)
+		return nil
+	}
+
+	remaining, err := s.remainingAudience(history)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := ParseMessageTemplate(winner.Template.Content)
+	if err != nil {
+		return err
+	}
+	recipients := buildRecipients(history.ID, remaining, &broadcastVariant{
+		id:       &winner.ID,
+		label:    winner.Label,
+		template: &winner.Template,
+		tmpl:     tmpl,
+	})
+
+	if err := s.repo.CreateRecipients(recipients); err != nil {
+		return err
+	}
+	if err := s.repo.AddHistoryRecipients(history.ID, len(recipients)); err != nil {
+		return err
+	}
+
+	s.logger.Info("📣 A/B test winner queued", "history_id", history.ID, "recipients", len(recipients))
+	s.refreshHistory(history.ID)
+	s.wakeDelivery()
+	return nil
+}
+
+// remainingAudience returns the current audience of a broadcast minus the contacts already in it
+func (s *BroadcastService) remainingAudience(history *models.BroadcastHistory) ([]models.Contact, error) {
+	segmentID := uint(0)
+	if history.SegmentID != nil {
+		segmentID = *history.SegmentID
+	}
+
+	filter, err := s.audienceFilter(&history.Template, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := s.segmentRepo.FindContacts(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.repo.RecipientContactIDs(history.ID)
+	if err != nil {
+		return nil, err
+	}
+	tested := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		tested[id] = true
+	}
+
+	remaining := make([]models.Contact, 0, len(contacts))
+	for _, contact := range contacts {
+		if !tested[contact.ID] {
+			remaining = append(remaining, contact)
+		}
+	}
+	return remaining, nil
+}
+
+// bestVariant returns the variant with the highest metric; ties go to the earlier variant
+func bestVariant(variants []models.BroadcastVariant, metric string) *models.BroadcastVariant {
+	score := func(v *models.BroadcastVariant) float64 {
+		switch metric {
+		case WinnerDeliveryRate:
+			return v.DeliveryRate
+		case WinnerConversionRate:
+			return v.ConversionRate
+		}
+		return v.ReplyRate
+	}
+
+	best := &variants[0]
+	for i := 1; i < len(variants); i++ {
+		if score(&variants[i]) > score(best) {
+			best = &variants[i]
+		}
+	}
+	return best
+}
+
+// percentage returns part/total as a percentage with two decimals
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestSplitAudience(t *testing.T) {
+	tests := []struct {
+		name        string
+		audience    int
+		percentages []int
+		want        []int
+	}{
+		{"single variant", 10, []int{100}, []int{10}},
+		{"even split", 10, []int{50, 50}, []int{5, 5}},
+		{"rounding remainder goes to the last variant", 10, []int{33, 33, 34}, []int{3, 3, 4}},
+		{"uneven split", 7, []int{70, 30}, []int{4, 3}},
+		{"fewer contacts than variants", 1, []int{50, 50}, []int{0, 1}},
+		{"empty audience", 0, []int{50, 50}, []int{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audience := make([]models.Contact, tt.audience)
+			for i := range audience {
+				audience[i].ID = uint(i + 1)
+			}
+			variants := make([]*broadcastVariant, len(tt.percentages))
+			for i, percentage := range tt.percentages {
+				variants[i] = &broadcastVariant{percentage: percentage}
+			}
+
+			groups := splitAudience(audience, variants)
+
+			sizes := make([]int, len(groups))
+			var seen []uint
+			for i, group := range groups {
+				sizes[i] = len(group)
+				for _, contact := range group {
+					seen = append(seen, contact.ID)
+				}
+			}
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("group sizes = %v, want %v", sizes, tt.want)
+			}
+			// Every contact lands in exactly one group, in order
+			for i, id := range seen {
+				if id != uint(i+1) {
+					t.Fatalf("contacts across groups = %v, want 1..%d once each", seen, tt.audience)
+				}
+			}
+			if len(seen) != tt.audience {
+				t.Errorf("groups hold %d contacts, want %d", len(seen), tt.audience)
+			}
+		})
+	}
+}
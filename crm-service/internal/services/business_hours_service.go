@@ -0,0 +1,403 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultOffHoursMessage = "Halo {name}, terima kasih telah menghubungi kami. 🙏 Saat ini kami sedang di luar jam operasional. Tim kami akan membalas pesan Anda pada {next_open}."
+
+type BusinessHoursService struct {
+	repo   *repository.BusinessHoursRepository
+	logger *utils.Logger
+}
+
+func NewBusinessHoursService(repo *repository.BusinessHoursRepository, logger *utils.Logger) *BusinessHoursService {
+	return &BusinessHoursService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// BusinessHoursStatus describes whether the business is currently open
+type BusinessHoursStatus struct {
+	Enabled  bool       `json:"enabled"`
+	Open     bool       `json:"open"`
+	Timezone string     `json:"timezone"`
+	Now      time.Time  `json:"now"`
+	NextOpen *time.Time `json:"next_open"`
+}
+
+// BusinessHoursUpdate changes the business hours settings; omitted fields are kept
+type BusinessHoursUpdate struct {
+	Timezone        *string `json:"timezone"`
+	Enabled         *bool   `json:"enabled"`
+	OffHoursMessage *string `json:"off_hours_message"`
+	SLAMinutes      *int    `json:"sla_minutes"`
+}
+
+// ==================== SETTINGS ====================
+
+// GetSettings returns the business hours settings, falling back to defaults
+func (s *BusinessHoursService) GetSettings() (*models.BusinessHours, error) {
+	settings, err := s.repo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &models.BusinessHours{
+			Timezone:        "Asia/Jakarta",
+			OffHoursMessage: defaultOffHoursMessage,
+			SLAMinutes:      60,
+		}
+	}
+	return settings, nil
+}
+
+// UpdateSettings merges the fields present in the update into the stored settings
+func (s *BusinessHoursService) UpdateSettings(req *BusinessHoursUpdate) (*models.BusinessHours, error) {
+	// There is only ever one settings row; GetSettings falls back to defaults before the first save
+	settings, err := s.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Timezone != nil {
+		settings.Timezone = *req.Timezone
+		if settings.Timezone == "" {
+			settings.Timezone = "Asia/Jakarta"
+		}
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone: %s", settings.Timezone)
+		}
+	}
+	if req.Enabled != nil {
+		settings.Enabled = *req.Enabled
+	}
+	if req.OffHoursMessage != nil {
+		settings.OffHoursMessage = *req.OffHoursMessage
+	}
+	if req.SLAMinutes != nil {
+		if *req.SLAMinutes < 0 {
+			return nil, fmt.Errorf("sla_minutes must not be negative")
+		}
+		settings.SLAMinutes = *req.SLAMinutes
+	}
+
+	s.logger.Info("Updating business hours", "timezone", settings.Timezone, "enabled", settings.Enabled)
+	if err := s.repo.SaveSettings(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// ==================== WEEKLY SCHEDULE ====================
+
+func (s *BusinessHoursService) GetSchedule() ([]models.BusinessSchedule, error) {
+	return s.repo.FindSchedule()
+}
+
+// UpdateSchedule replaces the weekly schedule. Days left out are treated as closed.
+func (s *BusinessHoursService) UpdateSchedule(schedule []models.BusinessSchedule) error {
+	seen := make(map[int]bool)
+	for i := range schedule {
+		day := &schedule[i]
+		if day.DayOfWeek < 0 || day.DayOfWeek > 6 {
+			return fmt.Errorf("day_of_week must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		if seen[day.DayOfWeek] {
+			return fmt.Errorf("duplicate day_of_week: %d", day.DayOfWeek)
+		}
+		seen[day.DayOfWeek] = true
+
+		day.ID = 0
+		if day.Closed {
+			continue
+		}
+
+		open, err := time.Parse("15:04", day.OpenTime)
+		if err != nil {
+			return fmt.Errorf("invalid open_time for day %d: %s", day.DayOfWeek, day.OpenTime)
+		}
+		closing, err := time.Parse("15:04", day.CloseTime)
+		if err != nil {
+			return fmt.Errorf("invalid close_time for day %d: %s", day.DayOfWeek, day.CloseTime)
+		}
+		if !closing.After(open) {
+			return fmt.Errorf("close_time must be after open_time for day %d", day.DayOfWeek)
+		}
+	}
+
+	s.logger.Info("Updating weekly schedule", "days", len(schedule))
+	return s.repo.ReplaceSchedule(schedule)
+}
+
+// ==================== HOLIDAYS ====================
+
+func (s *BusinessHoursService) GetHolidays() ([]models.Holiday, error) {
+	return s.repo.FindHolidays()
+}
+
+func (s *BusinessHoursService) CreateHoliday(holiday *models.Holiday) error {
+	s.logger.Info("Creating holiday", "date", holiday.Date.Format("2006-01-02"), "name", holiday.Name)
+	return s.repo.CreateHoliday(holiday)
+}
+
+func (s *BusinessHoursService) DeleteHoliday(id uint) error {
+	s.logger.Info("Deleting holiday", "id", id)
+	return s.repo.DeleteHoliday(id)
+}
+
+// ==================== CALENDAR ====================
+
+// Location returns the configured business timezone, or the server's local time
+// when the settings cannot be loaded
+func (s *BusinessHoursService) Location() *time.Location {
+	settings, err := s.GetSettings()
+	if err != nil {
+		s.logger.Warn("Failed to load business hours, using server timezone", "error", err)
+		return time.Local
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// IsOpen reports whether t falls within business hours.
+// When business hours are disabled or cannot be loaded, the business is always open.
+func (s *BusinessHoursService) IsOpen(t time.Time) bool {
+	cal, err := s.loadCalendar()
+	if err != nil {
+		s.logger.Warn("Failed to load business hours, assuming open", "error", err)
+		return true
+	}
+	return cal.isOpen(t)
+}
+
+// GetStatus returns the current open/closed state and the next opening time
+func (s *BusinessHoursService) GetStatus(t time.Time) (*BusinessHoursStatus, error) {
+	cal, err := s.loadCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BusinessHoursStatus{
+		Enabled:  cal.enabled(),
+		Open:     cal.isOpen(t),
+		Timezone: cal.loc.String(),
+		Now:      t.In(cal.loc),
+	}
+	if !status.Open {
+		if next, ok := cal.nextOpen(t); ok {
+			status.NextOpen = &next
+		}
+	}
+
+	return status, nil
+}
+
+// OffHoursReply returns the out-of-office message for a contact when the business is closed.
+// lastSent is when the contact last got it; a contact writing several times in the same
+// closed period is answered once, so later calls return an empty message.
+// The second return value is false while the business is open.
+func (s *BusinessHoursService) OffHoursReply(contactName string, t time.Time, lastSent *time.Time) (string, bool) {
+	cal, err := s.loadCalendar()
+	if err != nil {
+		s.logger.Warn("Failed to load business hours, skipping off-hours reply", "error", err)
+		return "", false
+	}
+	if cal.isOpen(t) {
+		return "", false
+	}
+	if lastSent != nil && cal.sameClosedPeriod(*lastSent, t) {
+		return "", true
+	}
+
+	message := cal.settings.OffHoursMessage
+	if message == "" {
+		message = defaultOffHoursMessage
+	}
+
+	nextOpen := "hari kerja berikutnya"
+	if next, ok := cal.nextOpen(t); ok {
+		nextOpen = next.Format("02 Jan 2006 15:04 MST")
+	}
+
+	message = strings.ReplaceAll(message, "{name}", contactName)
+	message = strings.ReplaceAll(message, "{next_open}", nextOpen)
+	return message, true
+}
+
+// SLADeadline returns when a first response is due for a message received at start,
+// counting only business hours. Returns nil when no SLA target is configured.
+func (s *BusinessHoursService) SLADeadline(start time.Time) *time.Time {
+	cal, err := s.loadCalendar()
+	if err != nil {
+		s.logger.Warn("Failed to load business hours, skipping SLA deadline", "error", err)
+		return nil
+	}
+	if cal.settings.SLAMinutes <= 0 {
+		return nil
+	}
+
+	due := cal.addBusinessDuration(start, time.Duration(cal.settings.SLAMinutes)*time.Minute)
+	return &due
+}
+
+// businessCalendar is a snapshot of settings, schedule and holidays used for time calculations
+type businessCalendar struct {
+	settings *models.BusinessHours
+	loc      *time.Location
+	days     map[time.Weekday]models.BusinessSchedule
+	holidays map[string]bool
+}
+
+func (s *BusinessHoursService) loadCalendar() (*businessCalendar, error) {
+	settings, err := s.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", settings.Timezone)
+	}
+
+	cal := &businessCalendar{
+		settings: settings,
+		loc:      loc,
+		days:     make(map[time.Weekday]models.BusinessSchedule),
+		holidays: make(map[string]bool),
+	}
+
+	if !settings.Enabled {
+		return cal, nil
+	}
+
+	schedule, err := s.repo.FindSchedule()
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range schedule {
+		cal.days[time.Weekday(day.DayOfWeek)] = day
+	}
+
+	holidays, err := s.repo.FindHolidays()
+	if err != nil {
+		return nil, err
+	}
+	for _, holiday := range holidays {
+		cal.holidays[holiday.Date.Format("2006-01-02")] = true
+	}
+
+	return cal, nil
+}
+
+func (c *businessCalendar) enabled() bool {
+	return c.settings.Enabled
+}
+
+// window returns the opening and closing time for the calendar day containing t
+func (c *businessCalendar) window(t time.Time) (time.Time, time.Time, bool) {
+	local := t.In(c.loc)
+	if c.holidays[local.Format("2006-01-02")] {
+		return time.Time{}, time.Time{}, false
+	}
+
+	day, ok := c.days[local.Weekday()]
+	if !ok || day.Closed {
+		return time.Time{}, time.Time{}, false
+	}
+
+	open, err := time.Parse("15:04", day.OpenTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	closing, err := time.Parse("15:04", day.CloseTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	year, month, date := local.Date()
+	start := time.Date(year, month, date, open.Hour(), open.Minute(), 0, 0, c.loc)
+	end := time.Date(year, month, date, closing.Hour(), closing.Minute(), 0, 0, c.loc)
+	return start, end, end.After(start)
+}
+
+func (c *businessCalendar) isOpen(t time.Time) bool {
+	if !c.enabled() {
+		return true
+	}
+
+	start, end, ok := c.window(t)
+	return ok && !t.Before(start) && t.Before(end)
+}
+
+// nextOpen finds the next opening time at or after t, looking up to a year ahead
+func (c *businessCalendar) nextOpen(t time.Time) (time.Time, bool) {
+	if c.isOpen(t) {
+		return t.In(c.loc), true
+	}
+
+	local := t.In(c.loc)
+	for i := 0; i <= 366; i++ {
+		day := local.AddDate(0, 0, i)
+		start, _, ok := c.window(day)
+		if ok && !start.Before(local) {
+			return start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// sameClosedPeriod reports whether a and b fall in the same stretch of closed hours,
+// i.e. both are outside business hours and the business does not open between them
+func (c *businessCalendar) sameClosedPeriod(a, b time.Time) bool {
+	if c.isOpen(a) || c.isOpen(b) {
+		return false
+	}
+
+	nextA, okA := c.nextOpen(a)
+	nextB, okB := c.nextOpen(b)
+	if !okA || !okB {
+		// Nothing opens within a year of either; treat it as one long closure
+		return okA == okB
+	}
+	return nextA.Equal(nextB)
+}
+
+// addBusinessDuration advances start by d, counting only time inside business hours
+func (c *businessCalendar) addBusinessDuration(start time.Time, d time.Duration) time.Time {
+	if !c.enabled() {
+		return start.Add(d)
+	}
+
+	current := start.In(c.loc)
+	remaining := d
+
+	for i := 0; i <= 366 && remaining > 0; i++ {
+		open, ok := c.nextOpen(current)
+		if !ok {
+			break
+		}
+
+		_, end, _ := c.window(open)
+		available := end.Sub(open)
+		if remaining <= available {
+			return open.Add(remaining)
+		}
+
+		remaining -= available
+		current = end
+	}
+
+	// No business hours configured ahead; fall back to wall-clock time
+	return start.Add(d)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"testing"
+	"time"
+)
+
+// testCalendar is open 09:00-17:00 Monday to Friday and 09:00-12:00 on Saturday in WIB,
+// with 2026-10-21 (Wednesday) as a holiday
+func testCalendar(t *testing.T) *businessCalendar {
+	t.Helper()
+	loc := time.FixedZone("WIB", 7*60*60)
+
+	cal := &businessCalendar{
+		settings: &models.BusinessHours{Enabled: true, SLAMinutes: 60},
+		loc:      loc,
+		days:     make(map[time.Weekday]models.BusinessSchedule),
+		holidays: map[string]bool{"2026-10-21": true},
+	}
+	for day := time.Monday; day <= time.Friday; day++ {
+		cal.days[day] = models.BusinessSchedule{DayOfWeek: int(day), OpenTime: "09:00", CloseTime: "17:00"}
+	}
+	cal.days[time.Saturday] = models.BusinessSchedule{DayOfWeek: int(time.Saturday), OpenTime: "09:00", CloseTime: "12:00"}
+	cal.days[time.Sunday] = models.BusinessSchedule{DayOfWeek: int(time.Sunday), Closed: true}
+	return cal
+}
+
+func wib(cal *businessCalendar, day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, cal.loc)
+}
+
+func TestBusinessCalendarNextOpen(t *testing.T) {
+	cal := testCalendar(t)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"already open", wib(cal, 19, 10, 30), wib(cal, 19, 10, 30)},
+		{"before opening", wib(cal, 19, 7, 0), wib(cal, 19, 9, 0)},
+		{"at closing", wib(cal, 19, 17, 0), wib(cal, 20, 9, 0)},
+		{"evening before a holiday", wib(cal, 20, 20, 0), wib(cal, 22, 9, 0)},
+		{"saturday afternoon", wib(cal, 24, 13, 0), wib(cal, 26, 9, 0)},
+		{"sunday", wib(cal, 25, 10, 0), wib(cal, 26, 9, 0)},
+		{"other timezone", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), wib(cal, 19, 9, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cal.nextOpen(tt.at)
+			if !ok {
+				t.Fatalf("nextOpen(%v) found no opening", tt.at)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextOpen(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessCalendarAddBusinessDuration(t *testing.T) {
+	cal := testCalendar(t)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		d     time.Duration
+		want  time.Time
+	}{
+		{"within the day", wib(cal, 19, 10, 0), time.Hour, wib(cal, 19, 11, 0)},
+		{"ends at closing", wib(cal, 19, 16, 0), time.Hour, wib(cal, 19, 17, 0)},
+		{"carries over to the next day", wib(cal, 19, 16, 30), time.Hour, wib(cal, 20, 9, 30)},
+		{"received overnight", wib(cal, 19, 22, 0), time.Hour, wib(cal, 20, 10, 0)},
+		{"skips the holiday", wib(cal, 20, 16, 30), time.Hour, wib(cal, 22, 9, 30)},
+		{"short saturday and closed sunday", wib(cal, 24, 11, 30), time.Hour, wib(cal, 26, 9, 30)},
+		{"spans several days", wib(cal, 19, 9, 0), 10 * time.Hour, wib(cal, 20, 11, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cal.addBusinessDuration(tt.start, tt.d); !got.Equal(tt.want) {
+				t.Errorf("addBusinessDuration(%v, %v) = %v, want %v", tt.start, tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessCalendarDisabled(t *testing.T) {
+	cal := testCalendar(t)
+	cal.settings.Enabled = false
+	sunday := wib(cal, 25, 3, 0)
+
+	if !cal.isOpen(sunday) {
+		t.Errorf("isOpen(%v) = false with business hours disabled", sunday)
+	}
+	if got := cal.addBusinessDuration(sunday, time.Hour); !got.Equal(sunday.Add(time.Hour)) {
+		t.Errorf("addBusinessDuration with business hours disabled = %v, want %v", got, sunday.Add(time.Hour))
+	}
+}
+
+func TestBusinessCalendarSameClosedPeriod(t *testing.T) {
+	cal := testCalendar(t)
+
+	tests := []struct {
+		name string
+		a, b time.Time
+		want bool
+	}{
+		{"same night", wib(cal, 19, 18, 0), wib(cal, 20, 7, 0), true},
+		{"across the weekend", wib(cal, 24, 13, 0), wib(cal, 25, 20, 0), true},
+		{"across the holiday", wib(cal, 20, 18, 0), wib(cal, 21, 12, 0), true},
+		{"consecutive nights", wib(cal, 19, 18, 0), wib(cal, 20, 18, 0), false},
+		{"one side open", wib(cal, 19, 10, 0), wib(cal, 19, 18, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cal.sameClosedPeriod(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameClosedPeriod(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var cannedVariablePattern = regexp.MustCompile(`\{([a-z_]+(?:\.[a-z_]+)?)\}`)
+
+type CannedResponseService struct {
+	repo        *repository.CannedResponseRepository
+	contactRepo *repository.ContactRepository
+	productRepo *repository.ProductRepository
+	chatRepo    *repository.ChatRepository
+	agentRepo   *repository.HumanAgentRepository
+	logger      *utils.Logger
+}
+
+func NewCannedResponseService(
+	repo *repository.CannedResponseRepository,
+	contactRepo *repository.ContactRepository,
+	productRepo *repository.ProductRepository,
+	chatRepo *repository.ChatRepository,
+	agentRepo *repository.HumanAgentRepository,
+	logger *utils.Logger,
+) *CannedResponseService {
+	return &CannedResponseService{
+		repo:        repo,
+		contactRepo: contactRepo,
+		productRepo: productRepo,
+		chatRepo:    chatRepo,
+		agentRepo:   agentRepo,
+		logger:      logger,
+	}
+}
+
+// RenderRequest selects the records used to fill in a canned response.
+// ChatID resolves the contact when ContactID is not given.
+type RenderRequest struct {
+	ContactID   uint   `json:"contact_id"`
+	ChatID      uint   `json:"chat_id"`
+	ProductID   uint   `json:"product_id"`
+	ProductCode string `json:"product_code"`
+}
+
+// RenderedResponse is a canned response with its variables filled in
+type RenderedResponse struct {
+	ID       uint     `json:"id"`
+	Shortcut string   `json:"shortcut"`
+	Content  string   `json:"content"`
+	Missing  []string `json:"missing,omitempty"` // Variables that could not be resolved
+}
+
+// GetAll returns the shared library and the agent's own responses
+func (s *CannedResponseService) GetAll(agentID uint, category string) ([]models.CannedResponse, error) {
+	return s.repo.FindVisible(agentID, category)
+}
+
+// Search finds responses for the composer by shortcut, title or content
+func (s *CannedResponseService) Search(agentID uint, query string, limit int) ([]models.CannedResponse, error) {
+	query = strings.TrimSpace(query)
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	if query == "" {
+		responses, err := s.repo.FindVisible(agentID, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(responses) > limit {
+			responses = responses[:limit]
+		}
+		return responses, nil
+	}
+	return s.repo.Search(agentID, query, limit)
+}
+
+func (s *CannedResponseService) GetCategories(agentID uint) ([]string, error) {
+	return s.repo.FindCategories(agentID)
+}
+
+// Create saves a personal response, or a shared one when shared is set by an admin or supervisor
+func (s *CannedResponseService) Create(response *models.CannedResponse, agentID uint, role string, shared bool) error {
+	if shared {
+		if !canManageShared(role) {
+			return fmt.Errorf("only admins and supervisors can create shared responses")
+		}
+		response.OwnerID = nil
+	} else {
+		response.OwnerID = &agentID
+	}
+	response.UsageCount = 0
+
+	if err := s.validate(response); err != nil {
+		return err
+	}
+
+	s.logger.Info("Creating canned response", "shortcut", response.Shortcut, "shared", shared)
+	return s.repo.Create(response)
+}
+
+// Update edits a response the agent is allowed to manage; ownership cannot change
+func (s *CannedResponseService) Update(response *models.CannedResponse, agentID uint, role string) error {
+	existing, err := s.getManageable(response.ID, agentID, role)
+	if err != nil {
+		return err
+	}
+
+	response.OwnerID = existing.OwnerID
+	response.UsageCount = existing.UsageCount
+	response.CreatedAt = existing.CreatedAt
+
+	if err := s.validate(response); err != nil {
+		return err
+	}
+
+	s.logger.Info("Updating canned response", "id", response.ID)
+	return s.repo.Update(response)
+}
+
+func (s *CannedResponseService) Delete(id, agentID uint, role string) error {
+	if _, err := s.getManageable(id, agentID, role); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleting canned response", "id", id)
+	return s.repo.Delete(id)
+}
+
+// Render fills in the response variables and counts it as used
+func (s *CannedResponseService) Render(id, agentID uint, req *RenderRequest) (*RenderedResponse, error) {
+	response, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil || (response.OwnerID != nil && *response.OwnerID != agentID) {
+		return nil, fmt.Errorf("canned response not found")
+	}
+
+	vars, err := s.buildVariables(agentID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	content, missing := renderCannedContent(response.Content, vars)
+
+	if err := s.repo.IncrementUsage(response.ID); err != nil {
+		s.logger.Warn("Failed to update canned response usage", "id", response.ID, "error", err)
+	}
+
+	return &RenderedResponse{
+		ID:       response.ID,
+		Shortcut: response.Shortcut,
+		Content:  content,
+		Missing:  missing,
+	}, nil
+}
+
+func (s *CannedResponseService) getManageable(id, agentID uint, role string) (*models.CannedResponse, error) {
+	existing, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("canned response not found")
+	}
+	if existing.OwnerID == nil {
+		if !canManageShared(role) {
+			return nil, fmt.Errorf("only admins and supervisors can change shared responses")
+		}
+	} else if *existing.OwnerID != agentID {
+		return nil, fmt.Errorf("canned response not found")
+	}
+	return existing, nil
+}
+
+func (s *CannedResponseService) validate(response *models.CannedResponse) error {
+	shortcut := strings.ToLower(strings.TrimSpace(response.Shortcut))
+	if shortcut == "" {
+		return fmt.Errorf("shortcut is required")
+	}
+	if !strings.HasPrefix(shortcut, "/") {
+		shortcut = "/" + shortcut
+	}
+	if strings.ContainsAny(shortcut, " \t\n") {
+		return fmt.Errorf("shortcut cannot contain spaces")
+	}
+	response.Shortcut = shortcut
+	response.Category = strings.TrimSpace(response.Category)
+
+	if strings.TrimSpace(response.Content) == "" {
+		return fmt.Errorf("content is required")
+	}
+
+	duplicate, err := s.repo.FindByShortcut(shortcut, response.OwnerID)
+	if err != nil {
+		return err
+	}
+	if duplicate != nil && duplicate.ID != response.ID {
+		return fmt.Errorf("shortcut %s already exists", shortcut)
+	}
+
+	return nil
+}
+
+// buildVariables collects contact, agent and product values for interpolation
+func (s *CannedResponseService) buildVariables(agentID uint, req *RenderRequest) (map[string]string, error) {
+	vars := map[string]string{}
+
+	if agent, err := s.agentRepo.FindByID(agentID); err == nil {
+		name := agent.FullName
+		if name == "" {
+			name = agent.Username
+		}
+		vars["agent.name"] = name
+	}
+
+	contactID := req.ContactID
+	if contactID == 0 && req.ChatID != 0 {
+		chat, err := s.chatRepo.FindByID(req.ChatID)
+		if err != nil {
+			return nil, fmt.Errorf("chat not found")
+		}
+		contactID = chat.ContactID
+	}
+	if contactID != 0 {
+		contact, err := s.contactRepo.FindByID(contactID)
+		if err != nil {
+			return nil, err
+		}
+		if contact == nil {
+			return nil, fmt.Errorf("contact not found")
+		}
+		vars["name"] = contact.Name
+		vars["contact.name"] = contact.Name
+		vars["contact.code"] = contact.Code
+		vars["contact.channel"] = contact.Channel
+		vars["contact.channel_id"] = contact.ChannelID
+	}
+
+	var product *models.Product
+	if req.ProductID != 0 {
+		found, err := s.productRepo.FindByID(req.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("product not found")
+		}
+		product = found
+	} else if code := strings.TrimSpace(req.ProductCode); code != "" {
+		found, err := s.productRepo.FindByCode(code)
+		if err != nil {
+			return nil, fmt.Errorf("product not found")
+		}
+		product = found
+	}
+	if product != nil {
+		vars["product.name"] = product.Name
+		vars["product.code"] = product.Code
+		vars["product.price"] = formatRupiah(product.Price)
+		vars["product.stock"] = strconv.Itoa(product.Stock)
+		vars["product.description"] = product.Description
+	}
+
+	return vars, nil
+}
+
+// renderCannedContent replaces {variable} placeholders, leaving unknown ones untouched
+func renderCannedContent(content string, vars map[string]string) (string, []string) {
+	var missing []string
+	seen := map[string]bool{}
+
+	rendered := cannedVariablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		key := match[1 : len(match)-1]
+		if value, ok := vars[key]; ok {
+			return value
+		}
+		if !seen[key] {
+			seen[key] = true
+			missing = append(missing, key)
+		}
+		return match
+	})
+
+	return rendered, missing
+}
+
+func canManageShared(role string) bool {
+	return strings.EqualFold(role, "Admin") || strings.EqualFold(role, "Supervisor")
+}
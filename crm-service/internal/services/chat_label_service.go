@@ -26,6 +26,11 @@ func (s *ChatLabelService) GetByID(id uint) (*models.ChatLabel, error) {
 	return s.repo.FindByID(id)
 }
 
+// GetCounts returns the number of conversations per label
+func (s *ChatLabelService) GetCounts() ([]repository.LabelCount, error) {
+	return s.repo.CountChats()
+}
+
 func (s *ChatLabelService) Create(label *models.ChatLabel) error {
 	s.logger.Info("Creating chat label", "label", label.Label)
 	return s.repo.Create(label)
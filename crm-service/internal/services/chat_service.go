@@ -5,31 +5,49 @@ import (
 	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
-	"strings"
+	"errors"
 	"time"
 )
 
 type ChatService struct {
-	chatRepo       *repository.ChatRepository
-	contactService *ContactService
-	aiService      *AIService
-	productService *ProductService
-	logger         *utils.Logger
+	chatRepo             *repository.ChatRepository
+	chatLabelRepo        *repository.ChatLabelRepository
+	contactService       *ContactService
+	aiService            *AIService
+	productService       *ProductService
+	businessHoursService *BusinessHoursService
+	automationService    *AutomationService
+	quickReplyService    *QuickReplyService
+	consentService       *ConsentService
+	broadcastService     *BroadcastService
+	logger               *utils.Logger
 }
 
 func NewChatService(
 	chatRepo *repository.ChatRepository,
+	chatLabelRepo *repository.ChatLabelRepository,
 	contactService *ContactService,
 	aiService *AIService,
 	productService *ProductService,
+	businessHoursService *BusinessHoursService,
+	automationService *AutomationService,
+	quickReplyService *QuickReplyService,
+	consentService *ConsentService,
+	broadcastService *BroadcastService,
 	logger *utils.Logger,
 ) *ChatService {
 	return &ChatService{
-		chatRepo:       chatRepo,
-		contactService: contactService,
-		aiService:      aiService,
-		productService: productService,
-		logger:         logger,
+		chatRepo:             chatRepo,
+		chatLabelRepo:        chatLabelRepo,
+		contactService:       contactService,
+		aiService:            aiService,
+		productService:       productService,
+		businessHoursService: businessHoursService,
+		automationService:    automationService,
+		quickReplyService:    quickReplyService,
+		consentService:       consentService,
+		broadcastService:     broadcastService,
+		logger:               logger,
 	}
 }
 
@@ -48,6 +66,11 @@ func (s *ChatService) GetByStatus(status string) ([]models.ChatMessage, error) {
 	return s.chatRepo.FindByStatus(status)
 }
 
+// GetSLABreached returns unanswered messages past their first response deadline
+func (s *ChatService) GetSLABreached() ([]models.ChatMessage, error) {
+	return s.chatRepo.FindSLABreached(time.Now())
+}
+
 // GetByContactID returns all messages for a specific contact
 func (s *ChatService) GetByContactID(contactID uint) ([]models.ChatMessage, error) {
 	return s.chatRepo.FindByContactID(contactID)
@@ -61,13 +84,29 @@ func (s *ChatService) GetByChannel(channel string) ([]models.ChatMessage, error)
 // Create creates a new chat message
 func (s *ChatService) Create(message *models.ChatMessage) error {
 	s.logger.Info("Creating chat message", "contact_id", message.ContactID)
-	return s.chatRepo.Create(message)
+	if err := s.chatRepo.Create(message); err != nil {
+		return err
+	}
+	return s.markResponded(message)
 }
 
 // Update updates a chat message
 func (s *ChatService) Update(message *models.ChatMessage) error {
 	s.logger.Info("Updating chat message", "id", message.ID)
-	return s.chatRepo.Update(message)
+	if err := s.chatRepo.Update(message); err != nil {
+		return err
+	}
+	return s.markResponded(message)
+}
+
+// markResponded stops the SLA clock on the contact's waiting messages once an
+// agent's answer is saved
+func (s *ChatService) markResponded(message *models.ChatMessage) error {
+	if message.Response == "" || message.ContactID == 0 {
+		return nil
+	}
+	now := time.Now()
+	return s.chatRepo.MarkResponded(message.ContactID, now, now)
 }
 
 // Assign assigns a chat to an agent
@@ -128,29 +167,87 @@ func (s *ChatService) BackToAI(id uint) error {
 	return s.chatRepo.Update(message)
 }
 
-// AddLabel adds a label to a chat message
-func (s *ChatService) AddLabel(id uint, labelID string) error {
-	message, err := s.chatRepo.FindByID(id)
+// GetByLabels returns chat messages carrying any of the given labels
+func (s *ChatService) GetByLabels(labelIDs []uint) ([]models.ChatMessage, error) {
+	return s.chatRepo.FindByLabels(labelIDs)
+}
+
+// AddLabels adds labels to a chat message
+func (s *ChatService) AddLabels(id uint, labelIDs []uint) error {
+	return s.BulkApplyLabels([]uint{id}, labelIDs)
+}
+
+// RemoveLabel removes a label from a chat message
+func (s *ChatService) RemoveLabel(id, labelID uint) error {
+	s.logger.Info("Removing label from chat", "id", id, "label", labelID)
+	return s.chatRepo.RemoveLabel(id, labelID)
+}
+
+// BulkApplyLabels adds every label to every chat message after validating both exist
+func (s *ChatService) BulkApplyLabels(chatIDs, labelIDs []uint) error {
+	chatIDs = uniqueIDs(chatIDs)
+	labelIDs = uniqueIDs(labelIDs)
+	if len(chatIDs) == 0 || len(labelIDs) == 0 {
+		return errors.New("chat_ids and label_ids are required")
+	}
+
+	labels, err := s.chatLabelRepo.FindByIDs(labelIDs)
 	if err != nil {
 		return err
 	}
+	if len(labels) != len(labelIDs) {
+		return errors.New("one or more labels do not exist")
+	}
 
-	// Add label to comma-separated list
-	if message.Labels == "" {
-		message.Labels = labelID
-	} else {
-		// Check if label already exists
-		labels := strings.Split(message.Labels, ",")
-		for _, l := range labels {
-			if strings.TrimSpace(l) == labelID {
-				return nil // Label already exists
-			}
+	chats, err := s.chatRepo.CountExisting(chatIDs)
+	if err != nil {
+		return err
+	}
+	if int(chats) != len(chatIDs) {
+		return errors.New("one or more chats do not exist")
+	}
+
+	s.logger.Info("Applying labels to chats", "chats", len(chatIDs), "labels", len(labelIDs))
+	if err := s.chatRepo.AddLabels(chatIDs, labelIDs); err != nil {
+		return err
+	}
+
+	if s.automationService != nil {
+		go s.fireLabelAdded(chatIDs, labelIDs)
+	}
+	return nil
+}
+
+// fireLabelAdded runs label_added automation rules for each labelled chat
+func (s *ChatService) fireLabelAdded(chatIDs, labelIDs []uint) {
+	for _, chatID := range chatIDs {
+		chat, err := s.chatRepo.FindByID(chatID)
+		if err != nil {
+			s.logger.Warn("Failed to load chat for label automation", "id", chatID, "error", err)
+			continue
 		}
-		message.Labels = message.Labels + "," + labelID
+
+		s.automationService.Fire(&AutomationEvent{
+			Trigger:  models.TriggerLabelAdded,
+			Contact:  &chat.Contact,
+			Chat:     chat,
+			Message:  chat.Message,
+			LabelIDs: labelIDs,
+		}, false)
 	}
+}
 
-	s.logger.Info("Adding label to chat", "id", id, "label", labelID)
-	return s.chatRepo.Update(message)
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool)
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
 }
 
 // GetStats returns chat statistics
@@ -167,6 +264,10 @@ func (s *ChatService) GetStats() (map[string]interface{}, error) {
 	stats["resolved"] = resolved
 	stats["total"] = unassigned + assigned + resolved
 
+	// Unanswered past their first response deadline
+	breached, _ := s.chatRepo.CountSLABreached(time.Now())
+	stats["sla_breached"] = breached
+
 	// Count by channel
 	whatsapp, _ := s.chatRepo.CountByChannel("WhatsApp")
 	instagram, _ := s.chatRepo.CountByChannel("Instagram")
@@ -217,6 +318,18 @@ func (s *ChatService) ProcessIncomingMessage(
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if s.businessHoursService != nil {
+		incomingMsg.SLADueAt = s.businessHoursService.SLADeadline(incomingMsg.CreatedAt)
+	}
+
+	// Link the message to the broadcast it answers, if one was sent recently
+	var campaign *models.BroadcastRecipient
+	if s.broadcastService != nil {
+		campaign = s.broadcastService.ReplyTarget(contact.ID, incomingMsg.CreatedAt)
+		if campaign != nil {
+			incomingMsg.BroadcastHistoryID = &campaign.HistoryID
+		}
+	}
 
 	if err := s.chatRepo.Create(incomingMsg); err != nil {
 		s.logger.Error("Failed to save incoming message", "error", err)
@@ -224,45 +337,137 @@ func (s *ChatService) ProcessIncomingMessage(
 	}
 	s.logger.Info("📝 Incoming message saved", "msg_id", incomingMsg.ID)
 
-	// 3. Generate AI response with RAG context
+	// 3. STOP/BERHENTI and MULAI/SUBSCRIBE change broadcast consent and are answered directly
+	if s.consentService != nil {
+		if reply, handled := s.consentService.HandleKeyword(contact, message); handled {
+			return s.saveOutgoingMessage(contact, platform, message, reply, "Answered", "Auto Reply", "Consent")
+		}
+	}
+
+	// A reply to a recent broadcast counts towards its reply rate
+	if campaign != nil {
+		s.broadcastService.RecordReply(campaign, incomingMsg.CreatedAt)
+	}
+
+	// 4. Run automation rules; a reply or assign action answers instead of the AI
+	if s.automationService != nil {
+		result := s.automationService.Fire(&AutomationEvent{
+			Trigger: models.TriggerMessageReceived,
+			Contact: contact,
+			Chat:    incomingMsg,
+			Message: message,
+		}, true)
+		if result.Reply != "" {
+			s.logger.Info("⚙️ Automation rule replied", "contact_id", contact.ID, "rules", result.Matched)
+			return s.saveOutgoingMessage(contact, platform, message, result.Reply, "Answered", "Automation", "Automation Rules")
+		}
+		if result.Handled {
+			// The chat was assigned to an agent; quick replies and the AI stay out of it
+			s.logger.Info("⚙️ Automation rule took over the chat", "contact_id", contact.ID, "rules", result.Matched)
+			return incomingMsg, "", nil
+		}
+	}
+
+	// 5. Quick replies answer known questions without calling the AI
+	if s.quickReplyService != nil {
+		reply, err := s.quickReplyService.CheckQuickReply(message, platform)
+		if err != nil {
+			s.logger.Warn("Quick reply lookup failed", "error", err)
+		} else if reply != nil {
+			s.logger.Info("⚡ Quick reply matched", "contact_id", contact.ID, "trigger", reply.Trigger, "mode", reply.MatchMode)
+			return s.saveOutgoingMessage(contact, platform, message, reply.Response, "Answered", "Quick Reply", reply.Trigger)
+		}
+	}
+
+	// 6. Outside business hours, send the out-of-office reply once and leave the chat for the team
+	if s.businessHoursService != nil {
+		lastSent, err := s.chatRepo.FindLatestReplyTime(contact.ID, "Off-Hours")
+		if err != nil {
+			s.logger.Warn("Failed to look up the last off-hours reply", "contact_id", contact.ID, "error", err)
+		}
+		if offHoursReply, closed := s.businessHoursService.OffHoursReply(contact.Name, time.Now(), lastSent); closed {
+			if offHoursReply == "" {
+				s.logger.Info("🌙 Outside business hours, off-hours reply already sent", "contact_id", contact.ID)
+				return incomingMsg, "", nil
+			}
+			s.logger.Info("🌙 Outside business hours, sending off-hours reply", "contact_id", contact.ID)
+			return s.saveOutgoingMessage(contact, platform, message, offHoursReply, "Pending", "Auto Reply", "Off-Hours")
+		}
+	}
+
+	// 7. Generate AI response with RAG context
 	ctx := context.Background()
 	s.logger.Info("🤖 Generating AI response with RAG...")
 
-	aiResponse, err := s.aiService.GenerateResponse(
+	// Buying questions get the matching products with current price and stock
+	productContext := ""
+	if s.productService != nil && s.productService.HasPurchaseIntent(message) {
+		productContext = s.productService.ProductContext(message)
+		if productContext != "" {
+			s.logger.Info("🛒 Purchase intent, adding products to the prompt", "contact_id", contact.ID)
+		}
+	}
+
+	aiReply, err := s.aiService.GenerateReply(
 		ctx,
 		message,
 		contact.Name,
 		contact.ID, // ✅ Pass contactID for chat history
+		productContext,
 	)
 
+	aiResponse, status := "", "Answered"
 	if err != nil {
+		// The apology promises a follow-up, so the chat still waits for the team
 		s.logger.Error("AI processing failed", "error", err)
+		status = "Pending"
 		aiResponse = "Maaf, saat ini sistem sedang sibuk. Tim kami akan segera menghubungi Anda. 🙏"
 	} else {
 		s.logger.Info("✅ AI response generated successfully")
+		aiResponse = aiReply.Text
+		if aiReply.NeedsAgent {
+			// Nothing in the knowledge base answered this, leave the chat for the team
+			s.logger.Info("🙋 No relevant context, leaving chat pending for an agent", "contact_id", contact.ID)
+			status = "Pending"
+		}
 	}
 
-	// 4. Save outgoing message
+	// 8. Save outgoing message
+	return s.saveOutgoingMessage(contact, platform, message, aiResponse, status, "AI Bot", "AI Assistant")
+}
+
+// saveOutgoingMessage stores the reply sent back to the contact
+func (s *ChatService) saveOutgoingMessage(
+	contact *models.Contact,
+	platform, message, response, status, assignedTo, assignedAgent string,
+) (*models.ChatMessage, string, error) {
 	outgoingMsg := &models.ChatMessage{
 		ContactID:     contact.ID,
 		ContactName:   contact.Name,
 		Message:       message,
-		Response:      aiResponse,
+		Response:      response,
 		Channel:       platform,
-		Status:        "Answered",
+		Status:        status,
 		TokensUsed:    0, // Can be calculated if needed
-		AssignedTo:    "AI Bot",
-		AssignedAgent: "AI Assistant",
+		AssignedTo:    assignedTo,
+		AssignedAgent: assignedAgent,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
 	if err := s.chatRepo.Create(outgoingMsg); err != nil {
 		s.logger.Error("Failed to save outgoing message", "error", err)
-		return nil, aiResponse, err
+		return nil, response, err
 	}
 	s.logger.Info("✅ Outgoing message saved", "msg_id", outgoingMsg.ID)
 
+	// Only a real answer counts as the first response; holding replies leave the SLA running
+	if status == "Answered" {
+		if err := s.chatRepo.MarkResponded(contact.ID, outgoingMsg.CreatedAt, outgoingMsg.CreatedAt); err != nil {
+			s.logger.Warn("Failed to record first response", "contact_id", contact.ID, "error", err)
+		}
+	}
+
 	s.logger.Info("🎉 Message processing completed successfully")
-	return outgoingMsg, aiResponse, nil
+	return outgoingMsg, response, nil
 }
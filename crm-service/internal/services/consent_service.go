@@ -0,0 +1,237 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keywords a contact can send to unsubscribe from or resubscribe to broadcasts
+var (
+	optOutKeywords = map[string]bool{"STOP": true, "BERHENTI": true, "UNSUBSCRIBE": true, "UNSUB": true, "STOP PROMO": true}
+	// No START: Telegram sends /start when a user first opens the bot, which is not consent
+	optInKeywords = map[string]bool{"MULAI": true, "SUBSCRIBE": true}
+)
+
+const (
+	optOutReply = "Anda telah berhenti berlangganan pesan promosi dari kami. Balas MULAI jika ingin berlangganan kembali."
+	optInReply  = "Terima kasih! Anda kembali berlangganan pesan promosi dari kami. Balas BERHENTI kapan saja untuk berhenti."
+)
+
+type ConsentService struct {
+	repo        *repository.ConsentRepository
+	contactRepo *repository.ContactRepository
+	logger      *utils.Logger
+}
+
+func NewConsentService(repo *repository.ConsentRepository, contactRepo *repository.ContactRepository, logger *utils.Logger) *ConsentService {
+	return &ConsentService{
+		repo:        repo,
+		contactRepo: contactRepo,
+		logger:      logger,
+	}
+}
+
+// ConsentRequest changes the marketing consent of a contact
+type ConsentRequest struct {
+	Status    string `json:"status"` // opted_in, opted_out
+	Source    string `json:"source"` // agent, api, import, ...
+	Detail    string `json:"detail"`
+	ChangedBy string `json:"changed_by"`
+}
+
+// ContactConsentState is the consent, suppression and change history of a contact
+type ContactConsentState struct {
+	ContactID  uint                   `json:"contact_id"`
+	Channel    string                 `json:"channel"`
+	Consent    *models.ContactConsent `json:"consent"`
+	Suppressed bool                   `json:"suppressed"`
+	History    []models.ConsentLog    `json:"history"`
+}
+
+// GetState returns the consent state of a contact on its channel
+func (s *ConsentService) GetState(contactID uint) (*ContactConsentState, error) {
+	contact, err := s.contactRepo.FindByID(contactID)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil {
+		return nil, fmt.Errorf("contact not found")
+	}
+
+	consent, err := s.repo.FindConsent(contact.ID, contact.Channel)
+	if err != nil {
+		return nil, err
+	}
+	suppressed, err := s.repo.IsSuppressed(contact.Channel, contact.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.repo.FindLogs(contact.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContactConsentState{
+		ContactID:  contact.ID,
+		Channel:    contact.Channel,
+		Consent:    consent,
+		Suppressed: suppressed,
+		History:    history,
+	}, nil
+}
+
+// SetConsent records a consent change for a contact on its channel
+func (s *ConsentService) SetConsent(contactID uint, req *ConsentRequest) (*ContactConsentState, error) {
+	if req.Status != models.ConsentOptedIn && req.Status != models.ConsentOptedOut {
+		return nil, fmt.Errorf("status must be %s or %s", models.ConsentOptedIn, models.ConsentOptedOut)
+	}
+
+	contact, err := s.contactRepo.FindByID(contactID)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil {
+		return nil, fmt.Errorf("contact not found")
+	}
+
+	source := req.Source
+	if source == "" {
+		source = "agent"
+	}
+	if err := s.apply(contact, req.Status, source, req.Detail, req.ChangedBy); err != nil {
+		return nil, err
+	}
+
+	return s.GetState(contactID)
+}
+
+// HandleKeyword unsubscribes or resubscribes a contact that sent STOP/BERHENTI or MULAI/SUBSCRIBE.
+// It returns the confirmation to send back, or false when the message is not a keyword.
+func (s *ConsentService) HandleKeyword(contact *models.Contact, message string) (string, bool) {
+	keyword := normalizeKeyword(message)
+
+	var status, reply string
+	switch {
+	case optOutKeywords[keyword]:
+		status, reply = models.ConsentOptedOut, optOutReply
+	case optInKeywords[keyword]:
+		status, reply = models.ConsentOptedIn, optInReply
+	default:
+		return "", false
+	}
+
+	if err := s.apply(contact, status, "keyword", keyword, "Contact"); err != nil {
+		s.logger.Error("Failed to record consent keyword", "contact_id", contact.ID, "keyword", keyword, "error", err)
+		return "", false
+	}
+
+	s.logger.Info("📵 Consent keyword received", "contact_id", contact.ID, "keyword", keyword, "status", status)
+	return reply, true
+}
+
+// CanBroadcast reports whether marketing messages may be sent to a contact
+func (s *ConsentService) CanBroadcast(contact *models.Contact) (bool, error) {
+	consent, err := s.repo.FindConsent(contact.ID, contact.Channel)
+	if err != nil {
+		return false, err
+	}
+	if consent != nil && consent.Status == models.ConsentOptedOut {
+		return false, nil
+	}
+
+	suppressed, err := s.repo.IsSuppressed(contact.Channel, contact.ChannelID)
+	if err != nil {
+		return false, err
+	}
+	return !suppressed, nil
+}
+
+// AttachConsent fills in the consent and suppression state of contacts for API responses
+func (s *ConsentService) AttachConsent(contacts []models.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(contacts))
+	channelIDs := map[string][]string{}
+	for i, contact := range contacts {
+		ids[i] = contact.ID
+		channelIDs[contact.Channel] = append(channelIDs[contact.Channel], contact.ChannelID)
+	}
+
+	consents, err := s.repo.FindConsentsByContactIDs(ids)
+	if err != nil {
+		return err
+	}
+	byContact := map[string]*models.ContactConsent{}
+	for i := range consents {
+		byContact[fmt.Sprintf("%d:%s", consents[i].ContactID, consents[i].Channel)] = &consents[i]
+	}
+
+	suppressed := map[string]bool{}
+	for channel, addresses := range channelIDs {
+		matches, err := s.repo.SuppressedChannelIDs(channel, addresses)
+		if err != nil {
+			return err
+		}
+		for _, address := range matches {
+			suppressed[channel+":"+address] = true
+		}
+	}
+
+	for i := range contacts {
+		contact := &contacts[i]
+		contact.Consent = byContact[fmt.Sprintf("%d:%s", contact.ID, contact.Channel)]
+		contact.Suppressed = suppressed[contact.Channel+":"+contact.ChannelID]
+	}
+	return nil
+}
+
+// Suppression list
+
+func (s *ConsentService) GetSuppressions(channel, query string) ([]models.SuppressionEntry, error) {
+	return s.repo.FindSuppressions(channel, query)
+}
+
+func (s *ConsentService) AddSuppression(entry *models.SuppressionEntry) error {
+	entry.ChannelID = strings.TrimSpace(entry.ChannelID)
+	if entry.Channel == "" || entry.ChannelID == "" {
+		return fmt.Errorf("channel and channel_id are required")
+	}
+	if entry.Source == "" {
+		entry.Source = "agent"
+	}
+	entry.CreatedAt = time.Now()
+
+	s.logger.Info("Adding suppression", "channel", entry.Channel, "channel_id", entry.ChannelID)
+	return s.repo.AddSuppression(entry)
+}
+
+func (s *ConsentService) DeleteSuppression(id uint) error {
+	s.logger.Info("Removing suppression", "id", id)
+	return s.repo.DeleteSuppression(id)
+}
+
+// apply saves the consent; the repository keeps the suppression list in step with it
+func (s *ConsentService) apply(contact *models.Contact, status, source, detail, changedBy string) error {
+	consent := &models.ContactConsent{
+		ContactID: contact.ID,
+		Channel:   contact.Channel,
+		Status:    status,
+		Source:    source,
+		Detail:    detail,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+	}
+	return s.repo.SaveConsent(consent, contact.ChannelID)
+}
+
+// normalizeKeyword uppercases a message and collapses whitespace; anything else, such as
+// "/start" or "stop please", is left intact so it never matches a keyword
+func normalizeKeyword(message string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(message), " "))
+}
@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestNormalizeKeyword(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		optOut  bool
+		optIn   bool
+	}{
+		{"stop", "STOP", true, false},
+		{"lowercase with padding", "  stop ", true, false},
+		{"multi word", "stop   promo", true, false},
+		{"berhenti", "Berhenti", true, false},
+		{"mulai", "mulai", false, true},
+		{"subscribe", "SUBSCRIBE", false, true},
+		{"telegram start command", "/start", false, false},
+		{"plain start", "start", false, false},
+		{"punctuation", "stop.", false, false},
+		{"keyword inside a sentence", "jangan stop dulu", false, false},
+		{"empty", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyword := normalizeKeyword(tt.message)
+			if got := optOutKeywords[keyword]; got != tt.optOut {
+				t.Errorf("opt-out match for %q = %v, want %v", tt.message, got, tt.optOut)
+			}
+			if got := optInKeywords[keyword]; got != tt.optIn {
+				t.Errorf("opt-in match for %q = %v, want %v", tt.message, got, tt.optIn)
+			}
+		})
+	}
+}
@@ -9,35 +9,67 @@ import (
 )
 
 type ContactService struct {
-	repo   *repository.ContactRepository
-	logger *utils.Logger
+	repo              *repository.ContactRepository
+	automationService *AutomationService
+	consentService    *ConsentService
+	broadcastService  *BroadcastService
+	logger            *utils.Logger
 }
 
-func NewContactService(repo *repository.ContactRepository, logger *utils.Logger) *ContactService {
+func NewContactService(
+	repo *repository.ContactRepository,
+	automationService *AutomationService,
+	consentService *ConsentService,
+	broadcastService *BroadcastService,
+	logger *utils.Logger,
+) *ContactService {
 	return &ContactService{
-		repo:   repo,
-		logger: logger,
+		repo:              repo,
+		automationService: automationService,
+		consentService:    consentService,
+		broadcastService:  broadcastService,
+		logger:            logger,
 	}
 }
 
 func (s *ContactService) GetAll() ([]models.Contact, error) {
-	return s.repo.FindAll()
+	return s.withConsent(s.repo.FindAll())
 }
 
 func (s *ContactService) GetByID(id uint) (*models.Contact, error) {
-	return s.repo.FindByID(id)
+	contact, err := s.repo.FindByID(id)
+	if err != nil || contact == nil {
+		return contact, err
+	}
+
+	contacts, err := s.withConsent([]models.Contact{*contact}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &contacts[0], nil
 }
 
 func (s *ContactService) GetByStatus(status string) ([]models.Contact, error) {
-	return s.repo.FindByStatus(status)
+	return s.withConsent(s.repo.FindByStatus(status))
 }
 
 func (s *ContactService) GetByTemperature(temp string) ([]models.Contact, error) {
-	return s.repo.FindByTemperature(temp)
+	return s.withConsent(s.repo.FindByTemperature(temp))
 }
 
 func (s *ContactService) Search(query string) ([]models.Contact, error) {
-	return s.repo.Search(query)
+	return s.withConsent(s.repo.Search(query))
+}
+
+// withConsent adds marketing consent and suppression state to contacts returned by the API
+func (s *ContactService) withConsent(contacts []models.Contact, err error) ([]models.Contact, error) {
+	if err != nil || s.consentService == nil {
+		return contacts, err
+	}
+	if err := s.consentService.AttachConsent(contacts); err != nil {
+		return nil, err
+	}
+	return contacts, nil
 }
 
 func (s *ContactService) Create(contact *models.Contact) error {
@@ -50,12 +82,29 @@ func (s *ContactService) Create(contact *models.Contact) error {
 	contact.LastContact = time.Now()
 
 	s.logger.Info("Creating new contact", "code", contact.Code, "name", contact.Name)
-	return s.repo.Create(contact)
+	if err := s.repo.Create(contact); err != nil {
+		return err
+	}
+
+	s.fireContactCreated(contact)
+	return nil
 }
 
 func (s *ContactService) Update(contact *models.Contact) error {
+	previous, err := s.repo.FindByID(contact.ID)
+	if err != nil {
+		return err
+	}
+
 	s.logger.Info("Updating contact", "id", contact.ID, "name", contact.Name)
-	return s.repo.Update(contact)
+	if err := s.repo.Update(contact); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		s.recordConversion(contact.ID, previous.ContactStatus, contact.ContactStatus)
+	}
+	return nil
 }
 
 func (s *ContactService) Delete(id uint) error {
@@ -65,6 +114,7 @@ func (s *ContactService) Delete(id uint) error {
 
 func (s *ContactService) GetOrCreateByChannelID(channel, channelID, name string) (*models.Contact, error) {
 	contact, err := s.repo.FindByChannelID(channel, channelID)
+	now := time.Now()
 
 	if contact == nil {
 		s.logger.Info("Contact not found, creating new", "channel", channel, "channelID", channelID)
@@ -79,8 +129,9 @@ func (s *ContactService) GetOrCreateByChannelID(channel, channelID, name string)
 			Name:          name,
 			ContactStatus: "Leads",
 			Temperature:   "Warm",
-			FirstContact:  time.Now(),
-			LastContact:   time.Now(),
+			FirstContact:  now,
+			LastContact:   now,
+			LastInboundAt: &now,
 			LastAgent:     "AI",
 			LastAgentType: "Bot",
 		}
@@ -91,6 +142,7 @@ func (s *ContactService) GetOrCreateByChannelID(channel, channelID, name string)
 		}
 
 		s.logger.Info("Created new contact", "code", contact.Code, "id", contact.ID)
+		s.fireContactCreated(contact)
 		return contact, nil
 	}
 
@@ -101,7 +153,9 @@ func (s *ContactService) GetOrCreateByChannelID(channel, channelID, name string)
 
 	// Update existing contact
 	s.logger.Info("Found existing contact", "id", contact.ID, "code", contact.Code)
-	contact.LastContact = time.Now()
+	contact.LastContact = now
+	// Opens the WhatsApp customer service window
+	contact.LastInboundAt = &now
 	if name != "" && contact.Name != name {
 		contact.Name = name
 	}
@@ -113,6 +167,20 @@ func (s *ContactService) GetOrCreateByChannelID(channel, channelID, name string)
 	return contact, nil
 }
 
+// fireContactCreated runs contact_created automation rules in the background.
+// Rules act on a copy so they never race with the caller still using the contact,
+// and write back only the columns they change.
+func (s *ContactService) fireContactCreated(contact *models.Contact) {
+	if s.automationService == nil {
+		return
+	}
+	created := *contact
+	go s.automationService.Fire(&AutomationEvent{
+		Trigger: models.TriggerContactCreated,
+		Contact: &created,
+	}, false)
+}
+
 func (s *ContactService) UpdateTemperature(id uint, temperature string) error {
 	contact, err := s.repo.FindByID(id)
 	if err != nil {
@@ -129,8 +197,24 @@ func (s *ContactService) UpdateStatus(id uint, status string) error {
 		return err
 	}
 
+	previous := contact.ContactStatus
 	contact.ContactStatus = status
-	return s.repo.Update(contact)
+	if err := s.repo.Update(contact); err != nil {
+		return err
+	}
+
+	s.recordConversion(contact.ID, previous, status)
+	return nil
+}
+
+// recordConversion credits the last broadcast when a lead becomes a customer
+func (s *ContactService) recordConversion(contactID uint, previous, status string) {
+	if s.broadcastService == nil || previous == status || status != "Contact" {
+		return
+	}
+	if _, err := s.broadcastService.RecordConversion(contactID, time.Now()); err != nil {
+		s.logger.Warn("Failed to record broadcast conversion", "contact_id", contactID, "error", err)
+	}
 }
 
 // Statistics
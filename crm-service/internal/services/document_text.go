@@ -0,0 +1,499 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Document formats accepted for knowledge ingestion
+const (
+	DocumentText     = "text"
+	DocumentMarkdown = "markdown"
+	DocumentHTML     = "html"
+	DocumentPDF      = "pdf"
+)
+
+// detectDocumentType resolves the document format from the requested type, file name or content
+func detectDocumentType(requested, filename string, data []byte) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(requested)) {
+	case "text", "txt", "plain", "text/plain":
+		return DocumentText, nil
+	case "markdown", "md", "text/markdown":
+		return DocumentMarkdown, nil
+	case "html", "htm", "text/html":
+		return DocumentHTML, nil
+	case "pdf", "application/pdf":
+		return DocumentPDF, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported content type: %s", requested)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".text":
+		return DocumentText, nil
+	case ".md", ".markdown":
+		return DocumentMarkdown, nil
+	case ".html", ".htm":
+		return DocumentHTML, nil
+	case ".pdf":
+		return DocumentPDF, nil
+	}
+
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return DocumentPDF, nil
+	}
+	mime := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(mime, "text/html"):
+		return DocumentHTML, nil
+	case strings.HasPrefix(mime, "text/plain"):
+		return DocumentText, nil
+	}
+	return "", fmt.Errorf("unsupported document: %s", mime)
+}
+
+// extractDocumentText returns the plain text of a document. Compressed content may expand
+// to at most maxSize bytes.
+func extractDocumentText(docType string, data []byte, maxSize int) (string, error) {
+	var text string
+	switch docType {
+	case DocumentText:
+		text = string(data)
+	case DocumentMarkdown:
+		text = markdownToText(string(data))
+	case DocumentHTML:
+		text = htmlToText(string(data))
+	case DocumentPDF:
+		extracted, err := pdfToText(data, maxSize)
+		if err != nil {
+			return "", err
+		}
+		text = extracted
+	default:
+		return "", fmt.Errorf("unsupported content type: %s", docType)
+	}
+
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "")
+	}
+	return normalizeDocumentText(text), nil
+}
+
+// normalizeDocumentText trims lines and collapses runs of blank lines into one paragraph break
+func normalizeDocumentText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var out []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// ==================== MARKDOWN ====================
+
+var (
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile(`(\*\*|__|~~|\x60)`)
+	markdownHeading  = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	markdownQuote    = regexp.MustCompile(`(?m)^\s*>\s?`)
+	markdownRule     = regexp.MustCompile(`(?m)^\s*([-*_]\s*){3,}$`)
+	markdownFence    = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+)
+
+// markdownToText drops Markdown syntax but keeps headings and list items on their own lines
+func markdownToText(source string) string {
+	text := markdownFence.ReplaceAllString(source, "")
+	text = markdownImage.ReplaceAllString(text, "$1")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownHeading.ReplaceAllString(text, "")
+	text = markdownQuote.ReplaceAllString(text, "")
+	text = markdownRule.ReplaceAllString(text, "")
+	return markdownEmphasis.ReplaceAllString(text, "")
+}
+
+// ==================== HTML ====================
+
+var (
+	htmlHidden  = regexp.MustCompile(`(?is)<(script|style|noscript|template|svg|head)\b.*?</(script|style|noscript|template|svg|head)\s*>`)
+	htmlBlock   = regexp.MustCompile(`(?i)</?(p|div|br|hr|li|ul|ol|tr|table|section|article|header|footer|h[1-6]|blockquote|pre)\b[^>]*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// htmlToText strips tags, scripts and styles and turns block elements into line breaks
+func htmlToText(source string) string {
+	text := htmlComment.ReplaceAllString(source, "")
+	text = htmlHidden.ReplaceAllString(text, "")
+	text = htmlBlock.ReplaceAllString(text, "\n")
+	text = htmlTag.ReplaceAllString(text, "")
+	return html.UnescapeString(text)
+}
+
+// ==================== PDF ====================
+
+var (
+	pdfStream = regexp.MustCompile(`(?s)stream\r?\n`)
+	// Type0 fonts draw CIDs that only the font's ToUnicode CMap maps back to characters
+	pdfCIDFont = regexp.MustCompile(`/Subtype\s*/Type0\b|/Identity-[HV]\b`)
+)
+
+var (
+	errPDFTooLarge = errors.New("PDF stream exceeds the extraction limit")
+	errPDFCIDFont  = errors.New("PDF uses CID-keyed (Type0) fonts, which cannot be extracted; upload it as text or HTML instead")
+)
+
+// pdfToText pulls the text drawn by the content streams of a PDF. Only fonts with a
+// single-byte encoding are understood: PDFs using CID-keyed (Type0) fonts, which many
+// word processors embed, are rejected, and scanned pages yield no text. Decompressed
+// streams may add up to at most maxSize bytes.
+func pdfToText(data []byte, maxSize int) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF document")
+	}
+	if pdfCIDFont.Match(data) {
+		return "", errPDFCIDFont
+	}
+
+	var out strings.Builder
+	budget := maxSize
+	next := 0
+	for _, loc := range pdfStream.FindAllIndex(data, -1) {
+		if loc[0] < next {
+			continue // The "stream" of an endstream keyword
+		}
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		next = start + end + len("endstream")
+
+		dict := data[:loc[0]]
+		if i := bytes.LastIndex(dict, []byte("obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		content := data[start : start+end]
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			decoded, err := inflatePDFStream(content, budget)
+			if errors.Is(err, errPDFTooLarge) {
+				return "", fmt.Errorf("PDF expands to more than %d bytes when decompressed", maxSize)
+			}
+			if err != nil && len(decoded) == 0 {
+				continue
+			}
+			budget -= len(decoded)
+			content = decoded
+
+			// Font dictionaries may sit in compressed object streams
+			if pdfCIDFont.Match(content) {
+				return "", errPDFCIDFont
+			}
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue // Images and other encodings carry no text
+		}
+
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+		out.WriteString(pdfContentText(content))
+		out.WriteString("\n\n")
+	}
+
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return "", fmt.Errorf("no extractable text in PDF (scanned or unsupported encoding)")
+	}
+	if !pdfTextReadable(text) {
+		return "", fmt.Errorf("PDF text uses a font encoding that cannot be decoded")
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text), nil
+}
+
+// inflatePDFStream decompresses a FlateDecode stream, giving up once it grows past limit bytes.
+// A truncated stream returns what could be read along with the error.
+func inflatePDFStream(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(decoded) > limit {
+		return nil, errPDFTooLarge
+	}
+	return decoded, err
+}
+
+// pdfTextReadable reports whether extracted text reads as characters rather than glyph IDs
+// decoded as Latin-1, which show up as control characters and stray symbols
+func pdfTextReadable(text string) bool {
+	total, odd := 0, 0
+	for _, r := range text {
+		if r == '\n' || r == '\t' || r == ' ' {
+			continue
+		}
+		total++
+		if unicode.IsControl(r) || (r > 0x7e && r < 0xc0) {
+			odd++
+		}
+	}
+	return odd*10 < total
+}
+
+// pdfContentText interprets the text operators (Tj, TJ, ', ", Td, T*, ...) of a content stream
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var operands []interface{} // string, float64 or []interface{} for arrays
+	var array []interface{}
+	inArray := false
+
+	push := func(v interface{}) {
+		if inArray {
+			array = append(array, v)
+		} else {
+			operands = append(operands, v)
+		}
+	}
+	lastString := func() string {
+		for i := len(operands) - 1; i >= 0; i-- {
+			if s, ok := operands[i].(string); ok {
+				return s
+			}
+		}
+		return ""
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := pdfLiteralString(content[i:])
+			push(s)
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			s, n := pdfHexString(content[i:])
+			push(s)
+			i += n
+		case c == '[':
+			inArray, array = true, nil
+			i++
+		case c == ']':
+			inArray = false
+			operands = append(operands, array)
+			i++
+		case c == '/':
+			i++
+			for i < len(content) && !isPDFSpace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+		default:
+			j := i
+			for j < len(content) && !isPDFSpace(content[j]) && !isPDFDelimiter(content[j]) {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			token := string(content[i:j])
+			i = j
+
+			if number, err := strconv.ParseFloat(token, 64); err == nil {
+				push(number)
+				continue
+			}
+
+			switch token {
+			case "Tj":
+				out.WriteString(lastString())
+			case "'", "\"":
+				out.WriteString("\n")
+				out.WriteString(lastString())
+			case "TJ":
+				if len(operands) > 0 {
+					if parts, ok := operands[len(operands)-1].([]interface{}); ok {
+						for _, part := range parts {
+							switch v := part.(type) {
+							case string:
+								out.WriteString(v)
+							case float64:
+								if v < -200 { // A wide negative kerning gap stands for a space
+									out.WriteString(" ")
+								}
+							}
+						}
+					}
+				}
+			case "Td", "TD":
+				if len(operands) >= 2 {
+					if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+						out.WriteString("\n")
+						break
+					}
+				}
+				out.WriteString(" ")
+			case "T*", "Tm":
+				out.WriteString("\n")
+			case "ET":
+				out.WriteString("\n")
+			case "BI":
+				// Skip inline image data
+				if k := bytes.Index(content[i:], []byte("EI")); k >= 0 {
+					i += k + 2
+				} else {
+					i = len(content)
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+	return out.String()
+}
+
+// pdfLiteralString decodes a (...) string and returns it with the number of bytes consumed
+func pdfLiteralString(data []byte) (string, int) {
+	var out []byte
+	depth := 0
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfBytesToText(out), i + 1
+			}
+			out = append(out, c)
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					k := 0
+					for k < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7' {
+						value = value*8 + int(data[i]-'0')
+						i++
+						k++
+					}
+					i--
+					out = append(out, byte(value))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+		i++
+	}
+	return pdfBytesToText(out), len(data)
+}
+
+// pdfHexString decodes a <...> string and returns it with the number of bytes consumed
+func pdfHexString(data []byte) (string, int) {
+	end := bytes.IndexByte(data, '>')
+	if end < 0 {
+		return "", len(data)
+	}
+
+	var digits []byte
+	for _, c := range data[1:end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	raw := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		b, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return "", end + 1
+		}
+		raw = append(raw, byte(b))
+	}
+	return pdfBytesToText(raw), end + 1
+}
+
+// pdfBytesToText reads single-byte encoded text as Latin-1. Control characters are kept
+// so pdfTextReadable can tell glyph IDs of fonts this extractor cannot map from text.
+func pdfBytesToText(raw []byte) string {
+	var out strings.Builder
+	for _, b := range raw {
+		out.WriteRune(rune(b))
+	}
+	return out.String()
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
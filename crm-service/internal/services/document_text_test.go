@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	testSimpleFont = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"
+	testCIDFont    = "<< /Type /Font /Subtype /Type0 /BaseFont /ABCDEF+Calibri /Encoding /Identity-H /ToUnicode 9 0 R >>"
+)
+
+// testPDF assembles a minimal PDF from a font dictionary and content streams
+func testPDF(font string, streams ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&b, "1 0 obj\n%s\nendobj\n", font)
+	for i, stream := range streams {
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", i+2, len(stream), stream)
+	}
+	b.WriteString("%%EOF\n")
+	return []byte(b.String())
+}
+
+// testFlatePDF is testPDF with a single FlateDecode stream
+func testFlatePDF(font string, content []byte) []byte {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(content)
+	w.Close()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	fmt.Fprintf(&b, "1 0 obj\n%s\nendobj\n", font)
+	fmt.Fprintf(&b, "2 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	b.Write(compressed.Bytes())
+	b.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestPDFToText(t *testing.T) {
+	openingHours := "BT /F1 12 Tf 72 720 Td (Jam buka: 09.00) Tj 0 -14 Td (Senin \\(kecuali libur\\)) Tj ET"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{
+			name: "literal strings",
+			data: testPDF(testSimpleFont, openingHours),
+			want: "Jam buka: 09.00\nSenin (kecuali libur)",
+		},
+		{
+			name: "flate compressed stream",
+			data: testFlatePDF(testSimpleFont, []byte(openingHours)),
+			want: "Jam buka: 09.00\nSenin (kecuali libur)",
+		},
+		{
+			name: "kerning gap becomes a space",
+			data: testPDF(testSimpleFont, "BT [(Harga)-300(Rp)20(50.000)] TJ ET"),
+			want: "Harga Rp50.000",
+		},
+		{
+			name: "hex and latin-1 strings",
+			data: testPDF(testSimpleFont, "BT <48616C6F> Tj 0 -14 Td (caf\\351) Tj ET"),
+			want: "Halo\ncafé",
+		},
+		{
+			name: "separate pages",
+			data: testPDF(testSimpleFont, "BT (Halaman satu) Tj ET", "BT (Halaman dua) Tj ET"),
+			want: "Halaman satu\n\n\nHalaman dua",
+		},
+		{
+			name:    "type0 font",
+			data:    testPDF(testCIDFont, "BT <00030012> Tj ET"),
+			wantErr: "CID-keyed",
+		},
+		{
+			name:    "type0 font in a compressed object stream",
+			data:    testFlatePDF(testSimpleFont, []byte("9 0 obj "+testCIDFont+" endobj")),
+			wantErr: "CID-keyed",
+		},
+		{
+			name:    "glyph ids without a readable encoding",
+			data:    testPDF(testSimpleFont, "BT <0003001200450046004C0057> Tj ET"),
+			wantErr: "cannot be decoded",
+		},
+		{
+			name:    "no text",
+			data:    testPDF(testSimpleFont, "q 100 0 0 100 0 0 cm /Im1 Do Q"),
+			wantErr: "no extractable text",
+		},
+		{
+			name:    "not a pdf",
+			data:    []byte("<html></html>"),
+			wantErr: "not a PDF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pdfToText(tt.data, 1<<20)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("pdfToText() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pdfToText() error = %v", err)
+			}
+			if got := normalizeDocumentText(got); got != normalizeDocumentText(tt.want) {
+				t.Errorf("pdfToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFToTextDecompressionLimit(t *testing.T) {
+	// Compresses to about a kilobyte but inflates to 4 MB
+	bomb := testFlatePDF(testSimpleFont, bytes.Repeat([]byte{' '}, 4<<20))
+
+	if _, err := pdfToText(bomb, 1<<20); err == nil || !strings.Contains(err.Error(), "decompressed") {
+		t.Fatalf("pdfToText() error = %v, want the decompression limit", err)
+	}
+
+	// The limit covers all streams together
+	stream := "BT (" + strings.Repeat("a", 600) + ") Tj ET"
+	var b bytes.Buffer
+	for _, part := range [][]byte{testFlatePDF(testSimpleFont, []byte(stream)), testFlatePDF(testSimpleFont, []byte(stream))} {
+		b.Write(part)
+	}
+	if _, err := pdfToText(b.Bytes(), 1000); err == nil || !strings.Contains(err.Error(), "decompressed") {
+		t.Fatalf("pdfToText() error = %v, want the decompression limit across streams", err)
+	}
+	if _, err := pdfToText(b.Bytes(), 2000); err != nil {
+		t.Fatalf("pdfToText() error = %v, want streams within the limit to pass", err)
+	}
+}
+
+func TestExtractDocumentText(t *testing.T) {
+	tests := []struct {
+		name    string
+		docType string
+		data    string
+		want    string
+	}{
+		{
+			name:    "html drops scripts, styles and tags, blocks become paragraphs",
+			docType: DocumentHTML,
+			data: `<!DOCTYPE html><html><head><title>FAQ</title><style>p { color: red }</style></head>
+<body><h1>Pengiriman</h1><!-- internal note --><p>Ongkir gratis &amp; cepat</p>
+<script>track("view")</script><ul><li>Jawa: 1-2 hari</li><li>Luar Jawa: 3&ndash;5 hari</li></ul></body></html>`,
+			want: "Pengiriman\n\nOngkir gratis & cepat\n\nJawa: 1-2 hari\n\nLuar Jawa: 3–5 hari",
+		},
+		{
+			name:    "html line breaks",
+			docType: DocumentHTML,
+			data:    "Jam buka<br>Senin&nbsp;-&nbsp;Jumat<br/>09.00",
+			want:    "Jam buka\nSenin - Jumat\n09.00",
+		},
+		{
+			name:    "markdown",
+			docType: DocumentMarkdown,
+			data:    "# Retur\n\n**Maksimal** 7 hari, lihat [syarat](https://example.com/syarat).\n\n```\nkode\n```",
+			want:    "Retur\n\nMaksimal 7 hari, lihat syarat.\n\nkode",
+		},
+		{
+			name:    "text collapses blank lines",
+			docType: DocumentText,
+			data:    "  satu  \r\n\r\n\r\n dua\n",
+			want:    "satu\n\ndua",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractDocumentText(tt.docType, []byte(tt.data), 1<<20)
+			if err != nil {
+				t.Fatalf("extractDocumentText() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("extractDocumentText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bytes"
+	"divine-crm/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Embedding providers
+const (
+	EmbeddingOpenAI = "openai"
+	EmbeddingGemini = "gemini"
+	EmbeddingLocal  = "local"
+)
+
+// Embedder turns texts into vectors of a fixed number of dimensions, one request per call
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+	Provider() string
+	Model() string
+	Dimensions() int
+}
+
+// NewEmbedder builds the embedder selected by EMBEDDING_PROVIDER
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	ec := cfg.Embedding
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	switch strings.ToLower(ec.Provider) {
+	case "", EmbeddingOpenAI:
+		e := &openAIEmbedder{
+			provider:   EmbeddingOpenAI,
+			endpoint:   firstNonEmpty(ec.Endpoint, "https://api.openai.com/v1/embeddings"),
+			apiKey:     firstNonEmpty(ec.APIKey, cfg.OpenAI.APIKey),
+			model:      firstNonEmpty(ec.Model, "text-embedding-ada-002"),
+			dimensions: ec.Dimensions,
+			client:     client,
+		}
+		// Only the text-embedding-3 models can shorten their output
+		e.sendDimensions = strings.HasPrefix(e.model, "text-embedding-3") && ec.Dimensions > 0
+		if e.dimensions == 0 {
+			e.dimensions = openAIDimensions(e.model)
+		}
+		return e, nil
+
+	case EmbeddingLocal:
+		if ec.Endpoint == "" || ec.Model == "" {
+			return nil, fmt.Errorf("EMBEDDING_ENDPOINT and EMBEDDING_MODEL are required for the local embedding provider")
+		}
+		if ec.Dimensions <= 0 {
+			return nil, fmt.Errorf("EMBEDDING_DIMENSIONS is required for the local embedding provider")
+		}
+		return &openAIEmbedder{
+			provider:   EmbeddingLocal,
+			endpoint:   ec.Endpoint,
+			apiKey:     ec.APIKey,
+			model:      ec.Model,
+			dimensions: ec.Dimensions,
+			client:     client,
+		}, nil
+
+	case EmbeddingGemini:
+		e := &geminiEmbedder{
+			endpoint:   ec.Endpoint,
+			apiKey:     firstNonEmpty(ec.APIKey, cfg.Gemini.APIKey),
+			model:      firstNonEmpty(ec.Model, "text-embedding-004"),
+			dimensions: ec.Dimensions,
+			client:     client,
+		}
+		if e.endpoint == "" {
+			e.endpoint = "https://generativelanguage.googleapis.com/v1beta/models/" + e.model
+		}
+		if e.dimensions == 0 {
+			e.dimensions = 768
+		}
+		return e, nil
+	}
+
+	return nil, fmt.Errorf("unknown embedding provider: %s", ec.Provider)
+}
+
+func openAIDimensions(model string) int {
+	if model == "text-embedding-3-large" {
+		return 3072
+	}
+	return 1536
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ==================== OPENAI / LOCAL ====================
+
+// openAIEmbedder calls the OpenAI embeddings API or a local server that implements it
+// (Ollama, LM Studio, vLLM, text-embeddings-inference, ...)
+type openAIEmbedder struct {
+	provider       string
+	endpoint       string
+	apiKey         string
+	model          string
+	dimensions     int
+	sendDimensions bool
+	client         *http.Client
+}
+
+func (e *openAIEmbedder) Provider() string { return e.provider }
+func (e *openAIEmbedder) Model() string    { return e.model }
+func (e *openAIEmbedder) Dimensions() int  { return e.dimensions }
+
+func (e *openAIEmbedder) Embed(texts []string) ([][]float32, error) {
+	requestBody := map[string]interface{}{
+		"input": texts,
+		"model": e.model,
+	}
+	if e.sendDimensions {
+		requestBody["dimensions"] = e.dimensions
+	}
+
+	headers := map[string]string{}
+	if e.apiKey != "" {
+		headers["Authorization"] = "Bearer " + e.apiKey
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postJSON(e.client, e.endpoint, headers, requestBody, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding API returned %d embeddings for %d inputs", len(result.Data), len(texts))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding API returned unknown index %d", item.Index)
+		}
+		embedding, err := checkDimensions(item.Embedding, e.dimensions)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[item.Index] = embedding
+	}
+	return embeddings, nil
+}
+
+// ==================== GEMINI ====================
+
+type geminiEmbedder struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	dimensions int
+	client     *http.Client
+}
+
+func (e *geminiEmbedder) Provider() string { return EmbeddingGemini }
+func (e *geminiEmbedder) Model() string    { return e.model }
+func (e *geminiEmbedder) Dimensions() int  { return e.dimensions }
+
+func (e *geminiEmbedder) Embed(texts []string) ([][]float32, error) {
+	requests := make([]map[string]interface{}, len(texts))
+	for i, text := range texts {
+		requests[i] = map[string]interface{}{
+			"model": "models/" + e.model,
+			"content": map[string]interface{}{
+				"parts": []map[string]string{{"text": text}},
+			},
+			"outputDimensionality": e.dimensions,
+		}
+	}
+
+	var result struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	endpoint := e.endpoint + ":batchEmbedContents?key=" + url.QueryEscape(e.apiKey)
+	if err := postJSON(e.client, endpoint, nil, map[string]interface{}{"requests": requests}, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding API returned %d embeddings for %d inputs", len(result.Embeddings), len(texts))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, item := range result.Embeddings {
+		embedding, err := checkDimensions(item.Values, e.dimensions)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// ==================== HELPERS ====================
+
+func postJSON(client *http.Client, endpoint string, headers map[string]string, requestBody, result interface{}) error {
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// checkDimensions rejects vectors that would not fit the configured vector columns
+func checkDimensions(embedding []float32, dimensions int) ([]float32, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("no embedding returned")
+	}
+	if len(embedding) != dimensions {
+		return nil, fmt.Errorf("embedding model returned %d dimensions, expected %d (check EMBEDDING_DIMENSIONS)", len(embedding), dimensions)
+	}
+	return embedding, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"divine-crm/internal/models"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// Stored embeddings unused for EMBEDDING_CACHE_TTL are pruned; a lookup refreshes
+// an entry's last use at most once per embeddingTouchInterval
+const (
+	defaultEmbeddingCacheTTL = 30 * 24 * time.Hour
+	embeddingTouchInterval   = 24 * time.Hour
+)
+
+// embeddingLRU keeps the most recently used embeddings in memory
+type embeddingLRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is most recently used
+	items    map[string]*list.Element
+}
+
+type embeddingLRUItem struct {
+	key   string
+	value []float32
+}
+
+func newEmbeddingLRU(capacity int) *embeddingLRU {
+	return &embeddingLRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *embeddingLRU) Get(key string) ([]float32, bool) {
+	if c.capacity <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*embeddingLRUItem).value, true
+}
+
+func (c *embeddingLRU) Put(key string, value []float32) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		element.Value.(*embeddingLRUItem).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&embeddingLRUItem{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*embeddingLRUItem).key)
+	}
+}
+
+// embeddingModelKey identifies the configured model in cache keys and entries
+func (s *VectorService) embeddingModelKey() string {
+	return fmt.Sprintf("%s/%s/%d", s.embedder.Provider(), s.embedder.Model(), s.embedder.Dimensions())
+}
+
+func (s *VectorService) embeddingCacheKey(text string) string {
+	sum := sha256.Sum256([]byte(s.embeddingModelKey() + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateEmbeddings embeds several texts, looking each one up in the memory cache and
+// the embedding_cache_entries table first. The rest go to the provider in batches.
+func (s *VectorService) GenerateEmbeddings(texts []string) ([]pgvector.Vector, error) {
+	results := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	missing := map[string][]int{} // Cache key -> positions in texts
+
+	for i, text := range texts {
+		keys[i] = s.embeddingCacheKey(text)
+		if embedding, ok := s.cache.Get(keys[i]); ok {
+			results[i] = embedding
+			continue
+		}
+		missing[keys[i]] = append(missing[keys[i]], i)
+	}
+
+	if len(missing) > 0 {
+		hashes := make([]string, 0, len(missing))
+		for key := range missing {
+			hashes = append(hashes, key)
+		}
+
+		stored, err := s.repo.FindCachedEmbeddings(hashes)
+		if err != nil {
+			s.logger.Warn("Failed to read embedding cache", "error", err)
+		}
+		s.touchCachedEmbeddings(stored)
+		for _, entry := range stored {
+			embedding := entry.Embedding.Slice()
+			if len(embedding) != s.embedder.Dimensions() {
+				continue
+			}
+			s.cache.Put(entry.Hash, embedding)
+			for _, i := range missing[entry.Hash] {
+				results[i] = embedding
+			}
+			delete(missing, entry.Hash)
+		}
+	}
+
+	if len(missing) > 0 {
+		if err := s.embedMissing(texts, missing, results); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors := make([]pgvector.Vector, len(results))
+	for i, embedding := range results {
+		vectors[i] = pgvector.NewVector(embedding)
+	}
+	return vectors, nil
+}
+
+// embedMissing calls the provider for texts not found in either cache and stores the results
+func (s *VectorService) embedMissing(texts []string, missing map[string][]int, results [][]float32) error {
+	keys := make([]string, 0, len(missing))
+	inputs := make([]string, 0, len(missing))
+	for key, positions := range missing {
+		keys = append(keys, key)
+		inputs = append(inputs, texts[positions[0]])
+	}
+
+	batchSize := s.config.Embedding.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(inputs)
+	}
+
+	model := s.embeddingModelKey()
+	for start := 0; start < len(inputs); start += batchSize {
+		end := start + batchSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+
+		embeddings, err := s.embedder.Embed(inputs[start:end])
+		if err != nil {
+			return err
+		}
+
+		entries := make([]models.EmbeddingCacheEntry, 0, len(embeddings))
+		for j, embedding := range embeddings {
+			key := keys[start+j]
+			for _, i := range missing[key] {
+				results[i] = embedding
+			}
+			s.cache.Put(key, embedding)
+			entries = append(entries, models.EmbeddingCacheEntry{
+				Hash:       key,
+				Model:      model,
+				Embedding:  pgvector.NewVector(embedding),
+				LastUsedAt: time.Now(),
+			})
+		}
+
+		if err := s.repo.SaveCachedEmbeddings(entries); err != nil {
+			s.logger.Warn("Failed to store embeddings in cache", "error", err)
+		}
+	}
+	return nil
+}
+
+// touchCachedEmbeddings keeps entries that are still being looked up from being pruned
+func (s *VectorService) touchCachedEmbeddings(entries []models.EmbeddingCacheEntry) {
+	now := time.Now()
+	var hashes []string
+	for _, entry := range entries {
+		if now.Sub(entry.LastUsedAt) > embeddingTouchInterval {
+			hashes = append(hashes, entry.Hash)
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	if err := s.repo.TouchCachedEmbeddings(hashes, now, now.Add(-embeddingTouchInterval)); err != nil {
+		s.logger.Warn("Failed to refresh embedding cache entries", "error", err)
+	}
+}
+
+// StartEmbeddingCachePruner periodically drops stored embeddings that have not been used
+// for EMBEDDING_CACHE_TTL, mostly one-off chat messages, so the table does not grow forever
+func (s *VectorService) StartEmbeddingCachePruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.pruneEmbeddingCache()
+	}
+}
+
+func (s *VectorService) pruneEmbeddingCache() {
+	ttl, err := time.ParseDuration(s.config.Embedding.CacheTTL)
+	if err != nil || ttl <= 0 {
+		ttl = defaultEmbeddingCacheTTL
+	}
+
+	deleted, err := s.repo.DeleteCachedEmbeddingsUnusedSince(time.Now().Add(-ttl))
+	if err != nil {
+		s.logger.Error("Failed to prune embedding cache", "error", err)
+		return
+	}
+	if deleted > 0 {
+		s.logger.Info("🧹 Pruned unused cached embeddings", "deleted", deleted, "ttl", ttl.String())
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"fmt"
+	"strings"
+)
+
+// reembedSuffix names the columns filled while re-embedding, e.g. embedding_next
+const reembedSuffix = "_next"
+
+// CheckEmbeddingModel records the embedding model on first start and warns when the
+// stored vectors were made by a different model, since they can't be compared
+func (s *VectorService) CheckEmbeddingModel() {
+	stored, err := s.repo.GetEmbeddingModel()
+	if err != nil {
+		s.logger.Warn("Failed to read embedding model", "error", err)
+		return
+	}
+
+	if stored == nil {
+		if err := s.RecordEmbeddingModel(); err != nil {
+			s.logger.Warn("Failed to record embedding model", "error", err)
+		}
+		return
+	}
+
+	if !s.usesModel(stored) {
+		s.logger.Warn("⚠️ Embedding model changed, run `make reembed` to re-embed stored vectors",
+			"stored", fmt.Sprintf("%s/%s (%d)", stored.Provider, stored.Model, stored.Dimensions),
+			"configured", fmt.Sprintf("%s/%s (%d)", s.embedder.Provider(), s.embedder.Model(), s.embedder.Dimensions()))
+	}
+}
+
+// RecordEmbeddingModel marks the configured model as the one the stored vectors come from
+func (s *VectorService) RecordEmbeddingModel() error {
+	stored, err := s.repo.GetEmbeddingModel()
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		stored = &models.EmbeddingModel{}
+	}
+
+	stored.Provider = s.embedder.Provider()
+	stored.Model = s.embedder.Model()
+	stored.Dimensions = s.embedder.Dimensions()
+	if err := s.repo.SaveEmbeddingModel(stored); err != nil {
+		return err
+	}
+
+	// Cached vectors of earlier models are never looked up again
+	return s.repo.DeleteCachedEmbeddingsExcept(s.embeddingModelKey())
+}
+
+func (s *VectorService) usesModel(stored *models.EmbeddingModel) bool {
+	return stored.Provider == s.embedder.Provider() &&
+		stored.Model == s.embedder.Model() &&
+		stored.Dimensions == s.embedder.Dimensions()
+}
+
+// Reembed recomputes every stored vector with the configured model. The new vectors are
+// written to *_next columns first, so searches keep working until all rows are done and
+// the columns are swapped. An interrupted run resumes where it stopped.
+func (s *VectorService) Reembed(batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	dimensions := s.embedder.Dimensions()
+
+	for _, col := range models.EmbeddingColumns {
+		target := col.Column + reembedSuffix
+		if err := s.repo.AddEmbeddingColumn(col.Table, target, dimensions); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", col.Table, target, err)
+		}
+
+		done, err := s.fillEmbeddingColumn(col, target, batchSize)
+		if err != nil {
+			return err
+		}
+		s.logger.Info("Re-embedded column", "table", col.Table, "column", col.Column, "rows", done)
+	}
+
+	// Catch rows written by the running server in the meantime
+	for _, col := range models.EmbeddingColumns {
+		if _, err := s.fillEmbeddingColumn(col, col.Column+reembedSuffix, batchSize); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.SwapEmbeddingColumns(models.EmbeddingColumns, reembedSuffix); err != nil {
+		return fmt.Errorf("failed to swap embedding columns: %w", err)
+	}
+	s.logger.Info("✅ Embedding columns swapped", "model", s.embedder.Model(), "dimensions", dimensions)
+
+	// Dropping the old columns dropped their indexes too
+	if err := s.RebuildVectorIndexes(); err != nil {
+		s.logger.Warn("Vector indexes not rebuilt, restart the server or POST /vectors/indexes/rebuild", "error", err)
+	}
+	return nil
+}
+
+// fillEmbeddingColumn embeds the source text of every row into the target column
+func (s *VectorService) fillEmbeddingColumn(col models.EmbeddingColumn, target string, batchSize int) (int, error) {
+	done := 0
+	var afterID uint
+	for {
+		rows, err := s.repo.FindRowsToEmbed(col, target, afterID, batchSize)
+		if err != nil {
+			return done, fmt.Errorf("failed to read %s: %w", col.Table, err)
+		}
+		if len(rows) == 0 {
+			return done, nil
+		}
+
+		afterID = rows[len(rows)-1].ID
+
+		var batch []repository.EmbeddingRow
+		var texts []string
+		for _, row := range rows {
+			if strings.TrimSpace(row.Text) == "" {
+				continue // Nothing to embed, the column stays empty
+			}
+			batch = append(batch, row)
+			texts = append(texts, row.Text)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		embeddings, err := s.GenerateEmbeddings(texts)
+		if err != nil {
+			return done, fmt.Errorf("failed to embed %s #%d-%d: %w", col.Table, batch[0].ID, afterID, err)
+		}
+		for i, row := range batch {
+			if err := s.repo.SetEmbedding(col.Table, target, row.ID, embeddings[i]); err != nil {
+				return done, err
+			}
+			done++
+		}
+		s.logger.Info("Re-embedding", "table", col.Table, "column", col.Column, "rows", done)
+	}
+}
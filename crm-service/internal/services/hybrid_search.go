@@ -0,0 +1,260 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion (the usual value from the RRF paper)
+const rrfK = 60
+
+// fullTextQuery turns a message into a to_tsquery expression matching any of its words,
+// e.g. "Berapa harga F003?" becomes "berapa | harga | f003"
+func fullTextQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := map[string]bool{}
+	var terms []string
+	for _, word := range words {
+		if len([]rune(word)) < 2 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return strings.Join(terms, " | ")
+}
+
+// identifierTerms returns the code-like words of a message, such as F003 or SKU12,
+// that must match exactly and are poorly served by embeddings
+func identifierTerms(text string) []string {
+	var terms []string
+	for _, term := range strings.Split(fullTextQuery(text), " | ") {
+		hasLetter, hasDigit := false, false
+		for _, r := range term {
+			hasLetter = hasLetter || unicode.IsLetter(r)
+			hasDigit = hasDigit || unicode.IsDigit(r)
+		}
+		if hasLetter && hasDigit {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// containsTerm reports whether any of the terms appears as a word in text
+func containsTerm(text string, terms []string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	words := " " + strings.ReplaceAll(fullTextQuery(text), " | ", " ") + " "
+	for _, term := range terms {
+		if strings.Contains(words, " "+term+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// retrievalSource adapts a searchable model to the hybrid ranking pipeline
+type retrievalSource[T any] struct {
+	id            func(*T) uint
+	text          func(*T) string
+	category      func(*T) string
+	similarity    func(*T) float64
+	setScore      func(*T, float64)
+	minSimilarity float64
+}
+
+// hybridRank merges vector and full-text results by reciprocal rank fusion, drops results
+// below the similarity cutoff (unless they contain a code from the query), reranks the
+// best candidates and applies the per-category limits.
+func hybridRank[T any](s *VectorService, query string, source retrievalSource[T], byVector, byText []T, limit int) []T {
+	fused := fuseRanks(source, byVector, byText)
+
+	identifiers := identifierTerms(query)
+	relevant := fused[:0]
+	for i := range fused {
+		item := &fused[i]
+		if source.similarity(item) >= source.minSimilarity || containsTerm(source.text(item), identifiers) {
+			relevant = append(relevant, *item)
+		}
+	}
+
+	candidates := s.candidateCount(limit)
+	if len(relevant) > candidates {
+		relevant = relevant[:candidates] // Reranking is the costly step, give it the best candidates only
+	}
+	relevant = rerankItems(s, query, source, relevant)
+
+	return limitPerCategory(source, relevant, s.config.RAG.CategoryLimits, limit)
+}
+
+// fuseRanks merges ranked result lists by reciprocal rank fusion: an item scores
+// 1/(rrfK+rank) in every list it appears in. Items come back best first.
+func fuseRanks[T any](source retrievalSource[T], lists ...[]T) []T {
+	scores := map[uint]float64{}
+	items := map[uint]T{}
+	var order []uint
+
+	for _, list := range lists {
+		for rank := range list {
+			key := source.id(&list[rank])
+			if _, ok := items[key]; !ok {
+				items[key] = list[rank]
+				order = append(order, key)
+			}
+			scores[key] += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	fused := make([]T, len(order))
+	for i, key := range order {
+		fused[i] = items[key]
+		source.setScore(&fused[i], scores[key])
+	}
+	return fused
+}
+
+// rerankItems reorders items by the configured reranker and scores them with its relevance.
+// Without one, or when it fails, the fused order is kept.
+func rerankItems[T any](s *VectorService, query string, source retrievalSource[T], items []T) []T {
+	if s.reranker == nil || len(items) < 2 {
+		return items
+	}
+
+	documents := make([]string, len(items))
+	for i := range items {
+		documents[i] = source.text(&items[i])
+	}
+
+	scores, err := s.reranker.Rerank(query, documents)
+	if err != nil {
+		s.logger.Warn("Rerank failed, keeping fused order", "error", err)
+		return items
+	}
+
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return scores[indexes[a]] > scores[indexes[b]]
+	})
+
+	reranked := make([]T, len(items))
+	for i, index := range indexes {
+		reranked[i] = items[index]
+		source.setScore(&reranked[i], scores[index])
+	}
+	return reranked
+}
+
+// limitPerCategory keeps at most limit items, and at most the configured number per category
+func limitPerCategory[T any](source retrievalSource[T], items []T, categoryLimits map[string]int, limit int) []T {
+	counts := map[string]int{}
+	var kept []T
+	for i := range items {
+		if len(kept) >= limit {
+			break
+		}
+		category := strings.ToLower(source.category(&items[i]))
+		if max, ok := categoryLimits[category]; ok && counts[category] >= max {
+			continue
+		}
+		counts[category]++
+		kept = append(kept, items[i])
+	}
+	return kept
+}
+
+// candidateCount is how many rows each retriever contributes before fusion
+func (s *VectorService) candidateCount(limit int) int {
+	candidates := s.config.RAG.Candidates
+	if candidates < limit {
+		candidates = limit
+	}
+	return candidates
+}
+
+// hybridKnowledge combines vector similarity and full-text ranking of the knowledge base
+func (s *VectorService) hybridKnowledge(query string, embedding pgvector.Vector, limit int) ([]models.KnowledgeBase, error) {
+	candidates := s.candidateCount(limit)
+
+	byVector, err := s.repo.FindSimilarKnowledge(embedding, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var byText []models.KnowledgeBase
+	if tsQuery := fullTextQuery(query); tsQuery != "" {
+		byText, err = s.repo.SearchKnowledgeFullText(tsQuery, embedding, candidates)
+		if err != nil {
+			s.logger.Warn("Full-text knowledge search failed", "error", err)
+		}
+	}
+
+	// Vector hits keep their full-text rank when both searches found them
+	textRanks := map[uint]float64{}
+	for _, kb := range byText {
+		textRanks[kb.ID] = kb.TextRank
+	}
+	for i := range byVector {
+		byVector[i].TextRank = textRanks[byVector[i].ID]
+	}
+
+	return hybridRank(s, query, retrievalSource[models.KnowledgeBase]{
+		id:            func(kb *models.KnowledgeBase) uint { return kb.ID },
+		text:          func(kb *models.KnowledgeBase) string { return kb.Title + "\n" + kb.Content },
+		category:      func(kb *models.KnowledgeBase) string { return kb.Category },
+		similarity:    func(kb *models.KnowledgeBase) float64 { return kb.Similarity },
+		setScore:      func(kb *models.KnowledgeBase, score float64) { kb.Score = score },
+		minSimilarity: s.config.RAG.MinKnowledgeSimilarity,
+	}, byVector, byText, limit), nil
+}
+
+// hybridFAQ combines vector similarity and full-text ranking of FAQs
+func (s *VectorService) hybridFAQ(query string, embedding pgvector.Vector, limit int) ([]models.FAQEmbedding, error) {
+	candidates := s.candidateCount(limit)
+
+	byVector, err := s.repo.FindSimilarFAQ(embedding, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var byText []models.FAQEmbedding
+	if tsQuery := fullTextQuery(query); tsQuery != "" {
+		byText, err = s.repo.SearchFAQFullText(tsQuery, embedding, candidates)
+		if err != nil {
+			s.logger.Warn("Full-text FAQ search failed", "error", err)
+		}
+	}
+
+	// Vector hits keep their full-text rank when both searches found them
+	textRanks := map[uint]float64{}
+	for _, faq := range byText {
+		textRanks[faq.ID] = faq.TextRank
+	}
+	for i := range byVector {
+		byVector[i].TextRank = textRanks[byVector[i].ID]
+	}
+
+	return hybridRank(s, query, retrievalSource[models.FAQEmbedding]{
+		id:            func(faq *models.FAQEmbedding) uint { return faq.ID },
+		text:          func(faq *models.FAQEmbedding) string { return faq.Question + "\n" + faq.Answer },
+		category:      func(faq *models.FAQEmbedding) string { return faq.Category },
+		similarity:    func(faq *models.FAQEmbedding) float64 { return faq.Similarity },
+		setScore:      func(faq *models.FAQEmbedding, score float64) { faq.Score = score },
+		minSimilarity: s.config.RAG.MinFAQSimilarity,
+	}, byVector, byText, limit), nil
+}
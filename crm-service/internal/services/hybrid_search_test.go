@@ -0,0 +1,142 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+type rankedItem struct {
+	id       uint
+	category string
+	score    float64
+}
+
+var rankedItemSource = retrievalSource[rankedItem]{
+	id:       func(item *rankedItem) uint { return item.id },
+	text:     func(item *rankedItem) string { return "" },
+	category: func(item *rankedItem) string { return item.category },
+	setScore: func(item *rankedItem, score float64) { item.score = score },
+}
+
+func rankedItems(ids ...uint) []rankedItem {
+	items := make([]rankedItem, len(ids))
+	for i, id := range ids {
+		items[i] = rankedItem{id: id}
+	}
+	return items
+}
+
+func rankedIDs(items []rankedItem) []uint {
+	ids := make([]uint, len(items))
+	for i := range items {
+		ids[i] = items[i].id
+	}
+	return ids
+}
+
+func TestFuseRanks(t *testing.T) {
+	tests := []struct {
+		name  string
+		lists [][]rankedItem
+		want  []uint
+	}{
+		{"single list keeps its order", [][]rankedItem{rankedItems(3, 1, 2)}, []uint{3, 1, 2}},
+		{"found by both beats found by one", [][]rankedItem{rankedItems(1, 2, 3), rankedItems(3, 4)}, []uint{3, 1, 2, 4}},
+		{"same ranks tie in first-seen order", [][]rankedItem{rankedItems(1, 2), rankedItems(2, 1)}, []uint{1, 2}},
+		{"full-text only hit is kept", [][]rankedItem{nil, rankedItems(7)}, []uint{7}},
+		{"no results", [][]rankedItem{nil, nil}, []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rankedIDs(fuseRanks(rankedItemSource, tt.lists...)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fuseRanks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuseRanksScores(t *testing.T) {
+	fused := fuseRanks(rankedItemSource, rankedItems(1, 2), rankedItems(2))
+
+	want := map[uint]float64{
+		1: 1.0 / (rrfK + 1),
+		2: 1.0/(rrfK+2) + 1.0/(rrfK+1),
+	}
+	for _, item := range fused {
+		if diff := item.score - want[item.id]; diff > 1e-12 || diff < -1e-12 {
+			t.Errorf("score of %d = %v, want %v", item.id, item.score, want[item.id])
+		}
+	}
+}
+
+func TestFullTextQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Berapa harga F003?", "berapa | harga | f003"},
+		{"harga, HARGA harga!", "harga"},
+		{"a b cd", "cd"},
+		{"?!", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := fullTextQuery(tt.text); got != tt.want {
+				t.Errorf("fullTextQuery(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentifierTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Berapa harga F003?", []string{"f003"}},
+		{"stok SKU12 dan sku-7", []string{"sku12"}},
+		{"ada 2 warna", nil},
+		{"iPhone 15 atau A54", []string{"a54"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := identifierTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("identifierTerms(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitPerCategory(t *testing.T) {
+	items := []rankedItem{
+		{id: 1, category: "Shipping"},
+		{id: 2, category: "shipping"},
+		{id: 3, category: "Pricing"},
+		{id: 4, category: "shipping"},
+		{id: 5, category: ""},
+	}
+
+	tests := []struct {
+		name   string
+		limits map[string]int
+		limit  int
+		want   []uint
+	}{
+		{"no category limits", nil, 3, []uint{1, 2, 3}},
+		{"category cap is case-insensitive", map[string]int{"shipping": 1}, 5, []uint{1, 3, 5}},
+		{"overall limit still applies", map[string]int{"shipping": 2}, 3, []uint{1, 2, 3}},
+		{"zero cap drops the category", map[string]int{"pricing": 0}, 5, []uint{1, 2, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rankedIDs(limitPerCategory(rankedItemSource, items, tt.limits, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("limitPerCategory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
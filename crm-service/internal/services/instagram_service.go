@@ -12,16 +12,18 @@ import (
 )
 
 type InstagramService struct {
-	repo   *repository.PlatformRepository
-	logger *utils.Logger
-	client *http.Client
+	repo    *repository.PlatformRepository
+	limiter *OutboundLimiter
+	logger  *utils.Logger
+	client  *http.Client
 }
 
-func NewInstagramService(repo *repository.PlatformRepository, logger *utils.Logger) *InstagramService {
+func NewInstagramService(repo *repository.PlatformRepository, limiter *OutboundLimiter, logger *utils.Logger) *InstagramService {
 	return &InstagramService{
-		repo:   repo,
-		logger: logger,
-		client: &http.Client{},
+		repo:    repo,
+		limiter: limiter,
+		logger:  logger,
+		client:  &http.Client{},
 	}
 }
 
@@ -46,6 +48,10 @@ func (s *InstagramService) SendMessage(recipientID, message string) error {
 		"message_length", len(message),
 	)
 
+	if err := s.limiter.Wait("Instagram", pageID, recipientID); err != nil {
+		return err
+	}
+
 	// Prepare request body
 	reqBody := map[string]interface{}{
 		"recipient": map[string]string{
@@ -84,6 +90,11 @@ func (s *InstagramService) SendMessage(recipientID, message string) error {
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
+	if rateLimit := detectRateLimit("Instagram", resp, body); rateLimit != nil {
+		s.limiter.Throttled("Instagram", pageID, rateLimit.RetryAfter)
+		return rateLimit
+	}
+
 	// Check status
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		s.logger.Error("Instagram API error",
@@ -93,6 +104,7 @@ func (s *InstagramService) SendMessage(recipientID, message string) error {
 		return fmt.Errorf("Instagram API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
+	s.limiter.Succeeded("Instagram", pageID)
 	s.logger.Info("✅ Instagram message sent successfully",
 		"response", string(body),
 	)
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"crypto/sha256"
+	"divine-crm/internal/models"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits used when KNOWLEDGE_CHUNK_TOKENS / KNOWLEDGE_CHUNK_OVERLAP / KNOWLEDGE_MAX_EXTRACTED_SIZE are not set
+const (
+	defaultChunkTokens      = 500
+	defaultChunkOverlap     = 50
+	defaultMaxExtractedSize = 50 << 20
+)
+
+// DocumentIngestRequest is a document to split into knowledge base chunks
+type DocumentIngestRequest struct {
+	DocumentID  uint   // Replaces this document when set
+	Title       string // Defaults to the file name
+	Source      string // Identifies the document, defaults to the file name; re-ingesting the same source replaces its chunks
+	ContentType string // text, markdown, html or pdf; detected from the file when empty
+	Category    string
+	Tags        string
+	Filename    string
+	Data        []byte
+}
+
+// KnowledgeDocumentDetail is a document with its chunks
+type KnowledgeDocumentDetail struct {
+	Document *models.KnowledgeDocument `json:"document"`
+	Chunks   []models.KnowledgeBase    `json:"chunks"`
+}
+
+// IngestDocument extracts the text of a document, splits it into overlapping chunks and
+// embeds each one. Chunks of an earlier version of the document are replaced.
+func (s *VectorService) IngestDocument(req *DocumentIngestRequest) (*models.KnowledgeDocument, error) {
+	if len(req.Data) == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+
+	docType, err := detectDocumentType(req.ContentType, req.Filename, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	maxExtracted := s.config.Knowledge.MaxExtractedSize
+	if maxExtracted <= 0 {
+		maxExtracted = defaultMaxExtractedSize
+	}
+	text, err := extractDocumentText(docType, req.Data, maxExtracted)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("document has no text")
+	}
+
+	document, err := s.findIngestTarget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = document.Title
+	}
+	if title == "" {
+		title = req.Filename
+	}
+	if title == "" {
+		title = document.Source
+	}
+
+	document.Title = title
+	document.ContentType = docType
+	document.Category = req.Category
+	document.Tags = req.Tags
+	document.Characters = utf8.RuneCountInString(text)
+	sum := sha256.Sum256([]byte(text))
+	document.Checksum = hex.EncodeToString(sum[:])
+
+	chunkTokens, overlap := s.config.Knowledge.ChunkTokens, s.config.Knowledge.ChunkOverlap
+	if chunkTokens <= 0 {
+		chunkTokens = defaultChunkTokens
+	}
+	if overlap < 0 {
+		overlap = defaultChunkOverlap
+	}
+
+	texts := chunkText(text, chunkTokens, overlap)
+	embeddings, err := s.GenerateEmbeddings(texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %d chunks: %w", len(texts), err)
+	}
+
+	chunks := make([]models.KnowledgeBase, len(texts))
+	for i, content := range texts {
+		chunks[i] = models.KnowledgeBase{
+			Title:      title,
+			Content:    content,
+			Category:   req.Category,
+			Tags:       req.Tags,
+			Source:     document.Source,
+			Embedding:  embeddings[i],
+			Active:     true,
+			ChunkIndex: i,
+		}
+	}
+	document.ChunkCount = len(chunks)
+
+	if err := s.repo.SaveDocument(document, chunks); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("📄 Document ingested", "document_id", document.ID, "source", document.Source, "type", docType, "chunks", len(chunks))
+	return document, nil
+}
+
+// findIngestTarget returns the document being replaced, or a new one
+func (s *VectorService) findIngestTarget(req *DocumentIngestRequest) (*models.KnowledgeDocument, error) {
+	if req.DocumentID != 0 {
+		document, err := s.repo.FindDocumentByID(req.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			return nil, fmt.Errorf("document not found")
+		}
+		return document, nil
+	}
+
+	source := strings.TrimSpace(req.Source)
+	if source == "" {
+		source = req.Filename
+	}
+	if source == "" {
+		source = strings.TrimSpace(req.Title)
+	}
+	if source == "" {
+		return nil, fmt.Errorf("title, source or file is required")
+	}
+
+	document, err := s.repo.FindDocumentBySource(source)
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		document = &models.KnowledgeDocument{Source: source}
+	}
+	return document, nil
+}
+
+func (s *VectorService) GetAllDocuments() ([]models.KnowledgeDocument, error) {
+	return s.repo.GetAllDocuments()
+}
+
+func (s *VectorService) GetDocument(id uint) (*KnowledgeDocumentDetail, error) {
+	document, err := s.repo.FindDocumentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, fmt.Errorf("document not found")
+	}
+
+	chunks, err := s.repo.FindDocumentChunks(id)
+	if err != nil {
+		return nil, err
+	}
+	return &KnowledgeDocumentDetail{Document: document, Chunks: chunks}, nil
+}
+
+// DeleteDocument removes a document and all of its chunks
+func (s *VectorService) DeleteDocument(id uint) error {
+	s.logger.Info("Deleting knowledge document", "document_id", id)
+	return s.repo.DeleteDocument(id)
+}
+
+// ==================== CHUNKING ====================
+
+type chunkWord struct {
+	text      string
+	tokens    int
+	paragraph bool // First word of a paragraph
+}
+
+// estimateTokens approximates the tokenizer at one token per four characters of a word
+func estimateTokens(word string) int {
+	n := (utf8.RuneCountInString(word) + 3) / 4
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// chunkText splits text into chunks of at most maxTokens estimated tokens. Each chunk
+// repeats up to overlap tokens from the end of the previous one and, where possible,
+// ends at a sentence or paragraph boundary.
+func chunkText(text string, maxTokens, overlap int) []string {
+	if overlap >= maxTokens {
+		overlap = maxTokens / 4
+	}
+
+	var words []chunkWord
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		for i, word := range strings.Fields(paragraph) {
+			words = append(words, chunkWord{text: word, tokens: estimateTokens(word), paragraph: i == 0})
+		}
+	}
+
+	var chunks []string
+	for start := 0; start < len(words); {
+		end, tokens := start, 0
+		for end < len(words) && (end == start || tokens+words[end].tokens <= maxTokens) {
+			tokens += words[end].tokens
+			end++
+		}
+
+		// Pull the end back to a boundary in the last quarter of the chunk
+		if end < len(words) {
+			remaining := tokens
+			for k := end; k > start+1 && remaining >= maxTokens*3/4; k-- {
+				if words[k].paragraph || endsSentence(words[k-1].text) {
+					end = k
+					break
+				}
+				remaining -= words[k-1].tokens
+			}
+		}
+
+		chunks = append(chunks, joinChunkWords(words[start:end]))
+		if end >= len(words) {
+			break
+		}
+
+		next, repeated := end, 0
+		for next > start+1 && repeated+words[next-1].tokens <= overlap {
+			next--
+			repeated += words[next].tokens
+		}
+		start = next
+	}
+	return chunks
+}
+
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `"')]`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?") || strings.HasSuffix(word, ":")
+}
+
+func joinChunkWords(words []chunkWord) string {
+	var b strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			if word.paragraph {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(word.text)
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func chunkTokens(chunk string) int {
+	tokens := 0
+	for _, word := range strings.Fields(chunk) {
+		tokens += estimateTokens(word)
+	}
+	return tokens
+}
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		overlap   int
+		want      []string
+	}{
+		{"empty", "", 10, 2, nil},
+		{"fits in one chunk", "Halo kak, ada yang bisa dibantu?", 50, 5, []string{"Halo kak, ada yang bisa dibantu?"}},
+		{"keeps paragraphs", "Satu dua.\n\nTiga empat.", 50, 5, []string{"Satu dua.\n\nTiga empat."}},
+		{
+			"ends at a sentence and overlaps",
+			"aa bb cc dd. ee ff gg hh ii jj",
+			5, 1,
+			[]string{"aa bb cc dd.", "dd. ee ff gg hh", "hh ii jj"},
+		},
+		{
+			"ends at a paragraph",
+			"aa bb cc dd\n\nee ff gg hh",
+			5, 0,
+			[]string{"aa bb cc dd", "ee ff gg hh"},
+		},
+		{"oversized word gets its own chunk", "aa " + strings.Repeat("x", 40) + " bb", 5, 0, []string{"aa", strings.Repeat("x", 40), "bb"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkText(tt.text, tt.maxTokens, tt.overlap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunkText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextCoversEveryWord(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 300; i++ {
+		b.WriteString("kata")
+		if i%7 == 6 {
+			b.WriteString(".")
+		}
+		if i%40 == 39 {
+			b.WriteString("\n\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	text := b.String()
+
+	for _, size := range []struct{ maxTokens, overlap int }{{20, 5}, {64, 16}, {10, 10}} {
+		chunks := chunkText(text, size.maxTokens, size.overlap)
+
+		words := 0
+		for i, chunk := range chunks {
+			if tokens := chunkTokens(chunk); tokens > size.maxTokens {
+				t.Errorf("max %d: chunk %d has %d tokens", size.maxTokens, i, tokens)
+			}
+			words += len(strings.Fields(chunk))
+		}
+		if words < len(strings.Fields(text)) {
+			t.Errorf("max %d: chunks hold %d words, text has %d", size.maxTokens, words, len(strings.Fields(text)))
+		}
+
+		last := strings.Fields(chunks[len(chunks)-1])
+		if got := last[len(last)-1]; got != "kata" {
+			t.Errorf("max %d: last chunk ends with %q, want the last word of the text", size.maxTokens, got)
+		}
+	}
+}
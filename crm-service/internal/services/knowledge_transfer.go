@@ -0,0 +1,570 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"divine-crm/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// Bulk transfer formats
+const (
+	TransferJSONL = "jsonl"
+	TransferCSV   = "csv"
+)
+
+// maxImportErrors caps the error lines kept on an import job
+const maxImportErrors = 100
+
+// knowledgeCSVHeader is the column order of CSV exports; imports accept any order
+var knowledgeCSVHeader = []string{
+	"type", "title", "content", "category", "tags", "source", "question", "answer", "active", "embedding_model", "embedding",
+}
+
+// KnowledgeRecord is one knowledge entry or FAQ in an import or export file
+type KnowledgeRecord struct {
+	Type     string `json:"type"` // knowledge or faq; rows with a question default to faq
+	Title    string `json:"title,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Tags     string `json:"tags,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Question string `json:"question,omitempty"`
+	Answer   string `json:"answer,omitempty"`
+	Category string `json:"category,omitempty"`
+	Active   *bool  `json:"active,omitempty"` // Defaults to true
+
+	// Written by exports with vectors and reused on import when the model matches
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	Embedding      []float32 `json:"embedding,omitempty"`
+
+	line int
+}
+
+// ImportProgress counts what an import has done so far
+type ImportProgress struct {
+	Total     int
+	Processed int
+	Imported  int
+	Skipped   int
+	Failed    int
+	Errors    []string
+}
+
+func (p *ImportProgress) fail(line int, err error) {
+	p.Processed++
+	p.Failed++
+	if len(p.Errors) < maxImportErrors {
+		p.Errors = append(p.Errors, fmt.Sprintf("line %d: %v", line, err))
+	}
+}
+
+// DetectTransferFormat picks jsonl or csv from an explicit format or the file extension
+func DetectTransferFormat(format, filename string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	switch strings.ToLower(format) {
+	case TransferJSONL, "ndjson", "json":
+		return TransferJSONL, nil
+	case TransferCSV:
+		return TransferCSV, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, use jsonl or csv", format)
+	}
+}
+
+// ParseKnowledgeRecords reads JSONL or CSV records. Lines that cannot be read are counted
+// as failed in the returned progress and the rest are still parsed.
+func ParseKnowledgeRecords(data []byte, format string) ([]KnowledgeRecord, *ImportProgress, error) {
+	switch format {
+	case TransferJSONL:
+		records, progress := parseJSONLRecords(data)
+		return records, progress, nil
+	case TransferCSV:
+		return parseCSVRecords(data)
+	default:
+		return nil, nil, fmt.Errorf("unsupported format %q, use jsonl or csv", format)
+	}
+}
+
+func parseJSONLRecords(data []byte) ([]KnowledgeRecord, *ImportProgress) {
+	progress := &ImportProgress{}
+	var records []KnowledgeRecord
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // Lines with vectors are long
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record KnowledgeRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			progress.Total++
+			progress.fail(line, fmt.Errorf("invalid JSON: %w", err))
+			continue
+		}
+		record.line = line
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		progress.Total++
+		progress.fail(line+1, err)
+	}
+
+	progress.Total += len(records)
+	return records, progress
+}
+
+func parseCSVRecords(data []byte) ([]KnowledgeRecord, *ImportProgress, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	progress := &ImportProgress{}
+	var records []KnowledgeRecord
+	line := 1
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			progress.Total++
+			progress.fail(line, err)
+			continue
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record := KnowledgeRecord{
+			Type:           get("type"),
+			Title:          get("title"),
+			Content:        get("content"),
+			Category:       get("category"),
+			Tags:           get("tags"),
+			Source:         get("source"),
+			Question:       get("question"),
+			Answer:         get("answer"),
+			EmbeddingModel: get("embedding_model"),
+			line:           line,
+		}
+		if active := get("active"); active != "" {
+			value, err := strconv.ParseBool(active)
+			if err != nil {
+				progress.Total++
+				progress.fail(line, fmt.Errorf("invalid active value %q", active))
+				continue
+			}
+			record.Active = &value
+		}
+		if embedding := get("embedding"); embedding != "" {
+			if err := json.Unmarshal([]byte(embedding), &record.Embedding); err != nil {
+				progress.Total++
+				progress.fail(line, fmt.Errorf("invalid embedding: %w", err))
+				continue
+			}
+		}
+		records = append(records, record)
+	}
+
+	progress.Total += len(records)
+	return records, progress, nil
+}
+
+// normalize fills the record type and checks the required fields
+func (r *KnowledgeRecord) normalize() error {
+	r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+	if r.Type == "" {
+		r.Type = models.EntryTypeKnowledge
+		if r.Question != "" {
+			r.Type = models.EntryTypeFAQ
+		}
+	}
+
+	switch r.Type {
+	case models.EntryTypeKnowledge:
+		if r.Title == "" || r.Content == "" {
+			return errors.New("knowledge needs a title and content")
+		}
+	case models.EntryTypeFAQ:
+		if r.Question == "" || r.Answer == "" {
+			return errors.New("faq needs a question and an answer")
+		}
+	default:
+		return fmt.Errorf("unknown type %q, use knowledge or faq", r.Type)
+	}
+	return nil
+}
+
+// embeddingText is what gets embedded, matching AddKnowledge and AddFAQ
+func (r *KnowledgeRecord) embeddingText() string {
+	if r.Type == models.EntryTypeFAQ {
+		return r.Question
+	}
+	return r.Content
+}
+
+func (r *KnowledgeRecord) active() bool {
+	return r.Active == nil || *r.Active
+}
+
+// dedupeKey identifies the entry a record creates, matching the existing-entry check
+func (r *KnowledgeRecord) dedupeKey() string {
+	if r.Type == models.EntryTypeFAQ {
+		return r.Type + "\x00" + r.Question
+	}
+	return r.Type + "\x00" + r.Title + "\x00" + r.Content
+}
+
+// skipDuplicateRecords drops records repeating an earlier one in the same file, counting them
+// as skipped. Invalid records are kept so the import reports them.
+func skipDuplicateRecords(records []KnowledgeRecord, progress *ImportProgress) []KnowledgeRecord {
+	seen := make(map[string]bool, len(records))
+	unique := records[:0]
+	for _, record := range records {
+		if err := record.normalize(); err == nil {
+			key := record.dedupeKey()
+			if seen[key] {
+				progress.Processed++
+				progress.Skipped++
+				continue
+			}
+			seen[key] = true
+		}
+		unique = append(unique, record)
+	}
+	return unique
+}
+
+// ImportKnowledgeRecords embeds and stores records in throttled batches. Entries already
+// present (same knowledge title and content, or same FAQ question), in the database or
+// earlier in the file, are skipped. report is called after every batch.
+func (s *VectorService) ImportKnowledgeRecords(records []KnowledgeRecord, progress *ImportProgress, report func(*ImportProgress)) {
+	records = skipDuplicateRecords(records, progress)
+
+	batchSize := s.config.Knowledge.ImportBatchSize
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	delay, err := time.ParseDuration(s.config.Knowledge.ImportBatchDelay)
+	if err != nil || delay < 0 {
+		delay = time.Second
+	}
+
+	for start := 0; start < len(records); start += batchSize {
+		if start > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		s.importBatch(records[start:end], progress)
+		if report != nil {
+			report(progress)
+		}
+	}
+}
+
+func (s *VectorService) importBatch(batch []KnowledgeRecord, progress *ImportProgress) {
+	modelKey := s.embeddingModelKey()
+	dimensions := s.embedder.Dimensions()
+
+	var pending []*KnowledgeRecord
+	var texts []string
+	var toEmbed []int // Indexes into pending without a reusable vector
+	for i := range batch {
+		record := &batch[i]
+		if err := record.normalize(); err != nil {
+			progress.fail(record.line, err)
+			continue
+		}
+
+		exists, err := s.recordExists(record)
+		if err != nil {
+			progress.fail(record.line, err)
+			continue
+		}
+		if exists {
+			progress.Processed++
+			progress.Skipped++
+			continue
+		}
+
+		if record.EmbeddingModel != modelKey || len(record.Embedding) != dimensions {
+			record.Embedding = nil
+			toEmbed = append(toEmbed, len(pending))
+			texts = append(texts, record.embeddingText())
+		}
+		pending = append(pending, record)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	if len(texts) > 0 {
+		embeddings, err := s.GenerateEmbeddings(texts)
+		if err != nil {
+			for _, record := range pending {
+				progress.fail(record.line, fmt.Errorf("embedding failed: %w", err))
+			}
+			return
+		}
+		for i, index := range toEmbed {
+			pending[index].Embedding = embeddings[i].Slice()
+		}
+	}
+
+	var knowledge []models.KnowledgeBase
+	var faqs []models.FAQEmbedding
+	for _, record := range pending {
+		embedding := pgvector.NewVector(record.Embedding)
+		if record.Type == models.EntryTypeFAQ {
+			faqs = append(faqs, models.FAQEmbedding{
+				Question:  record.Question,
+				Answer:    record.Answer,
+				Category:  record.Category,
+				Embedding: embedding,
+				Active:    record.active(),
+			})
+			continue
+		}
+		knowledge = append(knowledge, models.KnowledgeBase{
+			Title:     record.Title,
+			Content:   record.Content,
+			Category:  record.Category,
+			Tags:      record.Tags,
+			Source:    record.Source,
+			Embedding: embedding,
+			Active:    record.active(),
+		})
+	}
+
+	if err := s.repo.CreateImported(knowledge, faqs); err != nil {
+		for _, record := range pending {
+			progress.fail(record.line, err)
+		}
+		return
+	}
+	progress.Processed += len(pending)
+	progress.Imported += len(pending)
+}
+
+func (s *VectorService) recordExists(record *KnowledgeRecord) (bool, error) {
+	if record.Type == models.EntryTypeFAQ {
+		return s.repo.FAQExists(record.Question)
+	}
+	return s.repo.KnowledgeExists(record.Title, record.Content)
+}
+
+// StartImport parses an uploaded file and imports it in the background. Follow the
+// returned job with GetImportJob.
+func (s *VectorService) StartImport(filename, format string, data []byte) (*models.KnowledgeImportJob, error) {
+	format, err := DetectTransferFormat(format, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	records, progress, err := ParseKnowledgeRecords(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Total == 0 {
+		return nil, errors.New("the file has no records")
+	}
+
+	job := &models.KnowledgeImportJob{
+		Filename: filename,
+		Format:   format,
+		Status:   "pending",
+	}
+	applyImportProgress(job, progress)
+	if err := s.repo.CreateImportJob(job); err != nil {
+		return nil, err
+	}
+
+	go s.runImportJob(job, records, progress)
+	return job, nil
+}
+
+func (s *VectorService) runImportJob(job *models.KnowledgeImportJob, records []KnowledgeRecord, progress *ImportProgress) {
+	now := time.Now()
+	job.Status = "running"
+	job.StartedAt = &now
+	s.saveImportJob(job)
+	s.logger.Info("📥 Knowledge import started", "job_id", job.ID, "records", progress.Total)
+
+	s.ImportKnowledgeRecords(records, progress, func(p *ImportProgress) {
+		applyImportProgress(job, p)
+		s.saveImportJob(job)
+	})
+
+	finished := time.Now()
+	job.Status = "completed"
+	job.FinishedAt = &finished
+	applyImportProgress(job, progress)
+	s.saveImportJob(job)
+	s.logger.Info("✅ Knowledge import finished", "job_id", job.ID,
+		"imported", job.Imported, "skipped", job.Skipped, "failed", job.Failed)
+}
+
+func (s *VectorService) saveImportJob(job *models.KnowledgeImportJob) {
+	if err := s.repo.SaveImportJob(job); err != nil {
+		s.logger.Warn("Failed to save import job progress", "job_id", job.ID, "error", err)
+	}
+}
+
+func applyImportProgress(job *models.KnowledgeImportJob, progress *ImportProgress) {
+	job.Total = progress.Total
+	job.Processed = progress.Processed
+	job.Imported = progress.Imported
+	job.Skipped = progress.Skipped
+	job.Failed = progress.Failed
+	job.Errors = strings.Join(progress.Errors, "\n")
+}
+
+func (s *VectorService) GetImportJob(id uint) (*models.KnowledgeImportJob, error) {
+	job, err := s.repo.FindImportJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("import job not found")
+	}
+	return job, nil
+}
+
+func (s *VectorService) GetImportJobs() ([]models.KnowledgeImportJob, error) {
+	return s.repo.GetImportJobs(50)
+}
+
+// FailUnfinishedImports marks imports cut off by a restart as failed
+func (s *VectorService) FailUnfinishedImports() {
+	failed, err := s.repo.FailUnfinishedImportJobs()
+	if err != nil {
+		s.logger.Warn("Failed to check unfinished imports", "error", err)
+		return
+	}
+	if failed > 0 {
+		s.logger.Warn("Knowledge imports interrupted by restart, upload them again to finish", "jobs", failed)
+	}
+}
+
+// ExportKnowledge writes every knowledge entry and FAQ as JSONL or CSV. With vectors the
+// embeddings and their model are included, so an import with the same model skips embedding.
+// Chunks of uploaded documents are not exported; upload the documents again instead.
+func (s *VectorService) ExportKnowledge(w io.Writer, format string, withVectors bool) error {
+	knowledge, err := s.repo.GetKnowledgeForExport()
+	if err != nil {
+		return err
+	}
+	faqs, err := s.repo.GetFAQForExport()
+	if err != nil {
+		return err
+	}
+
+	modelKey := s.embeddingModelKey()
+	records := make([]KnowledgeRecord, 0, len(knowledge)+len(faqs))
+	for _, kb := range knowledge {
+		active := kb.Active
+		record := KnowledgeRecord{
+			Type:     models.EntryTypeKnowledge,
+			Title:    kb.Title,
+			Content:  kb.Content,
+			Category: kb.Category,
+			Tags:     kb.Tags,
+			Source:   kb.Source,
+			Active:   &active,
+		}
+		if withVectors {
+			record.EmbeddingModel, record.Embedding = modelKey, kb.Embedding.Slice()
+		}
+		records = append(records, record)
+	}
+	for _, faq := range faqs {
+		active := faq.Active
+		record := KnowledgeRecord{
+			Type:     models.EntryTypeFAQ,
+			Question: faq.Question,
+			Answer:   faq.Answer,
+			Category: faq.Category,
+			Active:   &active,
+		}
+		if withVectors {
+			record.EmbeddingModel, record.Embedding = modelKey, faq.Embedding.Slice()
+		}
+		records = append(records, record)
+	}
+
+	switch format {
+	case TransferJSONL:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TransferCSV:
+		return writeKnowledgeCSV(w, records)
+	default:
+		return fmt.Errorf("unsupported format %q, use jsonl or csv", format)
+	}
+}
+
+func writeKnowledgeCSV(w io.Writer, records []KnowledgeRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(knowledgeCSVHeader); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		embedding := ""
+		if len(record.Embedding) > 0 {
+			data, err := json.Marshal(record.Embedding)
+			if err != nil {
+				return err
+			}
+			embedding = string(data)
+		}
+		err := writer.Write([]string{
+			record.Type, record.Title, record.Content, record.Category, record.Tags, record.Source,
+			record.Question, record.Answer, strconv.FormatBool(record.active()), record.EmbeddingModel, embedding,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSkipDuplicateRecords(t *testing.T) {
+	records := []KnowledgeRecord{
+		{Title: "Ongkir", Content: "Gratis ongkir di atas 100rb", line: 1},
+		{Question: "Jam buka?", Answer: "09.00 - 17.00", line: 2},
+		{Title: "Ongkir", Content: "Gratis ongkir di atas 100rb", line: 3},         // Same knowledge
+		{Type: "faq", Question: "Jam buka?", Answer: "Setiap hari", line: 4},       // Same question, other answer
+		{Title: "Ongkir", Content: "Ongkir flat 10rb untuk luar Jawa", line: 5},    // Same title, other content
+		{Type: "knowledge", Title: "Jam buka?", Content: "09.00 - 17.00", line: 6}, // Title equal to an FAQ question
+		{Title: "", Content: "tanpa judul", line: 7},                               // Invalid, left for the import to report
+		{Title: "", Content: "tanpa judul", line: 8},                               // Invalid duplicates are reported too
+	}
+	progress := &ImportProgress{Total: len(records)}
+
+	got := skipDuplicateRecords(records, progress)
+
+	var lines []int
+	for _, record := range got {
+		lines = append(lines, record.line)
+	}
+	if want := []int{1, 2, 5, 6, 7, 8}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("kept lines %v, want %v", lines, want)
+	}
+	if progress.Skipped != 2 || progress.Processed != 2 {
+		t.Errorf("skipped %d, processed %d, want 2 and 2", progress.Skipped, progress.Processed)
+	}
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrEntryNotFound   = errors.New("entry not found")
+	ErrVersionNotFound = errors.New("version not found")
+	ErrVersionConflict = repository.ErrVersionConflict
+	ErrDocumentChunk   = errors.New("entry is a chunk of an uploaded document; re-upload or delete the document instead")
+)
+
+// KnowledgeUpdate replaces the content of a knowledge entry; Active is kept when omitted
+type KnowledgeUpdate struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	Tags     string `json:"tags"`
+	Source   string `json:"source"`
+	Active   *bool  `json:"active"`
+}
+
+// FAQUpdate replaces the content of an FAQ; Active is kept when omitted
+type FAQUpdate struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Category string `json:"category"`
+	Active   *bool  `json:"active"`
+}
+
+// ProductEmbeddingUpdate replaces the text of a product embedding
+type ProductEmbeddingUpdate struct {
+	Description string `json:"description"`
+	Features    string `json:"features"`
+	UseCases    string `json:"use_cases"`
+}
+
+// productEmbeddingText is the text embedded for a product
+func productEmbeddingText(description, features, useCases string) string {
+	return fmt.Sprintf("%s. %s. %s", description, features, useCases)
+}
+
+// ==================== KNOWLEDGE BASE ====================
+
+// UpdateKnowledge saves new content for an entry, re-embedding it when the content changed.
+// The previous state is kept as a version. Chunks of uploaded documents are managed through
+// their document and cannot be edited one by one.
+func (s *VectorService) UpdateKnowledge(id uint, req *KnowledgeUpdate) (*models.KnowledgeBase, error) {
+	if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Content) == "" {
+		return nil, errors.New("title and content are required")
+	}
+
+	kb, err := s.repo.FindKnowledgeByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if kb == nil {
+		return nil, ErrEntryNotFound
+	}
+	if kb.DocumentID != nil {
+		return nil, ErrDocumentChunk
+	}
+
+	previous := knowledgeVersion(kb, "update")
+	if req.Content != kb.Content {
+		if kb.Embedding, err = s.GenerateEmbedding(req.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	kb.Title = req.Title
+	kb.Content = req.Content
+	kb.Category = req.Category
+	kb.Tags = req.Tags
+	kb.Source = req.Source
+	if req.Active != nil {
+		kb.Active = *req.Active
+	}
+	kb.Version++
+
+	if err := s.repo.SaveVersioned(kb, previous); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Knowledge updated", "id", kb.ID, "version", kb.Version)
+	return kb, nil
+}
+
+// DeleteKnowledge removes an entry; its last state stays available for rollback
+func (s *VectorService) DeleteKnowledge(id uint) error {
+	kb, err := s.repo.FindKnowledgeByID(id)
+	if err != nil {
+		return err
+	}
+	if kb == nil {
+		return ErrEntryNotFound
+	}
+	if kb.DocumentID != nil {
+		return ErrDocumentChunk
+	}
+
+	s.logger.Info("Deleting knowledge", "id", id)
+	return s.repo.DeleteVersioned(kb, knowledgeVersion(kb, "delete"))
+}
+
+// SetKnowledgeCategoryActive activates or deactivates every knowledge entry of a category
+func (s *VectorService) SetKnowledgeCategoryActive(category string, active bool) (int64, error) {
+	if strings.TrimSpace(category) == "" {
+		return 0, errors.New("category is required")
+	}
+	return s.repo.SetKnowledgeActiveByCategory(category, active)
+}
+
+func (s *VectorService) rollbackKnowledge(id uint, snapshot models.KnowledgeSnapshot) (interface{}, error) {
+	kb, err := s.repo.FindKnowledgeByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *models.KnowledgeVersion
+	if kb == nil {
+		// Deleted entries come back under their old ID, after their last version
+		latest, err := s.repo.LatestVersion(models.EntryTypeKnowledge, id)
+		if err != nil {
+			return nil, err
+		}
+		kb = &models.KnowledgeBase{ID: id, Version: latest}
+	} else if kb.DocumentID != nil {
+		return nil, ErrDocumentChunk
+	} else {
+		previous = knowledgeVersion(kb, "rollback")
+	}
+
+	if kb.Embedding, err = s.GenerateEmbedding(snapshot.Content); err != nil {
+		return nil, err
+	}
+	kb.Title = snapshot.Title
+	kb.Content = snapshot.Content
+	kb.Category = snapshot.Category
+	kb.Tags = snapshot.Tags
+	kb.Source = snapshot.Source
+	kb.Active = snapshot.Active
+	kb.Version++
+
+	return kb, s.repo.SaveVersioned(kb, previous)
+}
+
+func knowledgeVersion(kb *models.KnowledgeBase, action string) *models.KnowledgeVersion {
+	return &models.KnowledgeVersion{
+		EntryType: models.EntryTypeKnowledge,
+		EntryID:   kb.ID,
+		Version:   kb.Version,
+		Action:    action,
+		Snapshot: models.KnowledgeSnapshot{
+			Title:    kb.Title,
+			Content:  kb.Content,
+			Category: kb.Category,
+			Tags:     kb.Tags,
+			Source:   kb.Source,
+			Active:   kb.Active,
+		},
+	}
+}
+
+// ==================== FAQ ====================
+
+// UpdateFAQ saves new content for an FAQ, re-embedding it when the question changed.
+// The previous state is kept as a version.
+func (s *VectorService) UpdateFAQ(id uint, req *FAQUpdate) (*models.FAQEmbedding, error) {
+	if strings.TrimSpace(req.Question) == "" || strings.TrimSpace(req.Answer) == "" {
+		return nil, errors.New("question and answer are required")
+	}
+
+	faq, err := s.repo.FindFAQByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if faq == nil {
+		return nil, ErrEntryNotFound
+	}
+
+	previous := faqVersion(faq, "update")
+	if req.Question != faq.Question {
+		if faq.Embedding, err = s.GenerateEmbedding(req.Question); err != nil {
+			return nil, err
+		}
+	}
+
+	faq.Question = req.Question
+	faq.Answer = req.Answer
+	faq.Category = req.Category
+	if req.Active != nil {
+		faq.Active = *req.Active
+	}
+	faq.Version++
+
+	if err := s.repo.SaveVersioned(faq, previous); err != nil {
+		return nil, err
+	}
+	s.logger.Info("FAQ updated", "id", faq.ID, "version", faq.Version)
+	return faq, nil
+}
+
+// DeleteFAQ removes an FAQ; its last state stays available for rollback
+func (s *VectorService) DeleteFAQ(id uint) error {
+	faq, err := s.repo.FindFAQByID(id)
+	if err != nil {
+		return err
+	}
+	if faq == nil {
+		return ErrEntryNotFound
+	}
+
+	s.logger.Info("Deleting FAQ", "id", id)
+	return s.repo.DeleteVersioned(faq, faqVersion(faq, "delete"))
+}
+
+// SetFAQCategoryActive activates or deactivates every FAQ of a category
+func (s *VectorService) SetFAQCategoryActive(category string, active bool) (int64, error) {
+	if strings.TrimSpace(category) == "" {
+		return 0, errors.New("category is required")
+	}
+	return s.repo.SetFAQActiveByCategory(category, active)
+}
+
+func (s *VectorService) rollbackFAQ(id uint, snapshot models.KnowledgeSnapshot) (interface{}, error) {
+	faq, err := s.repo.FindFAQByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *models.KnowledgeVersion
+	if faq == nil {
+		latest, err := s.repo.LatestVersion(models.EntryTypeFAQ, id)
+		if err != nil {
+			return nil, err
+		}
+		faq = &models.FAQEmbedding{ID: id, Version: latest}
+	} else {
+		previous = faqVersion(faq, "rollback")
+	}
+
+	if faq.Embedding, err = s.GenerateEmbedding(snapshot.Question); err != nil {
+		return nil, err
+	}
+	faq.Question = snapshot.Question
+	faq.Answer = snapshot.Answer
+	faq.Category = snapshot.Category
+	faq.Active = snapshot.Active
+	faq.Version++
+
+	return faq, s.repo.SaveVersioned(faq, previous)
+}
+
+func faqVersion(faq *models.FAQEmbedding, action string) *models.KnowledgeVersion {
+	return &models.KnowledgeVersion{
+		EntryType: models.EntryTypeFAQ,
+		EntryID:   faq.ID,
+		Version:   faq.Version,
+		Action:    action,
+		Snapshot: models.KnowledgeSnapshot{
+			Question: faq.Question,
+			Answer:   faq.Answer,
+			Category: faq.Category,
+			Active:   faq.Active,
+		},
+	}
+}
+
+// ==================== PRODUCT EMBEDDINGS ====================
+
+// UpdateProductEmbedding saves new text for a product embedding, re-embedding it when
+// the text changed. The previous state is kept as a version. Edited text is hand-curated,
+// so catalog syncs no longer touch the embedding.
+func (s *VectorService) UpdateProductEmbedding(id uint, req *ProductEmbeddingUpdate) (*models.ProductEmbedding, error) {
+	pe, err := s.repo.FindProductEmbeddingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if pe == nil {
+		return nil, ErrEntryNotFound
+	}
+	return s.updateProductEmbedding(pe, req, false)
+}
+
+func (s *VectorService) updateProductEmbedding(pe *models.ProductEmbedding, req *ProductEmbeddingUpdate, managed bool) (*models.ProductEmbedding, error) {
+	var err error
+	previous := productEmbeddingVersion(pe, "update")
+	text := productEmbeddingText(req.Description, req.Features, req.UseCases)
+	if text != productEmbeddingText(pe.Description, pe.Features, pe.UseCases) {
+		if pe.Embedding, err = s.GenerateEmbedding(text); err != nil {
+			return nil, err
+		}
+	}
+
+	pe.Description = req.Description
+	pe.Features = req.Features
+	pe.UseCases = req.UseCases
+	pe.Managed = managed
+	pe.Version++
+
+	if err := s.repo.SaveVersioned(pe, previous); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Product embedding updated", "id", pe.ID, "product_id", pe.ProductID, "version", pe.Version)
+	return pe, nil
+}
+
+// DeleteProductEmbedding removes a product embedding; its last state stays available for rollback
+func (s *VectorService) DeleteProductEmbedding(id uint) error {
+	pe, err := s.repo.FindProductEmbeddingByID(id)
+	if err != nil {
+		return err
+	}
+	if pe == nil {
+		return ErrEntryNotFound
+	}
+
+	s.logger.Info("Deleting product embedding", "id", id, "product_id", pe.ProductID)
+	return s.repo.DeleteVersioned(pe, productEmbeddingVersion(pe, "delete"))
+}
+
+func (s *VectorService) rollbackProductEmbedding(id uint, snapshot models.KnowledgeSnapshot) (interface{}, error) {
+	pe, err := s.repo.FindProductEmbeddingByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *models.KnowledgeVersion
+	if pe == nil {
+		latest, err := s.repo.LatestVersion(models.EntryTypeProduct, id)
+		if err != nil {
+			return nil, err
+		}
+		pe = &models.ProductEmbedding{ID: id, Version: latest}
+	} else {
+		previous = productEmbeddingVersion(pe, "rollback")
+	}
+
+	pe.Embedding, err = s.GenerateEmbedding(productEmbeddingText(snapshot.Description, snapshot.Features, snapshot.UseCases))
+	if err != nil {
+		return nil, err
+	}
+	pe.ProductID = snapshot.ProductID
+	pe.Description = snapshot.Description
+	pe.Features = snapshot.Features
+	pe.UseCases = snapshot.UseCases
+	pe.Managed = snapshot.Managed
+	pe.Version++
+
+	return pe, s.repo.SaveVersioned(pe, previous)
+}
+
+func productEmbeddingVersion(pe *models.ProductEmbedding, action string) *models.KnowledgeVersion {
+	return &models.KnowledgeVersion{
+		EntryType: models.EntryTypeProduct,
+		EntryID:   pe.ID,
+		Version:   pe.Version,
+		Action:    action,
+		Snapshot: models.KnowledgeSnapshot{
+			ProductID:   pe.ProductID,
+			Description: pe.Description,
+			Features:    pe.Features,
+			UseCases:    pe.UseCases,
+			Managed:     pe.Managed,
+			Active:      true,
+		},
+	}
+}
+
+// ==================== VERSIONS ====================
+
+// GetVersions lists the earlier states of an entry, newest first
+func (s *VectorService) GetVersions(entryType string, id uint) ([]models.KnowledgeVersion, error) {
+	return s.repo.FindVersions(entryType, id)
+}
+
+// Rollback restores an entry to an earlier version and re-embeds it. The state it replaces
+// becomes a version too, so a rollback can itself be undone. Deleted entries are recreated.
+func (s *VectorService) Rollback(entryType string, id uint, version int) (interface{}, error) {
+	target, err := s.repo.FindVersion(entryType, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrVersionNotFound
+	}
+
+	s.logger.Info("Rolling back entry", "type", entryType, "id", id, "version", version)
+	switch entryType {
+	case models.EntryTypeKnowledge:
+		return s.rollbackKnowledge(id, target.Snapshot)
+	case models.EntryTypeFAQ:
+		return s.rollbackFAQ(id, target.Snapshot)
+	case models.EntryTypeProduct:
+		return s.rollbackProductEmbedding(id, target.Snapshot)
+	default:
+		return nil, fmt.Errorf("unknown entry type %q", entryType)
+	}
+}
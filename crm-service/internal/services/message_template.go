@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"divine-crm/internal/models"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// Message templates use Go text/template syntax over a contact:
+//
+//	Halo {{.Name | default "Kak"}}!
+//	{{if eq .Temperature "Hot"}}Promo khusus untuk Anda{{else}}Cek katalog kami{{end}}
+//	Harga: {{rupiah .Attr.price}}, berlaku s/d {{date .Attr.expires "02 Jan 2006"}}
+//
+// Legacy single-brace placeholders such as {name} and {code} are still accepted;
+// unknown ones read from the contact's custom attributes.
+
+var legacyPlaceholderPattern = regexp.MustCompile(`\{\{.*?\}\}|\{([a-z_]+)\}`)
+
+// legacyPlaceholders maps single-brace placeholders to template fields
+var legacyPlaceholders = map[string]string{
+	"name":            ".Name",
+	"code":            ".Code",
+	"channel":         ".Channel",
+	"channel_id":      ".ChannelID",
+	"contact_status":  ".ContactStatus",
+	"temperature":     ".Temperature",
+	"first_contact":   ".FirstContact",
+	"last_contact":    ".LastContact",
+	"last_agent":      ".LastAgent",
+	"last_agent_type": ".LastAgentType",
+}
+
+var indonesianMonths = []string{
+	"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember",
+}
+
+// MessageTemplateData is the value templates are rendered against
+type MessageTemplateData struct {
+	ID            uint
+	Name          string
+	Code          string
+	Channel       string
+	ChannelID     string
+	ContactStatus string
+	Temperature   string
+	FirstContact  time.Time
+	LastContact   time.Time
+	LastAgent     string
+	LastAgentType string
+	Notes         string
+	Attr          map[string]string
+	Now           time.Time
+}
+
+// NewMessageTemplateData exposes a contact to templates
+func NewMessageTemplateData(contact *models.Contact) *MessageTemplateData {
+	attrs := make(map[string]string, len(contact.Attributes))
+	for key, value := range contact.Attributes {
+		attrs[key] = value
+	}
+
+	return &MessageTemplateData{
+		ID:            contact.ID,
+		Name:          contact.Name,
+		Code:          contact.Code,
+		Channel:       contact.Channel,
+		ChannelID:     contact.ChannelID,
+		ContactStatus: contact.ContactStatus,
+		Temperature:   contact.Temperature,
+		FirstContact:  contact.FirstContact,
+		LastContact:   contact.LastContact,
+		LastAgent:     contact.LastAgent,
+		LastAgentType: contact.LastAgentType,
+		Notes:         contact.Notes,
+		Attr:          attrs,
+		Now:           time.Now(),
+	}
+}
+
+// ParseMessageTemplate compiles a template and checks it renders against a sample contact,
+// so unknown fields and bad function arguments are caught when the template is saved
+func ParseMessageTemplate(content string) (*template.Template, error) {
+	tmpl, err := template.New("message").
+		Funcs(messageTemplateFuncs).
+		Option("missingkey=zero").
+		Parse(convertLegacyPlaceholders(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	sample := NewMessageTemplateData(&models.Contact{
+		Name:         "Budi",
+		Code:         "CTC-0001",
+		Channel:      "WhatsApp",
+		FirstContact: time.Now(),
+		LastContact:  time.Now(),
+	})
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// RenderMessageTemplate executes a parsed template for one contact
+func RenderMessageTemplate(tmpl *template.Template, contact *models.Contact) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, NewMessageTemplateData(contact)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// convertLegacyPlaceholders rewrites {name} style placeholders to template actions
+func convertLegacyPlaceholders(content string) string {
+	return legacyPlaceholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		if strings.HasPrefix(match, "{{") {
+			return match
+		}
+		key := match[1 : len(match)-1]
+		if field, ok := legacyPlaceholders[key]; ok {
+			return "{{" + field + "}}"
+		}
+		return fmt.Sprintf("{{index .Attr %q}}", key)
+	})
+}
+
+var messageTemplateFuncs = template.FuncMap{
+	"default": templateDefault,
+	"rupiah":  templateRupiah,
+	"date":    templateDate,
+	"tanggal": templateTanggal,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"title":   templateTitle,
+	"trim":    strings.TrimSpace,
+}
+
+// templateDefault returns fallback when value is empty: {{.Name | default "Kak"}}
+func templateDefault(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		if strings.TrimSpace(v.String()) == "" {
+			return fallback
+		}
+	case reflect.Map, reflect.Slice:
+		if v.Len() == 0 {
+			return fallback
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return fallback
+		}
+	}
+	if t, ok := value.(time.Time); ok && t.IsZero() {
+		return fallback
+	}
+	return value
+}
+
+// templateRupiah formats a number (or numeric string) as Rupiah: Rp 1.500.000
+func templateRupiah(value interface{}) (string, error) {
+	var amount float64
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case int:
+		amount = float64(v)
+	case int64:
+		amount = float64(v)
+	case uint:
+		amount = float64(v)
+	case float32:
+		amount = float64(v)
+	case float64:
+		amount = v
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return "", nil
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", fmt.Errorf("rupiah: %q is not a number", v)
+		}
+		amount = parsed
+	default:
+		return "", fmt.Errorf("rupiah: unsupported value %T", value)
+	}
+	return formatRupiah(amount), nil
+}
+
+func formatRupiah(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatFloat(math.Round(amount), 'f', 0, 64)
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + "Rp " + grouped.String()
+}
+
+// templateDate formats a time (or YYYY-MM-DD / RFC3339 string) with a Go layout
+func templateDate(value interface{}, layout string) (string, error) {
+	t, ok, err := templateTime(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// templateTanggal formats a date in Indonesian: 17 Agustus 2026
+func templateTanggal(value interface{}) (string, error) {
+	t, ok, err := templateTime(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), indonesianMonths[t.Month()-1], t.Year()), nil
+}
+
+func templateTime(value interface{}) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return v, !v.IsZero(), nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false, nil
+		}
+		return *v, !v.IsZero(), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return time.Time{}, false, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, true, nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, true, nil
+		}
+		return time.Time{}, false, fmt.Errorf("date: %q is not a date", v)
+	}
+	return time.Time{}, false, fmt.Errorf("date: unsupported value %T", value)
+}
+
+// templateTitle capitalizes the first letter of each word; it works on runes so
+// names starting with a multi-byte letter such as "élodie" stay valid UTF-8
+func templateTitle(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
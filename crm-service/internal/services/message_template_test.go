@@ -0,0 +1,47 @@
+package services
+
+import "testing"
+
+func TestTemplateTitle(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"budi santoso", "Budi Santoso"},
+		{"  SITI   aminah ", "Siti Aminah"},
+		{"élodie", "Élodie"},
+		{"ünal öz", "Ünal Öz"},
+		{"李 雷", "李 雷"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := templateTitle(tt.in); got != tt.want {
+				t.Errorf("templateTitle(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "Rp 0"},
+		{999, "Rp 999"},
+		{1000, "Rp 1.000"},
+		{1500000, "Rp 1.500.000"},
+		{1234567.6, "Rp 1.234.568"},
+		{-25000, "-Rp 25.000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatRupiah(tt.amount); got != tt.want {
+				t.Errorf("formatRupiah(%v) = %q, want %q", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches @username tokens inside a note
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]+)`)
+
+type NoteService struct {
+	repo      *repository.NoteRepository
+	chatRepo  *repository.ChatRepository
+	agentRepo *repository.HumanAgentRepository
+	logger    *utils.Logger
+}
+
+func NewNoteService(
+	repo *repository.NoteRepository,
+	chatRepo *repository.ChatRepository,
+	agentRepo *repository.HumanAgentRepository,
+	logger *utils.Logger,
+) *NoteService {
+	return &NoteService{
+		repo:      repo,
+		chatRepo:  chatRepo,
+		agentRepo: agentRepo,
+		logger:    logger,
+	}
+}
+
+// GetByChatID returns all internal notes on a conversation
+func (s *NoteService) GetByChatID(chatID uint) ([]models.ChatNote, error) {
+	return s.repo.FindByChatID(chatID)
+}
+
+// Create adds an internal note to a conversation and notifies mentioned agents
+func (s *NoteService) Create(chatID, authorID uint, content string) (*models.ChatNote, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("note content is required")
+	}
+
+	chat, err := s.chatRepo.FindByID(chatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	note := &models.ChatNote{
+		ChatMessageID: chat.ID,
+		ContactID:     chat.ContactID,
+		AuthorID:      authorID,
+		Content:       content,
+	}
+
+	if author, err := s.agentRepo.FindByID(authorID); err == nil {
+		note.AuthorName = author.Username
+	}
+
+	agents, err := s.agentRepo.FindByUsernames(ParseMentions(content))
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		if agent.ID == authorID {
+			continue
+		}
+		note.Mentions = append(note.Mentions, models.NoteMention{
+			AgentID:       agent.ID,
+			ChatMessageID: chat.ID,
+			MentionedBy:   note.AuthorName,
+		})
+	}
+
+	s.logger.Info("Creating internal note", "chat_id", chatID, "author_id", authorID, "mentions", len(note.Mentions))
+	if err := s.repo.Create(note); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// Delete removes a note. Only the author can delete their own note.
+func (s *NoteService) Delete(chatID, noteID, agentID uint) error {
+	note, err := s.repo.FindByID(noteID)
+	if err != nil || note.ChatMessageID != chatID {
+		return errors.New("note not found")
+	}
+	if note.AuthorID != agentID {
+		return errors.New("only the author can delete this note")
+	}
+
+	s.logger.Info("Deleting internal note", "id", noteID)
+	return s.repo.Delete(noteID)
+}
+
+// ==================== MENTION NOTIFICATIONS ====================
+
+// GetMentions returns the mention feed for an agent
+func (s *NoteService) GetMentions(agentID uint, unreadOnly bool) ([]models.NoteMention, int64, error) {
+	mentions, err := s.repo.FindMentionsByAgent(agentID, unreadOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := s.repo.CountUnreadMentions(agentID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return mentions, unread, nil
+}
+
+func (s *NoteService) MarkMentionRead(id, agentID uint) error {
+	affected, err := s.repo.MarkMentionRead(id, agentID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("mention not found")
+	}
+	return nil
+}
+
+func (s *NoteService) MarkAllMentionsRead(agentID uint) error {
+	return s.repo.MarkAllMentionsRead(agentID)
+}
+
+// ParseMentions extracts unique, lower-cased usernames mentioned with @ in text
+func ParseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"single", "@budi tolong cek", []string{"budi"}},
+		{"several", "cc @Budi dan @siti_a", []string{"budi", "siti_a"}},
+		{"duplicates", "@budi @BUDI @budi.", []string{"budi"}},
+		{"trailing punctuation", "sudah dicek @rina.", []string{"rina"}},
+		{"dotted username", "@agent.one follow up", []string{"agent.one"}},
+		{"email is not a mention", "kirim ke budi@example.com", nil},
+		{"double at", "@@budi", nil},
+		{"lone at", "harga @ 10rb", nil},
+		{"none", "tidak ada mention", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
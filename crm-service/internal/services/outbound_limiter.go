@@ -0,0 +1,303 @@
+package services
+
+import (
+	"divine-crm/internal/config"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Graph API error codes that mean the account is sending too fast
+var graphRateLimitCodes = map[int]bool{
+	4:      true, // Application request limit reached
+	17:     true, // User request limit reached
+	32:     true, // Page request limit reached
+	613:    true, // Calls exceed the rate limit
+	80007:  true, // WhatsApp business account rate limit
+	130429: true, // Cloud API throughput reached
+	131048: true, // Spam rate limit hit
+	131056: true, // Pair rate limit hit (too many messages to one recipient)
+}
+
+// RateLimitError is returned when a message cannot be sent yet
+type RateLimitError struct {
+	Channel    string
+	RetryAfter time.Duration
+	Reason     string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit: %s (retry after %s)", e.Channel, e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+// OutboundLimiter shapes every message sent to a platform with one token bucket per
+// channel account, shared by AI replies, agents, automation and broadcasts
+type OutboundLimiter struct {
+	mu         sync.Mutex
+	rates      map[string]float64      // Messages per second by channel
+	buckets    map[string]*tokenBucket // By channel + account
+	chats      map[string]*tokenBucket // By channel + account + recipient, for per-chat limits
+	chatRate   float64                 // Telegram per-chat limit
+	dailyLimit int                     // WhatsApp tier limit
+	repo       *repository.OutboundRepository
+	pruneMu    sync.Mutex // Guards lastPrune; kept apart from mu so Wait never waits on the database
+	lastPrune  time.Time
+	maxWait    time.Duration
+	logger     *utils.Logger
+}
+
+func NewOutboundLimiter(cfg *config.OutboundConfig, repo *repository.OutboundRepository, logger *utils.Logger) *OutboundLimiter {
+	maxWait, err := time.ParseDuration(cfg.MaxWait)
+	if err != nil || maxWait <= 0 {
+		maxWait = 30 * time.Second
+	}
+
+	return &OutboundLimiter{
+		rates: map[string]float64{
+			"WhatsApp":  float64(cfg.WhatsAppPerSecond),
+			"Telegram":  float64(cfg.TelegramPerSecond),
+			"Instagram": float64(cfg.InstagramPerSecond),
+		},
+		buckets:    map[string]*tokenBucket{},
+		chats:      map[string]*tokenBucket{},
+		chatRate:   float64(cfg.TelegramPerChatPerSecond),
+		dailyLimit: cfg.WhatsAppDailyConversations,
+		repo:       repo,
+		maxWait:    maxWait,
+		logger:     logger,
+	}
+}
+
+// Wait blocks until the account may send to the recipient. It gives up with a
+// RateLimitError instead of blocking longer than the configured maximum wait.
+func (l *OutboundLimiter) Wait(channel, account, recipient string) error {
+	deadline := time.Now().Add(l.maxWait)
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		bucket := l.bucket(channel, account)
+		chat := l.chatBucket(channel, account, recipient)
+
+		delay := bucket.delay(now)
+		if chat != nil {
+			if d := chat.delay(now); d > delay {
+				delay = d
+			}
+		}
+		if delay == 0 {
+			bucket.take()
+			if chat != nil {
+				chat.take()
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if now.Add(delay).After(deadline) {
+			return &RateLimitError{Channel: channel, RetryAfter: delay, Reason: "outbound queue is full"}
+		}
+		time.Sleep(delay)
+	}
+}
+
+// ReserveConversation counts a business-initiated message against the WhatsApp
+// messaging tier, which caps unique recipients in a rolling 24 hours. Conversations
+// are stored, so the count holds across restarts and is shared by every instance;
+// the repository serializes reservations per account.
+func (l *OutboundLimiter) ReserveConversation(account, recipient string) error {
+	if l.dailyLimit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	since := now.Add(-24 * time.Hour)
+	l.pruneConversations(now, since)
+
+	reserved, err := l.repo.ReserveConversation(account, recipient, now, since, l.dailyLimit)
+	if err != nil {
+		return fmt.Errorf("failed to record WhatsApp conversation: %w", err)
+	}
+	if !reserved {
+		return &RateLimitError{Channel: "WhatsApp", RetryAfter: time.Hour, Reason: "messaging tier limit reached"}
+	}
+	return nil
+}
+
+// Throttled reacts to a rate limit response: the account pauses for retryAfter
+// (or an exponential backoff) and its rate is halved until sends succeed again
+func (l *OutboundLimiter) Throttled(channel, account string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(channel, account)
+	bucket.strikes++
+	bucket.rate = math.Max(bucket.maxRate/20, bucket.rate/2)
+	if retryAfter <= 0 {
+		retryAfter = time.Duration(math.Min(60, math.Pow(2, float64(bucket.strikes-1)))) * time.Second
+	}
+	bucket.tokens = 0
+	bucket.blockedUntil = time.Now().Add(retryAfter)
+
+	l.logger.Warn("⏳ Outbound rate limit hit, backing off",
+		"channel", channel,
+		"retry_after", retryAfter.String(),
+		"rate_per_second", bucket.rate,
+	)
+}
+
+// Succeeded slowly restores the rate of an account after throttling
+func (l *OutboundLimiter) Succeeded(channel, account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(channel, account)
+	bucket.strikes = 0
+	if bucket.rate < bucket.maxRate {
+		bucket.rate = math.Min(bucket.maxRate, bucket.rate+bucket.maxRate*0.05)
+	}
+}
+
+func (l *OutboundLimiter) bucket(channel, account string) *tokenBucket {
+	key := channel + ":" + account
+	bucket, ok := l.buckets[key]
+	if !ok {
+		rate := l.rates[channel]
+		if rate <= 0 {
+			rate = 1
+		}
+		bucket = newTokenBucket(rate)
+		l.buckets[key] = bucket
+	}
+	return bucket
+}
+
+// chatBucket returns the per-recipient bucket for channels that limit each chat (Telegram)
+func (l *OutboundLimiter) chatBucket(channel, account, recipient string) *tokenBucket {
+	if channel != "Telegram" || l.chatRate <= 0 || recipient == "" {
+		return nil
+	}
+
+	key := channel + ":" + account + ":" + recipient
+	bucket, ok := l.chats[key]
+	if !ok {
+		if len(l.chats) > 10000 {
+			l.pruneChats(time.Now())
+		}
+		bucket = newTokenBucket(l.chatRate)
+		l.chats[key] = bucket
+	}
+	return bucket
+}
+
+// pruneChats drops per-chat buckets that have been idle long enough to be full again
+func (l *OutboundLimiter) pruneChats(now time.Time) {
+	for key, bucket := range l.chats {
+		if now.Sub(bucket.last) > time.Minute {
+			delete(l.chats, key)
+		}
+	}
+}
+
+// pruneConversations drops conversations that no longer count against the tier, at most once a minute
+func (l *OutboundLimiter) pruneConversations(now, before time.Time) {
+	l.pruneMu.Lock()
+	due := now.Sub(l.lastPrune) > time.Minute
+	if due {
+		l.lastPrune = now
+	}
+	l.pruneMu.Unlock()
+	if !due {
+		return
+	}
+
+	if err := l.repo.DeleteConversationsBefore(before); err != nil {
+		l.logger.Warn("Failed to prune WhatsApp conversations", "error", err)
+	}
+}
+
+// tokenBucket allows rate messages per second with bursts of up to one second
+type tokenBucket struct {
+	maxRate      float64
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+	strikes      int
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(1, rate)
+	return &tokenBucket{
+		maxRate: rate,
+		rate:    rate,
+		burst:   burst,
+		tokens:  burst,
+		last:    time.Now(),
+	}
+}
+
+// delay returns how long until a token is available
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// detectRateLimit recognises rate limit responses from the Graph API (WhatsApp, Instagram) and Telegram
+func detectRateLimit(channel string, resp *http.Response, body []byte) *RateLimitError {
+	retryAfter := time.Duration(0)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	var payload struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+		Description string `json:"description"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	if payload.Parameters.RetryAfter > 0 {
+		retryAfter = time.Duration(payload.Parameters.RetryAfter) * time.Second
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		reason := payload.Error.Message
+		if reason == "" {
+			reason = payload.Description
+		}
+		if reason == "" {
+			reason = "too many requests"
+		}
+		return &RateLimitError{Channel: channel, RetryAfter: retryAfter, Reason: reason}
+	case graphRateLimitCodes[payload.Error.Code]:
+		return &RateLimitError{Channel: channel, RetryAfter: retryAfter, Reason: payload.Error.Message}
+	}
+	return nil
+}
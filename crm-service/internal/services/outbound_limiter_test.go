@@ -0,0 +1,89 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketDelay(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		rate  float64
+		taken int
+		after time.Duration
+		want  time.Duration
+	}{
+		{"full bucket", 10, 0, 0, 0},
+		{"burst left", 10, 9, 0, 0},
+		{"burst used up", 10, 10, 0, 100 * time.Millisecond},
+		{"refilled", 10, 10, 100 * time.Millisecond, 0},
+		{"slow rate", 0.5, 1, 0, 2 * time.Second},
+		{"partly refilled", 0.5, 1, time.Second, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTokenBucket(tt.rate)
+			bucket.last = start
+			for i := 0; i < tt.taken; i++ {
+				bucket.take()
+			}
+
+			got := bucket.delay(start.Add(tt.after))
+			if diff := got - tt.want; diff > time.Millisecond || diff < -time.Millisecond {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenBucketBlocked(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(10)
+	bucket.last = now
+	bucket.blockedUntil = now.Add(5 * time.Second)
+
+	if got := bucket.delay(now); got != 5*time.Second {
+		t.Errorf("delay() while blocked = %v, want 5s", got)
+	}
+	if got := bucket.delay(now.Add(6 * time.Second)); got != 0 {
+		t.Errorf("delay() after the block = %v, want 0", got)
+	}
+}
+
+func TestDetectRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		body       string
+		limited    bool
+		wantRetry  time.Duration
+	}{
+		{"too many requests", 429, "7", `{}`, true, 7 * time.Second},
+		{"telegram retry_after", 429, "", `{"ok":false,"description":"Too Many Requests","parameters":{"retry_after":3}}`, true, 3 * time.Second},
+		{"graph throughput code", 400, "", `{"error":{"code":130429,"message":"Rate limit hit"}}`, true, 0},
+		{"other graph error", 400, "", `{"error":{"code":100,"message":"Invalid parameter"}}`, false, 0},
+		{"success", 200, "", `{}`, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			got := detectRateLimit("WhatsApp", resp, []byte(tt.body))
+			if (got != nil) != tt.limited {
+				t.Fatalf("detectRateLimit() = %v, want limited %v", got, tt.limited)
+			}
+			if got != nil && got.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", got.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
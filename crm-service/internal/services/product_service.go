@@ -1,24 +1,53 @@
 package services
 
 import (
+	"divine-crm/internal/config"
 	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
 	"fmt"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
+// purchaseKeywords mark a message as being about buying, prices or availability. They match
+// whole words only; everyday words such as "pesan" (message) or "berapa" (how many) are left
+// out because they appear in plenty of questions that have nothing to do with the catalog.
+var purchaseKeywords = map[string]bool{
+	"beli": true, "membeli": true, "pembelian": true, "order": true, "harga": true, "harganya": true,
+	"stok": true, "stoknya": true, "tersedia": true, "bayar": true, "checkout": true, "katalog": true,
+	"produk": true, "diskon": true, "promo": true, "ongkir": true,
+	"buy": true, "price": true, "cost": true, "stock": true, "available": true, "purchase": true,
+}
+
 type ProductService struct {
-	repo   *repository.ProductRepository
-	logger *utils.Logger
+	repo          *repository.ProductRepository
+	vectorService *VectorService // Keeps product embeddings in sync and finds products for the AI; optional
+	rag           *config.RAGConfig
+	logger        *utils.Logger
+	syncLocks     sync.Map // Product ID -> *sync.Mutex, so syncs of one product never overlap
+	failedMu      sync.Mutex
+	failed        map[uint]EmbeddingSyncFailure
+}
+
+// EmbeddingSyncFailure is a product whose last embedding sync failed.
+// It is cleared by the next successful sync, e.g. from SyncAllEmbeddings.
+type EmbeddingSyncFailure struct {
+	ProductID uint      `json:"product_id"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
 }
 
-func NewProductService(repo *repository.ProductRepository, logger *utils.Logger) *ProductService {
+func NewProductService(repo *repository.ProductRepository, vectorService *VectorService, rag *config.RAGConfig, logger *utils.Logger) *ProductService {
 	return &ProductService{
-		repo:   repo,
-		logger: logger,
+		repo:          repo,
+		vectorService: vectorService,
+		rag:           rag,
+		logger:        logger,
+		failed:        map[uint]EmbeddingSyncFailure{},
 	}
 }
 
@@ -48,7 +77,12 @@ func (s *ProductService) Create(product *models.Product) error {
 	product.UpdatedAt = time.Now()
 
 	s.logger.Info("Creating product", "code", product.Code, "name", product.Name)
-	return s.repo.Create(product)
+	if err := s.repo.Create(product); err != nil {
+		return err
+	}
+
+	s.syncEmbedding(product.ID)
+	return nil
 }
 
 // Update updates a product
@@ -56,29 +90,179 @@ func (s *ProductService) Update(product *models.Product) error {
 	product.UpdatedAt = time.Now()
 
 	s.logger.Info("Updating product", "id", product.ID, "name", product.Name)
-	return s.repo.Update(product)
+	if err := s.repo.Update(product); err != nil {
+		return err
+	}
+
+	s.syncEmbedding(product.ID)
+	return nil
 }
 
 // Delete deletes a product
 func (s *ProductService) Delete(id uint) error {
 	s.logger.Info("Deleting product", "id", id)
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	s.syncEmbedding(id)
+	return nil
+}
+
+// syncEmbedding re-embeds a product in the background so saving it doesn't wait on the embedding API
+func (s *ProductService) syncEmbedding(id uint) {
+	if s.vectorService == nil {
+		return
+	}
+	go s.syncProduct(id)
+}
+
+// syncProduct brings one product's embedding in line with the catalog. The product is read
+// under its lock rather than passed in, so when saves race the last sync always embeds the
+// latest row and a deleted product loses its embedding.
+func (s *ProductService) syncProduct(id uint) error {
+	lock, _ := s.syncLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	product, err := s.repo.FindByIDOrNil(id)
+	switch {
+	case err != nil:
+	case product == nil:
+		err = s.vectorService.RemoveProductEmbeddings(id)
+	default:
+		err = s.vectorService.SyncProductEmbedding(product)
+	}
+
+	s.failedMu.Lock()
+	defer s.failedMu.Unlock()
+	if err != nil {
+		s.logger.Error("Failed to sync product embedding", "product_id", id, "error", err)
+		s.failed[id] = EmbeddingSyncFailure{ProductID: id, Error: err.Error(), FailedAt: time.Now()}
+		return err
+	}
+	delete(s.failed, id)
+	return nil
+}
+
+// FailedEmbeddingSyncs returns the products whose embedding is out of date because the last sync failed
+func (s *ProductService) FailedEmbeddingSyncs() []EmbeddingSyncFailure {
+	s.failedMu.Lock()
+	defer s.failedMu.Unlock()
+
+	failures := make([]EmbeddingSyncFailure, 0, len(s.failed))
+	for _, failure := range s.failed {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].ProductID < failures[j].ProductID })
+	return failures
+}
+
+// SyncAllEmbeddings embeds every catalog product that has no embedding or a stale one,
+// for catalogs created before embeddings were kept in sync
+func (s *ProductService) SyncAllEmbeddings() (int, error) {
+	if s.vectorService == nil {
+		return 0, fmt.Errorf("vector search is not available")
+	}
+
+	products, err := s.repo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range products {
+		if err := s.syncProduct(products[i].ID); err != nil {
+			return i, fmt.Errorf("failed to embed product %s: %w", products[i].Code, err)
+		}
+	}
+	s.logger.Info("✅ Product embeddings synced", "products", len(products))
+	return len(products), nil
+}
+
+// HasPurchaseIntent reports whether a message asks about buying, prices or stock
+func (s *ProductService) HasPurchaseIntent(message string) bool {
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if purchaseKeywords[word] {
+			return true
+		}
+	}
+	return len(identifierTerms(message)) > 0 // Product codes such as F003
 }
 
-// FormatProductList formats products for AI context
-func (s *ProductService) FormatProductList() string {
-	products, err := s.GetActiveProducts()
-	if err != nil || len(products) == 0 {
-		return "Saat ini tidak ada produk yang tersedia."
+// ProductContext lists the catalog products relevant to a message with their current price
+// and stock, for the AI prompt. Out-of-stock products are flagged or left out per
+// RAG_OUT_OF_STOCK. Returns "" when nothing relevant is found.
+func (s *ProductService) ProductContext(message string) string {
+	products := s.relevantProducts(message)
+
+	if strings.EqualFold(s.rag.OutOfStock, "exclude") {
+		inStock := products[:0]
+		for _, product := range products {
+			if product.Stock > 0 {
+				inStock = append(inStock, product)
+			}
+		}
+		products = inStock
+	}
+	if len(products) == 0 {
+		return ""
 	}
 
+	return formatProducts(products)
+}
+
+// relevantProducts finds products by meaning, falling back to product codes in the message
+func (s *ProductService) relevantProducts(message string) []models.Product {
+	limit := s.rag.ProductLimit
+	if limit <= 0 {
+		limit = 5
+	}
+
+	var products []models.Product
+	seen := map[uint]bool{}
+	if s.vectorService != nil {
+		matches, err := s.vectorService.SearchProducts(message, limit)
+		if err != nil {
+			s.logger.Warn("Semantic product search failed", "error", err)
+		}
+		for _, match := range matches {
+			if match.Product != nil && !seen[match.Product.ID] {
+				seen[match.Product.ID] = true
+				products = append(products, *match.Product)
+			}
+		}
+	}
+
+	for _, code := range identifierTerms(message) {
+		if len(products) >= limit {
+			break
+		}
+		product, err := s.repo.FindByCode(code)
+		if err == nil && !seen[product.ID] {
+			seen[product.ID] = true
+			products = append(products, *product)
+		}
+	}
+	return products
+}
+
+// formatProducts writes products, with price and stock, in the layout the AI prompt uses
+func formatProducts(products []models.Product) string {
 	var builder strings.Builder
 	builder.WriteString("\n=== DAFTAR PRODUK YANG TERSEDIA ===\n\n")
 
 	for i, product := range products {
 		builder.WriteString(fmt.Sprintf("%d. %s (Kode: %s)\n", i+1, product.Name, product.Code))
-		builder.WriteString(fmt.Sprintf("   💰 Harga: Rp %s\n", formatCurrency(product.Price)))
-		builder.WriteString(fmt.Sprintf("   📦 Stok: %d unit\n", product.Stock))
+		builder.WriteString(fmt.Sprintf("   💰 Harga: %s\n", formatRupiah(product.Price)))
+		if product.Stock > 0 {
+			builder.WriteString(fmt.Sprintf("   📦 Stok: %d unit\n", product.Stock))
+		} else {
+			builder.WriteString("   ⛔ Stok: HABIS (jangan terima pesanan, tawarkan produk lain atau pemberitahuan saat tersedia)\n")
+		}
 		if product.Description != "" {
 			builder.WriteString(fmt.Sprintf("   📝 Deskripsi: %s\n", product.Description))
 		}
@@ -91,8 +275,3 @@ func (s *ProductService) FormatProductList() string {
 
 	return builder.String()
 }
-
-// Helper function to format currency
-func formatCurrency(amount float64) string {
-	return strconv.FormatFloat(amount, 'f', 0, 64)
-}
@@ -0,0 +1,34 @@
+package services
+
+import "testing"
+
+func TestHasPurchaseIntent(t *testing.T) {
+	s := &ProductService{}
+
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"Berapa harga paket premium?", true},
+		{"mau beli 2 ya", true},
+		{"Stoknya masih ada kak?", true},
+		{"Is this available in blue?", true},
+		{"Order F003 dong", true},
+		{"F003 masih ada?", true},
+		{"Harga!", true},
+		{"Saya sudah kirim pesan kemarin", false},
+		{"Berapa lama pengirimannya?", false},
+		{"Kapan toko buka?", false},
+		{"ordering is confusing", false},
+		{"pembelinya siapa", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			if got := s.HasPurchaseIntent(tt.message); got != tt.want {
+				t.Errorf("HasPurchaseIntent(%q) = %v, want %v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,18 +4,32 @@ import (
 	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
+	"fmt"
+	"math"
+	"regexp"
 	"strings"
 )
 
+// Quick reply match modes
+const (
+	MatchExact    = "exact"
+	MatchPrefix   = "prefix"
+	MatchWord     = "word"
+	MatchRegex    = "regex"
+	MatchSemantic = "semantic"
+)
+
 type QuickReplyService struct {
-	repo   *repository.QuickReplyRepository
-	logger *utils.Logger
+	repo          *repository.QuickReplyRepository
+	vectorService *VectorService
+	logger        *utils.Logger
 }
 
-func NewQuickReplyService(repo *repository.QuickReplyRepository, logger *utils.Logger) *QuickReplyService {
+func NewQuickReplyService(repo *repository.QuickReplyRepository, vectorService *VectorService, logger *utils.Logger) *QuickReplyService {
 	return &QuickReplyService{
-		repo:   repo,
-		logger: logger,
+		repo:          repo,
+		vectorService: vectorService,
+		logger:        logger,
 	}
 }
 
@@ -24,11 +38,27 @@ func (s *QuickReplyService) GetAll() ([]models.QuickReply, error) {
 }
 
 func (s *QuickReplyService) Create(reply *models.QuickReply) error {
-	s.logger.Info("Creating quick reply", "trigger", reply.Trigger)
+	if err := s.prepare(reply); err != nil {
+		return err
+	}
+
+	s.logger.Info("Creating quick reply", "trigger", reply.Trigger, "mode", reply.MatchMode)
 	return s.repo.Create(reply)
 }
 
 func (s *QuickReplyService) Update(reply *models.QuickReply) error {
+	existing, err := s.repo.FindByID(reply.ID)
+	if err != nil {
+		return fmt.Errorf("quick reply not found")
+	}
+	reply.HitCount = existing.HitCount
+	reply.LastHitAt = existing.LastHitAt
+	reply.CreatedAt = existing.CreatedAt
+
+	if err := s.prepare(reply); err != nil {
+		return err
+	}
+
 	s.logger.Info("Updating quick reply", "id", reply.ID)
 	return s.repo.Update(reply)
 }
@@ -38,21 +68,153 @@ func (s *QuickReplyService) Delete(id uint) error {
 	return s.repo.Delete(id)
 }
 
-// Check if message matches any quick reply
-func (s *QuickReplyService) CheckQuickReply(message string) (*models.QuickReply, error) {
-	messageLower := strings.ToLower(strings.TrimSpace(message))
-
+// ReembedSemantic re-embeds the triggers of semantic quick replies after the embedding model changed
+func (s *QuickReplyService) ReembedSemantic() (int, error) {
 	replies, err := s.repo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for i := range replies {
+		reply := &replies[i]
+		if reply.MatchMode != MatchSemantic {
+			continue
+		}
+
+		embedding, err := s.vectorService.GenerateEmbedding(reply.Trigger)
+		if err != nil {
+			return done, fmt.Errorf("failed to embed trigger of quick reply %d: %w", reply.ID, err)
+		}
+		reply.Embedding = embedding.Slice()
+		if err := s.repo.Update(reply); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// prepare validates the match mode and embeds the trigger for semantic matching
+func (s *QuickReplyService) prepare(reply *models.QuickReply) error {
+	reply.Trigger = strings.TrimSpace(reply.Trigger)
+	if reply.Trigger == "" {
+		return fmt.Errorf("trigger is required")
+	}
+	if reply.MatchMode == "" {
+		reply.MatchMode = MatchWord
+	}
+	if reply.SimilarityThreshold <= 0 || reply.SimilarityThreshold > 1 {
+		reply.SimilarityThreshold = 0.85
+	}
+
+	reply.Embedding = nil
+	switch reply.MatchMode {
+	case MatchExact, MatchPrefix, MatchWord:
+	case MatchRegex:
+		if _, err := regexp.Compile(reply.Trigger); err != nil {
+			return fmt.Errorf("invalid regex trigger: %v", err)
+		}
+	case MatchSemantic:
+		if s.vectorService == nil {
+			return fmt.Errorf("semantic matching requires the vector service")
+		}
+		embedding, err := s.vectorService.GenerateEmbedding(reply.Trigger)
+		if err != nil {
+			return fmt.Errorf("failed to embed trigger: %w", err)
+		}
+		reply.Embedding = embedding.Slice()
+	default:
+		return fmt.Errorf("unknown match mode: %s", reply.MatchMode)
+	}
+
+	return nil
+}
+
+// CheckQuickReply returns the highest priority quick reply matching the message on a channel,
+// or nil when nothing matches. The message is only embedded when a semantic reply is reached.
+func (s *QuickReplyService) CheckQuickReply(message, channel string) (*models.QuickReply, error) {
+	text := strings.TrimSpace(message)
+	if text == "" {
+		return nil, nil
+	}
+
+	replies, err := s.repo.FindActiveForChannel(channel)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, reply := range replies {
-		triggerLower := strings.ToLower(reply.Trigger)
-		if strings.Contains(messageLower, triggerLower) {
-			return &reply, nil
+	var messageEmbedding []float32
+	embeddingFailed := false
+
+	for i := range replies {
+		reply := &replies[i]
+
+		matched := false
+		if reply.MatchMode == MatchSemantic {
+			if embeddingFailed || len(reply.Embedding) == 0 {
+				continue
+			}
+			if messageEmbedding == nil {
+				vector, err := s.vectorService.GenerateEmbedding(text)
+				if err != nil {
+					s.logger.Warn("Failed to embed message for quick replies", "error", err)
+					embeddingFailed = true
+					continue
+				}
+				messageEmbedding = vector.Slice()
+			}
+			matched = cosineSimilarity(messageEmbedding, reply.Embedding) >= reply.SimilarityThreshold
+		} else {
+			matched = matchQuickReply(reply, text)
+		}
+
+		if matched {
+			if err := s.repo.IncrementHit(reply.ID); err != nil {
+				s.logger.Warn("Failed to update quick reply hit count", "id", reply.ID, "error", err)
+			}
+			return reply, nil
 		}
 	}
 
 	return nil, nil
 }
+
+// matchQuickReply applies the lexical match modes
+func matchQuickReply(reply *models.QuickReply, message string) bool {
+	messageLower := strings.ToLower(message)
+	triggerLower := strings.ToLower(reply.Trigger)
+
+	switch reply.MatchMode {
+	case MatchExact:
+		return messageLower == triggerLower
+	case MatchPrefix:
+		return strings.HasPrefix(messageLower, triggerLower)
+	case MatchWord:
+		pattern, err := regexp.Compile(`(?i)(^|\W)` + regexp.QuoteMeta(reply.Trigger) + `($|\W)`)
+		return err == nil && pattern.MatchString(message)
+	case MatchRegex:
+		pattern, err := regexp.Compile(reply.Trigger)
+		return err == nil && pattern.MatchString(message)
+	}
+
+	return false
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"testing"
+)
+
+func TestMatchQuickReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		trigger string
+		message string
+		want    bool
+	}{
+		{"exact", MatchExact, "Jam buka", "jam buka", true},
+		{"exact needs the whole message", MatchExact, "jam buka", "jam buka toko?", false},
+		{"prefix", MatchPrefix, "promo", "Promo hari ini apa?", true},
+		{"prefix not in the middle", MatchPrefix, "promo", "ada promo?", false},
+		{"word", MatchWord, "ongkir", "Berapa ongkir ke Bandung?", true},
+		{"word at the end", MatchWord, "ongkir", "cek ongkir", true},
+		{"word inside another word", MatchWord, "kir", "berapa ongkir", false},
+		{"word with special characters", MatchWord, "c++", "kursus c++ ada?", true},
+		{"regex", MatchRegex, `(?i)^(hi|halo)\b`, "Halo kak", true},
+		{"regex no match", MatchRegex, `^\d+$`, "12a", false},
+		{"invalid regex", MatchRegex, `(`, "(", false},
+		{"semantic is not lexical", MatchSemantic, "jam buka", "jam buka", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := &models.QuickReply{Trigger: tt.trigger, MatchMode: tt.mode}
+			if got := matchQuickReply(reply, tt.message); got != tt.want {
+				t.Errorf("matchQuickReply(%q, %q) = %v, want %v", tt.trigger, tt.message, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"divine-crm/internal/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Rerank modes
+const (
+	RerankNone = "none"
+	RerankLLM  = "llm"
+	RerankAPI  = "api"
+)
+
+// rerankPassageLength caps the characters of each passage sent to the reranker
+const rerankPassageLength = 1200
+
+// Reranker scores how relevant each document is to a query; higher is more relevant
+type Reranker interface {
+	Rerank(query string, documents []string) ([]float64, error)
+}
+
+// NewReranker builds the reranker selected by RAG_RERANK, or nil when reranking is off
+func NewReranker(cfg *config.Config) (Reranker, error) {
+	rc := cfg.RAG
+	client := &http.Client{Timeout: 20 * time.Second}
+
+	switch strings.ToLower(rc.Rerank) {
+	case "", RerankNone:
+		return nil, nil
+
+	case RerankLLM:
+		return &llmReranker{
+			endpoint: firstNonEmpty(rc.RerankEndpoint, cfg.OpenAI.Endpoint),
+			apiKey:   firstNonEmpty(rc.RerankAPIKey, cfg.OpenAI.APIKey),
+			model:    firstNonEmpty(rc.RerankModel, cfg.OpenAI.Model),
+			client:   client,
+		}, nil
+
+	case RerankAPI:
+		if rc.RerankEndpoint == "" {
+			return nil, fmt.Errorf("RAG_RERANK_ENDPOINT is required for the api reranker")
+		}
+		return &apiReranker{
+			endpoint: rc.RerankEndpoint,
+			apiKey:   rc.RerankAPIKey,
+			model:    rc.RerankModel,
+			client:   client,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown rerank mode: %s", rc.Rerank)
+}
+
+func truncatePassage(text string) string {
+	if utf8.RuneCountInString(text) <= rerankPassageLength {
+		return text
+	}
+	return string([]rune(text)[:rerankPassageLength]) + "…"
+}
+
+// ==================== LLM ====================
+
+// llmReranker asks a chat completion model to grade each passage
+type llmReranker struct {
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+func (r *llmReranker) Rerank(query string, documents []string) ([]float64, error) {
+	var passages strings.Builder
+	for i, doc := range documents {
+		fmt.Fprintf(&passages, "[%d] %s\n\n", i+1, truncatePassage(doc))
+	}
+
+	prompt := fmt.Sprintf(`Rate how well each passage helps answer the question, from 0 (irrelevant) to 10 (answers it directly).
+Reply with only a JSON array of %d numbers, one per passage in order.
+
+Question: %s
+
+Passages:
+%s`, len(documents), query, passages.String())
+
+	requestBody := map[string]interface{}{
+		"model": r.model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + r.apiKey}
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(r.client, r.endpoint, headers, requestBody, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in rerank response")
+	}
+
+	content := result.Choices[0].Message.Content
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("rerank response is not a JSON array: %s", content)
+	}
+
+	var scores []float64
+	if err := json.Unmarshal([]byte(content[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("failed to parse rerank scores: %w", err)
+	}
+	if len(scores) != len(documents) {
+		return nil, fmt.Errorf("reranker returned %d scores for %d passages", len(scores), len(documents))
+	}
+	return scores, nil
+}
+
+// ==================== RERANK API ====================
+
+// apiReranker calls a cross-encoder behind a Cohere/Jina-compatible /rerank endpoint
+type apiReranker struct {
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+func (r *apiReranker) Rerank(query string, documents []string) ([]float64, error) {
+	passages := make([]string, len(documents))
+	for i, doc := range documents {
+		passages[i] = truncatePassage(doc)
+	}
+
+	requestBody := map[string]interface{}{
+		"query":     query,
+		"documents": passages,
+		"top_n":     len(passages),
+	}
+	if r.model != "" {
+		requestBody["model"] = r.model
+	}
+
+	headers := map[string]string{}
+	if r.apiKey != "" {
+		headers["Authorization"] = "Bearer " + r.apiKey
+	}
+
+	var result struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := postJSON(r.client, r.endpoint, headers, requestBody, &result); err != nil {
+		return nil, err
+	}
+
+	scores := make([]float64, len(documents))
+	for i := range scores {
+		scores[i] = -1 // Documents the API leaves out rank last
+	}
+	for _, item := range result.Results {
+		if item.Index >= 0 && item.Index < len(scores) {
+			scores[item.Index] = item.RelevanceScore
+		}
+	}
+	return scores, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"fmt"
+	"strings"
+)
+
+var validAttributeOperators = map[string]bool{
+	"equals": true, "not_equals": true, "contains": true, "exists": true, "not_exists": true,
+}
+
+type SegmentService struct {
+	repo   *repository.SegmentRepository
+	logger *utils.Logger
+}
+
+func NewSegmentService(repo *repository.SegmentRepository, logger *utils.Logger) *SegmentService {
+	return &SegmentService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// AudiencePreview is the size of an audience with a sample of its contacts
+type AudiencePreview struct {
+	Count  int64            `json:"count"`
+	Sample []models.Contact `json:"sample"`
+}
+
+func (s *SegmentService) GetAll() ([]models.Segment, error) {
+	return s.repo.FindAll()
+}
+
+func (s *SegmentService) GetByID(id uint) (*models.Segment, error) {
+	segment, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if segment == nil {
+		return nil, fmt.Errorf("segment not found")
+	}
+	return segment, nil
+}
+
+func (s *SegmentService) Create(segment *models.Segment) error {
+	if err := s.validate(segment); err != nil {
+		return err
+	}
+
+	s.logger.Info("Creating segment", "name", segment.Name)
+	return s.repo.Create(segment)
+}
+
+func (s *SegmentService) Update(segment *models.Segment) error {
+	existing, err := s.GetByID(segment.ID)
+	if err != nil {
+		return err
+	}
+	segment.CreatedBy = existing.CreatedBy
+	segment.CreatedAt = existing.CreatedAt
+
+	if err := s.validate(segment); err != nil {
+		return err
+	}
+
+	s.logger.Info("Updating segment", "id", segment.ID)
+	return s.repo.Update(segment)
+}
+
+func (s *SegmentService) Delete(id uint) error {
+	s.logger.Info("Deleting segment", "id", id)
+	return s.repo.Delete(id)
+}
+
+// GetContacts returns every contact currently in the segment
+func (s *SegmentService) GetContacts(id uint) ([]models.Contact, error) {
+	segment, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindContacts(&segment.Filters, 0)
+}
+
+// Preview counts the contacts matching a filter and returns the first few
+func (s *SegmentService) Preview(filter *models.SegmentFilter) (*AudiencePreview, error) {
+	if err := ValidateSegmentFilter(filter); err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountContacts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sample, err := s.repo.FindContacts(filter, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AudiencePreview{Count: count, Sample: sample}, nil
+}
+
+func (s *SegmentService) validate(segment *models.Segment) error {
+	segment.Name = strings.TrimSpace(segment.Name)
+	if segment.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return ValidateSegmentFilter(&segment.Filters)
+}
+
+// ValidateSegmentFilter checks attribute filters and date ranges
+func ValidateSegmentFilter(filter *models.SegmentFilter) error {
+	for i, attr := range filter.Attributes {
+		if strings.TrimSpace(attr.Key) == "" {
+			return fmt.Errorf("attribute filter %d: key is required", i+1)
+		}
+		if !validAttributeOperators[attr.Operator] {
+			return fmt.Errorf("attribute filter %d: unknown operator %q", i+1, attr.Operator)
+		}
+	}
+	if filter.LastContactDays < 0 {
+		return fmt.Errorf("last_contact_days cannot be negative")
+	}
+	if filter.LastContactAfter != nil && filter.LastContactBefore != nil &&
+		filter.LastContactAfter.After(*filter.LastContactBefore) {
+		return fmt.Errorf("last_contact_after must be before last_contact_before")
+	}
+	return nil
+}
+
+// restrictToChannel limits a filter to the template channel.
+// It returns false when the segment only targets other channels.
+func restrictToChannel(filter models.SegmentFilter, channel string) (models.SegmentFilter, bool) {
+	if channel == "" || channel == "All" {
+		return filter, true
+	}
+	if len(filter.Channels) == 0 {
+		filter.Channels = []string{channel}
+		return filter, true
+	}
+	for _, c := range filter.Channels {
+		if strings.EqualFold(c, channel) {
+			filter.Channels = []string{channel}
+			return filter, true
+		}
+	}
+	return filter, false
+}
@@ -10,19 +10,22 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type TelegramService struct {
-	repo   *repository.PlatformRepository
-	logger *utils.Logger
-	client *http.Client
+	repo    *repository.PlatformRepository
+	limiter *OutboundLimiter
+	logger  *utils.Logger
+	client  *http.Client
 }
 
-func NewTelegramService(repo *repository.PlatformRepository, logger *utils.Logger) *TelegramService {
+func NewTelegramService(repo *repository.PlatformRepository, limiter *OutboundLimiter, logger *utils.Logger) *TelegramService {
 	return &TelegramService{
-		repo:   repo,
-		logger: logger,
-		client: &http.Client{},
+		repo:    repo,
+		limiter: limiter,
+		logger:  logger,
+		client:  &http.Client{},
 	}
 }
 
@@ -57,6 +60,12 @@ func (s *TelegramService) SendMessage(chatID interface{}, message string) error
 		"message_length", len(message),
 	)
 
+	// The bot ID (before the colon) identifies the account without exposing the token
+	account := strings.SplitN(botToken, ":", 2)[0]
+	if err := s.limiter.Wait("Telegram", account, strconv.FormatInt(finalChatID, 10)); err != nil {
+		return err
+	}
+
 	reqBody := map[string]interface{}{
 		"chat_id": finalChatID,
 		"text":    message,
@@ -75,6 +84,11 @@ func (s *TelegramService) SendMessage(chatID interface{}, message string) error
 
 	body, _ := io.ReadAll(resp.Body)
 
+	if rateLimit := detectRateLimit("Telegram", resp, body); rateLimit != nil {
+		s.limiter.Throttled("Telegram", account, rateLimit.RetryAfter)
+		return rateLimit
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		s.logger.Error("Telegram API error",
 			"status", resp.StatusCode,
@@ -83,6 +97,7 @@ func (s *TelegramService) SendMessage(chatID interface{}, message string) error
 		return fmt.Errorf("Telegram API error: %s", string(body))
 	}
 
+	s.limiter.Succeeded("Telegram", account)
 	s.logger.Info("✅ Telegram message sent successfully")
 	return nil
 }
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"errors"
+	"fmt"
+)
+
+// ErrIndexRebuildRunning is returned when a rebuild is requested while one is in progress
+var ErrIndexRebuildRunning = errors.New("a vector index rebuild is already running")
+
+// VectorIndexStatus lists the HNSW indexes with the settings new builds and searches use
+type VectorIndexStatus struct {
+	Indexes        []models.VectorIndexInfo `json:"indexes"`
+	M              int                      `json:"m"`
+	EFConstruction int                      `json:"ef_construction"`
+	EFSearch       int                      `json:"ef_search"`
+	Rebuilding     bool                     `json:"rebuilding"`
+}
+
+func (s *VectorService) GetVectorIndexes() (*VectorIndexStatus, error) {
+	indexes, err := s.repo.GetVectorIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &VectorIndexStatus{
+		Indexes:        indexes,
+		M:              s.config.VectorIndex.M,
+		EFConstruction: s.config.VectorIndex.EFConstruction,
+		EFSearch:       s.config.VectorIndex.EFSearch,
+		Rebuilding:     s.rebuildingIndexes.Load(),
+	}, nil
+}
+
+// StartVectorIndexRebuild rebuilds the HNSW indexes in the background, picking up
+// changed VECTOR_INDEX_M / VECTOR_INDEX_EF_CONSTRUCTION settings
+func (s *VectorService) StartVectorIndexRebuild() error {
+	if !s.rebuildingIndexes.CompareAndSwap(false, true) {
+		return ErrIndexRebuildRunning
+	}
+
+	go func() {
+		defer s.rebuildingIndexes.Store(false)
+		if err := s.RebuildVectorIndexes(); err != nil {
+			s.logger.Error("Vector index rebuild failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// RebuildVectorIndexes rebuilds the HNSW index of every searched column
+func (s *VectorService) RebuildVectorIndexes() error {
+	if dimensions := s.embedder.Dimensions(); dimensions > models.HNSWMaxDimensions {
+		return fmt.Errorf("HNSW indexes support up to %d dimensions, the embedding model has %d", models.HNSWMaxDimensions, dimensions)
+	}
+
+	for _, col := range models.EmbeddingColumns {
+		if !col.Searched {
+			continue
+		}
+
+		s.logger.Info("🧭 Rebuilding vector index", "table", col.Table, "column", col.Column)
+		if err := s.repo.RebuildVectorIndex(col, s.config.VectorIndex.M, s.config.VectorIndex.EFConstruction); err != nil {
+			return fmt.Errorf("failed to rebuild index on %s.%s: %w", col.Table, col.Column, err)
+		}
+	}
+
+	s.logger.Info("✅ Vector indexes rebuilt",
+		"m", s.config.VectorIndex.M, "ef_construction", s.config.VectorIndex.EFConstruction)
+	return nil
+}
@@ -1,84 +1,50 @@
 package services
 
 import (
-	"bytes"
 	"divine-crm/internal/config"
 	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"sync/atomic"
 
 	"github.com/pgvector/pgvector-go"
 )
 
 type VectorService struct {
-	repo   *repository.VectorRepository
-	config *config.Config
-	logger *utils.Logger
+	repo     *repository.VectorRepository
+	embedder Embedder
+	cache    *embeddingLRU
+	reranker Reranker
+	config   *config.Config
+	logger   *utils.Logger
+
+	rebuildingIndexes atomic.Bool
 }
 
-func NewVectorService(repo *repository.VectorRepository, cfg *config.Config, logger *utils.Logger) *VectorService {
-	return &VectorService{
-		repo:   repo,
-		config: cfg,
-		logger: logger,
-	}
-}
-
-// GenerateEmbedding generates vector embedding for text using OpenAI
-func (s *VectorService) GenerateEmbedding(text string) (pgvector.Vector, error) {
-	requestBody := map[string]interface{}{
-		"input": text,
-		"model": "text-embedding-ada-002",
-	}
-
-	jsonData, err := json.Marshal(requestBody)
+func NewVectorService(repo *repository.VectorRepository, embedder Embedder, cfg *config.Config, logger *utils.Logger) *VectorService {
+	reranker, err := NewReranker(cfg)
 	if err != nil {
-		return pgvector.Vector{}, err
+		logger.Warn("Reranking disabled", "error", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return pgvector.Vector{}, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.config.OpenAI.APIKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return pgvector.Vector{}, err
+	return &VectorService{
+		repo:     repo,
+		embedder: embedder,
+		cache:    newEmbeddingLRU(cfg.Embedding.CacheSize),
+		reranker: reranker,
+		config:   cfg,
+		logger:   logger,
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GenerateEmbedding generates vector embedding for text with the configured embedding provider
+func (s *VectorService) GenerateEmbedding(text string) (pgvector.Vector, error) {
+	embeddings, err := s.GenerateEmbeddings([]string{text})
 	if err != nil {
 		return pgvector.Vector{}, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return pgvector.Vector{}, fmt.Errorf("OpenAI API error: %s", string(body))
-	}
-
-	var result struct {
-		Data []struct {
-			Embedding []float32 `json:"embedding"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return pgvector.Vector{}, err
-	}
-
-	if len(result.Data) == 0 {
-		return pgvector.Vector{}, fmt.Errorf("no embedding returned")
-	}
-
-	return pgvector.NewVector(result.Data[0].Embedding), nil
+	return embeddings[0], nil
 }
 
 // ==================== KNOWLEDGE BASE ====================
@@ -102,13 +68,14 @@ func (s *VectorService) AddKnowledge(title, content, category, tags, source stri
 	return s.repo.CreateKnowledge(kb)
 }
 
+// SearchKnowledge runs hybrid (vector + full-text) retrieval over the knowledge base
 func (s *VectorService) SearchKnowledge(query string, limit int) ([]models.KnowledgeBase, error) {
 	embedding, err := s.GenerateEmbedding(query)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.repo.FindSimilarKnowledge(embedding, limit)
+	return s.hybridKnowledge(query, embedding, limit)
 }
 
 func (s *VectorService) GetAllKnowledge() ([]models.KnowledgeBase, error) {
@@ -118,15 +85,11 @@ func (s *VectorService) GetAllKnowledge() ([]models.KnowledgeBase, error) {
 // ==================== CHAT HISTORY ====================
 
 func (s *VectorService) SaveChatWithEmbedding(contactID uint, userMessage, aiResponse, sentiment, intent string) error {
-	msgEmbedding, err := s.GenerateEmbedding(userMessage)
-	if err != nil {
-		return err
-	}
-
-	respEmbedding, err := s.GenerateEmbedding(aiResponse)
+	embeddings, err := s.GenerateEmbeddings([]string{userMessage, aiResponse})
 	if err != nil {
 		return err
 	}
+	msgEmbedding, respEmbedding := embeddings[0], embeddings[1]
 
 	history := &models.ChatHistory{
 		ContactID:         contactID,
@@ -152,9 +115,13 @@ func (s *VectorService) GetSimilarConversations(contactID uint, query string, li
 
 // ==================== PRODUCT EMBEDDINGS ====================
 
+// AddProductEmbedding stores hand-written text for a product; catalog syncs leave it alone
 func (s *VectorService) AddProductEmbedding(productID uint, description, features, useCases string) error {
-	combinedText := fmt.Sprintf("%s. %s. %s", description, features, useCases)
-	embedding, err := s.GenerateEmbedding(combinedText)
+	return s.addProductEmbedding(productID, description, features, useCases, false)
+}
+
+func (s *VectorService) addProductEmbedding(productID uint, description, features, useCases string, managed bool) error {
+	embedding, err := s.GenerateEmbedding(productEmbeddingText(description, features, useCases))
 	if err != nil {
 		return err
 	}
@@ -165,18 +132,86 @@ func (s *VectorService) AddProductEmbedding(productID uint, description, feature
 		Features:    features,
 		UseCases:    useCases,
 		Embedding:   embedding,
+		Managed:     managed,
 	}
 
 	return s.repo.CreateProductEmbedding(pe)
 }
 
+// catalogDescription is the product text kept in sync with the catalog
+func catalogDescription(product *models.Product) string {
+	description := fmt.Sprintf("%s (Kode: %s)", product.Name, product.Code)
+	if product.Description != "" {
+		description += ". " + product.Description
+	}
+	return description
+}
+
+// SyncProductEmbedding embeds a catalog product, or re-embeds its managed embeddings when
+// the name, code or description changed. Embeddings added or edited by hand are never
+// overwritten; a product that only has those gets no generated one.
+func (s *VectorService) SyncProductEmbedding(product *models.Product) error {
+	description := catalogDescription(product)
+
+	existing, err := s.repo.FindProductEmbeddingsByProductID(product.ID)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return s.addProductEmbedding(product.ID, description, "", "", true)
+	}
+
+	for i := range existing {
+		pe := &existing[i]
+		if !pe.Managed || pe.Description == description {
+			continue // Price and stock are joined live, only text changes need a new vector
+		}
+		_, err := s.updateProductEmbedding(pe, &ProductEmbeddingUpdate{
+			Description: description,
+			Features:    pe.Features,
+			UseCases:    pe.UseCases,
+		}, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveProductEmbeddings deletes the embeddings of a product removed from the catalog
+func (s *VectorService) RemoveProductEmbeddings(productID uint) error {
+	existing, err := s.repo.FindProductEmbeddingsByProductID(productID)
+	if err != nil {
+		return err
+	}
+
+	for _, pe := range existing {
+		if err := s.DeleteProductEmbedding(pe.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchProducts finds catalog products by meaning, with their live price and stock
 func (s *VectorService) SearchProducts(query string, limit int) ([]models.ProductEmbedding, error) {
 	embedding, err := s.GenerateEmbedding(query)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.repo.FindSimilarProducts(embedding, limit)
+	products, err := s.repo.FindSimilarProducts(embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	relevant := products[:0]
+	for _, product := range products {
+		if product.Similarity >= s.config.RAG.MinProductSimilarity {
+			relevant = append(relevant, product)
+		}
+	}
+	return relevant, nil
 }
 
 // ==================== FAQ ====================
@@ -204,7 +239,11 @@ func (s *VectorService) SearchFAQ(query string, limit int) ([]models.FAQEmbeddin
 		return nil, err
 	}
 
-	faqs, err := s.repo.FindSimilarFAQ(embedding, limit)
+	return s.searchFAQ(query, embedding, limit)
+}
+
+func (s *VectorService) searchFAQ(query string, embedding pgvector.Vector, limit int) ([]models.FAQEmbedding, error) {
+	faqs, err := s.hybridFAQ(query, embedding, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -223,15 +262,36 @@ func (s *VectorService) GetAllFAQ() ([]models.FAQEmbedding, error) {
 
 // ==================== RAG (Retrieval Augmented Generation) ====================
 
-func (s *VectorService) BuildRAGContext(query string) (string, error) {
+// RAGResult is the context retrieved for a message, with the scores of what was used
+type RAGResult struct {
+	Knowledge []models.KnowledgeBase `json:"knowledge"`
+	FAQs      []models.FAQEmbedding  `json:"faqs"`
+	Context   string                 `json:"context"`
+
+	// NoGoodContext is set when nothing passed the similarity cutoffs, so an answer
+	// would rest on the model's own knowledge
+	NoGoodContext bool    `json:"no_good_context"`
+	TopSimilarity float64 `json:"top_similarity"`
+}
+
+// RetrieveContext finds the knowledge and FAQs relevant to a message
+func (s *VectorService) RetrieveContext(query string) (*RAGResult, error) {
+	// Embed the query once for both searches
+	embedding, err := s.GenerateEmbedding(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RAGResult{}
+
 	// Search knowledge base
-	knowledge, err := s.SearchKnowledge(query, 3)
+	result.Knowledge, err = s.hybridKnowledge(query, embedding, s.config.RAG.KnowledgeLimit)
 	if err != nil {
 		s.logger.Warn("Failed to search knowledge", "error", err)
 	}
 
 	// Search FAQ
-	faqs, err := s.SearchFAQ(query, 2)
+	result.FAQs, err = s.searchFAQ(query, embedding, s.config.RAG.FAQLimit)
 	if err != nil {
 		s.logger.Warn("Failed to search FAQ", "error", err)
 	}
@@ -239,19 +299,39 @@ func (s *VectorService) BuildRAGContext(query string) (string, error) {
 	// Build context
 	var context string
 
-	if len(knowledge) > 0 {
+	if len(result.Knowledge) > 0 {
 		context += "\n=== KNOWLEDGE BASE ===\n"
-		for i, kb := range knowledge {
+		for i, kb := range result.Knowledge {
 			context += fmt.Sprintf("%d. %s\n%s\n\n", i+1, kb.Title, kb.Content)
+			if kb.Similarity > result.TopSimilarity {
+				result.TopSimilarity = kb.Similarity
+			}
 		}
 	}
 
-	if len(faqs) > 0 {
+	if len(result.FAQs) > 0 {
 		context += "\n=== FAQ ===\n"
-		for _, faq := range faqs {
+		for _, faq := range result.FAQs {
 			context += fmt.Sprintf("Q: %s\nA: %s\n\n", faq.Question, faq.Answer)
+			if faq.Similarity > result.TopSimilarity {
+				result.TopSimilarity = faq.Similarity
+			}
 		}
 	}
 
-	return context, nil
+	result.Context = context
+	result.NoGoodContext = context == ""
+	if result.NoGoodContext {
+		s.logger.Info("🔎 No relevant context found for message")
+	}
+	return result, nil
+}
+
+func (s *VectorService) BuildRAGContext(query string) (string, error) {
+	result, err := s.RetrieveContext(query)
+	if err != nil {
+		s.logger.Warn("Failed to retrieve RAG context", "error", err)
+		return "", nil
+	}
+	return result.Context, nil
 }
@@ -4,10 +4,13 @@ import (
 	"divine-crm/internal/utils"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 )
 
 type WebhookService struct {
 	chatService      *ChatService
+	broadcastService *BroadcastService
 	whatsappService  *WhatsAppService
 	instagramService *InstagramService
 	telegramService  *TelegramService
@@ -16,6 +19,7 @@ type WebhookService struct {
 
 func NewWebhookService(
 	chatService *ChatService,
+	broadcastService *BroadcastService,
 	whatsappService *WhatsAppService,
 	instagramService *InstagramService,
 	telegramService *TelegramService,
@@ -23,6 +27,7 @@ func NewWebhookService(
 ) *WebhookService {
 	return &WebhookService{
 		chatService:      chatService,
+		broadcastService: broadcastService,
 		whatsappService:  whatsappService,
 		instagramService: instagramService,
 		telegramService:  telegramService,
@@ -57,6 +62,16 @@ type WhatsAppWebhookPayload struct {
 					} `json:"text"`
 					Type string `json:"type"`
 				} `json:"messages"`
+				Statuses []struct {
+					ID          string `json:"id"`
+					Status      string `json:"status"` // sent, delivered, read, failed
+					Timestamp   string `json:"timestamp"`
+					RecipientID string `json:"recipient_id"`
+					Errors      []struct {
+						Code  int    `json:"code"`
+						Title string `json:"title"`
+					} `json:"errors"`
+				} `json:"statuses"`
 			} `json:"value"`
 			Field string `json:"field"`
 		} `json:"changes"`
@@ -109,6 +124,23 @@ func (s *WebhookService) ProcessWhatsAppWebhook(payload *WhatsAppWebhookPayload)
 	value := payload.Entry[0].Changes[0].Value
 	log.Printf("📊 Messages count: %d", len(value.Messages))
 
+	// Delivery receipts for messages we sent
+	if s.broadcastService != nil {
+		for _, status := range value.Statuses {
+			errorMessage := ""
+			if len(status.Errors) > 0 {
+				errorMessage = fmt.Sprintf("%d: %s", status.Errors[0].Code, status.Errors[0].Title)
+			}
+			at := time.Now()
+			if ts, err := strconv.ParseInt(status.Timestamp, 10, 64); err == nil {
+				at = time.Unix(ts, 0)
+			}
+			if err := s.broadcastService.HandleDeliveryStatus(status.ID, status.Status, errorMessage, at); err != nil {
+				log.Printf("❌ Failed to apply delivery status for %s: %v", status.ID, err)
+			}
+		}
+	}
+
 	if len(value.Messages) == 0 {
 		log.Printf("ℹ️  No messages in webhook (might be status update)")
 		return nil
@@ -159,6 +191,11 @@ func (s *WebhookService) ProcessWhatsAppWebhook(payload *WhatsAppWebhookPayload)
 
 	log.Printf("✅ ChatService returned: msg_id=%v, response=%s", outMsg.ID, aiResponse)
 
+	// Nothing to send when automation handed the chat to an agent
+	if aiResponse == "" {
+		return nil
+	}
+
 	// Send response via WhatsApp
 	log.Printf("📤 Sending response to WhatsApp...")
 
@@ -242,6 +279,9 @@ func (s *WebhookService) ProcessInstagramWebhook(payload *InstagramWebhookPayloa
 			}
 
 			log.Printf("✅ AI Response: %s", aiResponse)
+			if aiResponse == "" {
+				continue
+			}
 
 			// Send AI response back to user
 			log.Printf("📤 Sending Instagram reply to %s...", senderID)
@@ -305,6 +345,10 @@ func (s *WebhookService) ProcessTelegramWebhook(payload *TelegramWebhookPayload)
 		return err
 	}
 
+	if aiResponse == "" {
+		return nil
+	}
+
 	// Send response
 	if err := s.telegramService.SendMessage(chatID, aiResponse); err != nil {
 		log.Printf("❌ Error sending Telegram message: %v", err)
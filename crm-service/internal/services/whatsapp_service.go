@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"divine-crm/internal/models"
 	"divine-crm/internal/repository"
 	"divine-crm/internal/utils"
 	"encoding/json"
@@ -12,28 +13,41 @@ import (
 )
 
 type WhatsAppService struct {
-	repo   *repository.PlatformRepository
-	logger *utils.Logger
-	client *http.Client
+	repo    *repository.PlatformRepository
+	limiter *OutboundLimiter
+	logger  *utils.Logger
+	client  *http.Client
 }
 
-func NewWhatsAppService(repo *repository.PlatformRepository, logger *utils.Logger) *WhatsAppService {
+func NewWhatsAppService(repo *repository.PlatformRepository, limiter *OutboundLimiter, logger *utils.Logger) *WhatsAppService {
 	return &WhatsAppService{
-		repo:   repo,
-		logger: logger,
-		client: &http.Client{},
+		repo:    repo,
+		limiter: limiter,
+		logger:  logger,
+		client:  &http.Client{},
 	}
 }
 
+// ReserveConversation counts a business-initiated message against the messaging tier
+func (s *WhatsAppService) ReserveConversation(to string) error {
+	return s.limiter.ReserveConversation(os.Getenv("WHATSAPP_PHONE_NUMBER_ID"), to)
+}
+
 // SendMessage sends a message via WhatsApp Business API
 func (s *WhatsAppService) SendMessage(to, message string) error {
+	_, err := s.SendMessageWithID(to, message)
+	return err
+}
+
+// SendMessageWithID sends a message and returns the WhatsApp message ID used in delivery receipts
+func (s *WhatsAppService) SendMessageWithID(to, message string) (string, error) {
 	// Get WhatsApp credentials from env
 	accessToken := os.Getenv("WHATSAPP_ACCESS_TOKEN")
 	phoneNumberID := os.Getenv("WHATSAPP_PHONE_NUMBER_ID")
 	apiVersion := os.Getenv("WHATSAPP_API_VERSION")
 
 	if accessToken == "" || phoneNumberID == "" {
-		return fmt.Errorf("WhatsApp credentials not configured")
+		return "", fmt.Errorf("WhatsApp credentials not configured")
 	}
 
 	if apiVersion == "" {
@@ -43,6 +57,10 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 	// Build API URL
 	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", apiVersion, phoneNumberID)
 
+	if err := s.limiter.Wait("WhatsApp", phoneNumberID, to); err != nil {
+		return "", err
+	}
+
 	s.logger.Info("📤 Sending WhatsApp message",
 		"to", to,
 		"message_length", len(message),
@@ -63,14 +81,14 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		s.logger.Error("Failed to marshal request", "error", err)
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		s.logger.Error("Failed to create request", "error", err)
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -80,7 +98,7 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 	resp, err := s.client.Do(req)
 	if err != nil {
 		s.logger.Error("Failed to send request", "error", err)
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -88,7 +106,12 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		s.logger.Error("Failed to read response", "error", err)
-		return fmt.Errorf("failed to read response: %w", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if rateLimit := detectRateLimit("WhatsApp", resp, body); rateLimit != nil {
+		s.limiter.Throttled("WhatsApp", phoneNumberID, rateLimit.RetryAfter)
+		return "", rateLimit
 	}
 
 	// Check response status
@@ -97,43 +120,138 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 			"status", resp.StatusCode,
 			"response", string(body),
 		)
-		return fmt.Errorf("WhatsApp API error (status %d): %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("WhatsApp API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
+	s.limiter.Succeeded("WhatsApp", phoneNumberID)
 	s.logger.Info("✅ WhatsApp message sent successfully",
 		"status", resp.StatusCode,
 		"response", string(body),
 	)
 
-	return nil
+	var result struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &result); err == nil && len(result.Messages) > 0 {
+		return result.Messages[0].ID, nil
+	}
+
+	return "", nil
+}
+
+// WhatsAppTemplatePayload is a message template as listed by the Graph API
+type WhatsAppTemplatePayload struct {
+	ID         string                             `json:"id"`
+	Name       string                             `json:"name"`
+	Language   string                             `json:"language"`
+	Category   string                             `json:"category"`
+	Status     string                             `json:"status"`
+	Components []models.WhatsAppTemplateComponent `json:"components"`
+}
+
+// FetchTemplates lists every message template of the business account, following pagination
+func (s *WhatsAppService) FetchTemplates() ([]WhatsAppTemplatePayload, error) {
+	accessToken := os.Getenv("WHATSAPP_ACCESS_TOKEN")
+	businessAccountID := os.Getenv("WHATSAPP_BUSINESS_ACCOUNT_ID")
+	apiVersion := os.Getenv("WHATSAPP_API_VERSION")
+
+	if accessToken == "" || businessAccountID == "" {
+		return nil, fmt.Errorf("WhatsApp business account not configured")
+	}
+
+	if apiVersion == "" {
+		apiVersion = "v18.0"
+	}
+
+	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/message_templates?limit=100", apiVersion, businessAccountID)
+
+	var templates []WhatsAppTemplatePayload
+	for url != "" {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch templates: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("WhatsApp API error (status %d): %s", resp.StatusCode, string(body))
+		}
+
+		var page struct {
+			Data   []WhatsAppTemplatePayload `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse templates: %w", err)
+		}
+
+		templates = append(templates, page.Data...)
+		url = page.Paging.Next
+	}
+
+	return templates, nil
 }
 
-// SendTemplateMessage sends a template message
-func (s *WhatsAppService) SendTemplateMessage(to, templateName string, params map[string]interface{}) error {
+// SendTemplateMessage sends an approved template with the given components
+// (header/body parameters) and returns the WhatsApp message ID
+func (s *WhatsAppService) SendTemplateMessage(to, templateName, language string, components []map[string]interface{}) (string, error) {
 	accessToken := os.Getenv("WHATSAPP_ACCESS_TOKEN")
 	phoneNumberID := os.Getenv("WHATSAPP_PHONE_NUMBER_ID")
 	apiVersion := os.Getenv("WHATSAPP_API_VERSION")
 
 	if accessToken == "" || phoneNumberID == "" {
-		return fmt.Errorf("WhatsApp credentials not configured")
+		return "", fmt.Errorf("WhatsApp credentials not configured")
 	}
 
 	if apiVersion == "" {
 		apiVersion = "v18.0"
 	}
 
+	if language == "" {
+		language = "id"
+	}
+
 	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", apiVersion, phoneNumberID)
 
+	template := map[string]interface{}{
+		"name":     templateName,
+		"language": map[string]string{"code": language},
+	}
+	if len(components) > 0 {
+		template["components"] = components
+	}
+
 	reqBody := map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"to":                to,
 		"type":              "template",
-		"template": map[string]interface{}{
-			"name":     templateName,
-			"language": map[string]string{"code": "id"},
-		},
+		"template":          template,
+	}
+
+	if err := s.limiter.Wait("WhatsApp", phoneNumberID, to); err != nil {
+		return "", err
 	}
 
+	s.logger.Info("📤 Sending WhatsApp template",
+		"to", to,
+		"template", templateName,
+		"language", language,
+	)
+
 	jsonData, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
@@ -141,14 +259,30 @@ func (s *WhatsAppService) SendTemplateMessage(to, templateName string, params ma
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
+	body, _ := io.ReadAll(resp.Body)
+	if rateLimit := detectRateLimit("WhatsApp", resp, body); rateLimit != nil {
+		s.limiter.Throttled("WhatsApp", phoneNumberID, rateLimit.RetryAfter)
+		return "", rateLimit
+	}
+
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("WhatsApp API error: %s", string(body))
+		return "", fmt.Errorf("WhatsApp API error: %s", string(body))
+	}
+
+	s.limiter.Succeeded("WhatsApp", phoneNumberID)
+
+	var result struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &result); err == nil && len(result.Messages) > 0 {
+		return result.Messages[0].ID, nil
 	}
 
-	return nil
+	return "", nil
 }
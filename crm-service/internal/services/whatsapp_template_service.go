@@ -0,0 +1,262 @@
+package services
+
+import (
+	"divine-crm/internal/models"
+	"divine-crm/internal/repository"
+	"divine-crm/internal/utils"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WhatsAppServiceWindow is how long after a contact's last message free-form text is allowed
+const WhatsAppServiceWindow = 24 * time.Hour
+
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{(\d+)\}\}`)
+
+type WhatsAppTemplateService struct {
+	repo            *repository.WhatsAppTemplateRepository
+	contactRepo     *repository.ContactRepository
+	whatsappService *WhatsAppService
+	logger          *utils.Logger
+}
+
+func NewWhatsAppTemplateService(
+	repo *repository.WhatsAppTemplateRepository,
+	contactRepo *repository.ContactRepository,
+	whatsappService *WhatsAppService,
+	logger *utils.Logger,
+) *WhatsAppTemplateService {
+	return &WhatsAppTemplateService{
+		repo:            repo,
+		contactRepo:     contactRepo,
+		whatsappService: whatsappService,
+		logger:          logger,
+	}
+}
+
+// SyncResult summarises a template sync
+type SyncResult struct {
+	Synced  int   `json:"synced"`
+	Removed int64 `json:"removed"`
+}
+
+// TemplatePreview shows the parameter values a template would be sent with
+type TemplatePreview struct {
+	Template   *models.WhatsAppTemplate `json:"template"`
+	Parameters map[string][]string      `json:"parameters"` // By component: header, body
+	Text       string                   `json:"text"`       // Body with the parameters filled in
+}
+
+// WithinServiceWindow reports whether free-form messages may be sent to a WhatsApp contact
+func WithinServiceWindow(contact *models.Contact, now time.Time) bool {
+	return contact.LastInboundAt != nil && now.Sub(*contact.LastInboundAt) < WhatsAppServiceWindow
+}
+
+func (s *WhatsAppTemplateService) GetAll(status string) ([]models.WhatsAppTemplate, error) {
+	return s.repo.FindAll(strings.ToUpper(status))
+}
+
+func (s *WhatsAppTemplateService) GetByID(id uint) (*models.WhatsAppTemplate, error) {
+	template, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, fmt.Errorf("template not found")
+	}
+	return template, nil
+}
+
+// Sync fetches templates from Meta, keeping local parameter mappings
+func (s *WhatsAppTemplateService) Sync() (*SyncResult, error) {
+	remote, err := s.whatsappService.FetchTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	syncedAt := time.Now()
+	for _, item := range remote {
+		template, err := s.repo.FindByNameAndLanguage(item.Name, item.Language)
+		if err != nil {
+			return nil, err
+		}
+		if template == nil {
+			template = &models.WhatsAppTemplate{Name: item.Name, Language: item.Language}
+		}
+
+		template.ExternalID = item.ID
+		template.Category = item.Category
+		template.Status = item.Status
+		template.Components = item.Components
+		template.SyncedAt = &syncedAt
+
+		if err := s.repo.Save(template); err != nil {
+			return nil, err
+		}
+	}
+
+	removed, err := s.repo.MarkMissing(syncedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("✅ WhatsApp templates synced", "synced", len(remote), "removed", removed)
+	return &SyncResult{Synced: len(remote), Removed: removed}, nil
+}
+
+// UpdateParameters sets how template placeholders are filled from contact fields
+func (s *WhatsAppTemplateService) UpdateParameters(id uint, params []models.TemplateParameter) (*models.WhatsAppTemplate, error) {
+	template, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := placeholderCounts(template)
+	for i := range params {
+		param := &params[i]
+		param.Component = strings.ToLower(strings.TrimSpace(param.Component))
+		if param.Index < 1 || param.Index > expected[param.Component] {
+			return nil, fmt.Errorf("parameter %d: %s has no placeholder {{%d}}", i+1, param.Component, param.Index)
+		}
+		if _, err := ParseMessageTemplate(param.Source); err != nil {
+			return nil, fmt.Errorf("parameter %d: %v", i+1, err)
+		}
+	}
+
+	template.Parameters = params
+	if err := s.repo.Save(template); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Updated WhatsApp template parameters", "id", id, "parameters", len(params))
+	return template, nil
+}
+
+// Preview renders the template parameters for a contact
+func (s *WhatsAppTemplateService) Preview(id, contactID uint) (*TemplatePreview, error) {
+	template, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	contact, err := s.contactRepo.FindByID(contactID)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil {
+		return nil, fmt.Errorf("contact not found")
+	}
+
+	values, err := renderTemplateParameters(template, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ""
+	for _, component := range template.Components {
+		if strings.EqualFold(component.Type, "BODY") {
+			body = fillPlaceholders(component.Text, values["body"])
+		}
+	}
+
+	return &TemplatePreview{Template: template, Parameters: values, Text: body}, nil
+}
+
+// SendToContact sends an approved template to a WhatsApp contact and returns the message ID
+func (s *WhatsAppTemplateService) SendToContact(templateID uint, contact *models.Contact) (string, error) {
+	template, err := s.GetByID(templateID)
+	if err != nil {
+		return "", err
+	}
+	if template.Status != "APPROVED" {
+		return "", fmt.Errorf("WhatsApp template %s is %s", template.Name, strings.ToLower(template.Status))
+	}
+
+	values, err := renderTemplateParameters(template, contact)
+	if err != nil {
+		return "", err
+	}
+
+	var components []map[string]interface{}
+	for _, name := range []string{"header", "body"} {
+		if len(values[name]) == 0 {
+			continue
+		}
+		parameters := make([]map[string]string, len(values[name]))
+		for i, value := range values[name] {
+			parameters[i] = map[string]string{"type": "text", "text": value}
+		}
+		components = append(components, map[string]interface{}{
+			"type":       name,
+			"parameters": parameters,
+		})
+	}
+
+	return s.whatsappService.SendTemplateMessage(contact.ChannelID, template.Name, template.Language, components)
+}
+
+// placeholderCounts returns the highest {{n}} placeholder in the header and body text
+func placeholderCounts(template *models.WhatsAppTemplate) map[string]int {
+	counts := map[string]int{}
+	for _, component := range template.Components {
+		name := strings.ToLower(component.Type)
+		if name != "header" && name != "body" {
+			continue
+		}
+		for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(component.Text, -1) {
+			if n, _ := strconv.Atoi(match[1]); n > counts[name] {
+				counts[name] = n
+			}
+		}
+	}
+	return counts
+}
+
+// renderTemplateParameters fills every placeholder from the parameter mapping.
+// Meta rejects empty parameters, so unmapped or blank values are an error.
+func renderTemplateParameters(template *models.WhatsAppTemplate, contact *models.Contact) (map[string][]string, error) {
+	values := map[string][]string{}
+	for component, count := range placeholderCounts(template) {
+		values[component] = make([]string, count)
+	}
+
+	for _, param := range template.Parameters {
+		slots, ok := values[param.Component]
+		if !ok || param.Index < 1 || param.Index > len(slots) {
+			continue
+		}
+		tmpl, err := ParseMessageTemplate(param.Source)
+		if err != nil {
+			return nil, err
+		}
+		value, err := RenderMessageTemplate(tmpl, contact)
+		if err != nil {
+			return nil, err
+		}
+		slots[param.Index-1] = value
+	}
+
+	for component, slots := range values {
+		for i, value := range slots {
+			if strings.TrimSpace(value) == "" {
+				return nil, fmt.Errorf("template %s: %s parameter {{%d}} is empty for contact %s",
+					template.Name, component, i+1, contact.Code)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+func fillPlaceholders(text string, values []string) string {
+	return templatePlaceholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		n, _ := strconv.Atoi(match[2 : len(match)-2])
+		if n >= 1 && n <= len(values) {
+			return values[n-1]
+		}
+		return match
+	})
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"0 9 * * 1-5", false},
+		{"*/15 8-17 * * *", false},
+		{"0 0 1,15 * *", false},
+		{"30 6 * * 7", false},
+		{"0 9 * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"a * * * *", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	// Wednesday
+	from := time.Date(2026, 10, 14, 10, 7, 30, 0, jakarta)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", from, time.Date(2026, 10, 14, 10, 8, 0, 0, jakarta)},
+		{"strictly after a match", "8 10 * * *", time.Date(2026, 10, 14, 10, 8, 0, 0, jakarta), time.Date(2026, 10, 15, 10, 8, 0, 0, jakarta)},
+		{"every 15 minutes", "*/15 * * * *", from, time.Date(2026, 10, 14, 10, 15, 0, 0, jakarta)},
+		{"later today", "0 14 * * *", from, time.Date(2026, 10, 14, 14, 0, 0, 0, jakarta)},
+		{"tomorrow", "0 9 * * *", from, time.Date(2026, 10, 15, 9, 0, 0, 0, jakarta)},
+		{"weekdays skip the weekend", "0 9 * * 1-5", time.Date(2026, 10, 16, 12, 0, 0, 0, jakarta), time.Date(2026, 10, 19, 9, 0, 0, 0, jakarta)},
+		{"7 is Sunday", "30 6 * * 7", from, time.Date(2026, 10, 18, 6, 30, 0, 0, jakarta)},
+		{"first of next month", "0 0 1 * *", from, time.Date(2026, 11, 1, 0, 0, 0, 0, jakarta)},
+		{"day of month or weekday", "0 8 20 * 5", from, time.Date(2026, 10, 16, 8, 0, 0, 0, jakarta)},
+		{"next year", "0 0 1 1 *", from, time.Date(2027, 1, 1, 0, 0, 0, 0, jakarta)},
+		{"leap day", "0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, jakarta)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) for %q = %v, want %v", tt.from, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextImpossible(t *testing.T) {
+	schedule, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron error = %v", err)
+	}
+	if got := schedule.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next for February 31st = %v, want zero time", got)
+	}
+}